@@ -0,0 +1,201 @@
+// Command mockoidc is a minimal OpenID Connect provider for exercising social
+// login locally. It approves every request: pass login_hint=<email> on the
+// authorization URL to sign in without a form, and email_verified=false to
+// simulate an unverified address. Never expose it outside a dev machine.
+//
+//	go run ./cmd/mockoidc -addr :9999
+//	OAUTH_MOCK_ISSUER=http://localhost:9999 OAUTH_MOCK_CLIENT_ID=workshop OAUTH_MOCK_CLIENT_SECRET=secret go run .
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-1"
+
+type grant struct {
+	clientID      string
+	redirectURI   string
+	challenge     string
+	nonce         string
+	email         string
+	emailVerified bool
+	expires       time.Time
+}
+
+type server struct {
+	issuer, clientID, clientSecret string
+	key                            *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+var form = template.Must(template.New("form").Parse(`<!doctype html>
+<title>Mock sign-in</title>
+<form method="get" action="/authorize">
+{{range $k, $v := .}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">{{end}}
+<label>Email <input name="login_hint" type="email" required></label>
+<label><input type="checkbox" name="email_verified" value="true" checked> verified</label>
+<button>Sign in</button>
+</form>`))
+
+func main() {
+	addr := flag.String("addr", ":9999", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9999", "issuer URL as seen by the API")
+	clientID := flag.String("client-id", "workshop", "accepted client_id")
+	clientSecret := flag.String("client-secret", "secret", "accepted client_secret")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &server{issuer: strings.TrimSuffix(*issuer, "/"), clientID: *clientID, clientSecret: *clientSecret, key: key, grants: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+	log.Printf("mock OIDC provider on %s (issuer %s)", *addr, s.issuer)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (s *server) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != s.clientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Host == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	email := q.Get("login_hint")
+	if email == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = form.Execute(w, q)
+		return
+	}
+	code := random()
+	s.mu.Lock()
+	s.grants[code] = grant{
+		clientID:      s.clientID,
+		redirectURI:   q.Get("redirect_uri"),
+		challenge:     q.Get("code_challenge"),
+		nonce:         q.Get("nonce"),
+		email:         email,
+		emailVerified: q.Get("email_verified") != "false",
+		expires:       time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.clientID || secret != s.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	g, found := s.grants[code]
+	delete(s.grants, code)
+	s.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !found || time.Now().After(g.expires) || g.redirectURI != r.PostForm.Get("redirect_uri") ||
+		g.challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	sub := sha256.Sum256([]byte(strings.ToLower(g.email)))
+	now := time.Now()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            s.issuer,
+		"aud":            g.clientID,
+		"sub":            fmt.Sprintf("%x", sub[:8]),
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          g.nonce,
+		"email":          g.email,
+		"email_verified": g.emailVerified,
+	})
+	tok.Header["kid"] = keyID
+	idToken, err := tok.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": random(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, _ *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func random() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"workshop-be/internal/httpx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// ListEvents godoc
+// @Summary Query audit events (admin)
+// @Description Newest first. user_id matches events where the user is actor or subject. from/to are RFC3339 (to is exclusive). Page with before_id = next_before_id.
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Produce json
+// @Param user_id query int false "actor or subject user id"
+// @Param type query string false "event type, e.g. login.failure"
+// @Param from query string false "RFC3339 start (inclusive)"
+// @Param to query string false "RFC3339 end (exclusive)"
+// @Param before_id query int false "cursor from next_before_id"
+// @Param limit query int false "page size (default 50, max 500)"
+// @Success 200 {object} QueryResult
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/audit-events [get]
+func (h *Handler) ListEvents(c *fiber.Ctx) error {
+	adminID, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var f Filter
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_FILTER", "invalid user_id")
+		}
+		uid := uint(id)
+		f.UserID = &uid
+	}
+	f.Type = c.Query("type")
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_FILTER", "invalid "+p.name)
+		}
+		*p.dst = &t
+	}
+	if v := c.Query("before_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_FILTER", "invalid before_id")
+		}
+		f.BeforeID = uint(id)
+	}
+	f.Limit = c.QueryInt("limit", 0)
+
+	res, err := h.svc.Query(c.UserContext(), f)
+	if err != nil {
+		if err == ErrInvalidFilter {
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_FILTER", "to must not be before from")
+		}
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	h.svc.Record(c.UserContext(), Entry{
+		Type:    EventAdminAction,
+		ActorID: &adminID,
+		UserID:  f.UserID,
+		Details: map[string]any{"action": "audit.query", "type": f.Type, "from": f.From, "to": f.To},
+	})
+	return c.JSON(res)
+}
+
+func RegisterAdminRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	r.Get("/audit-events", h.ListEvents)
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Event types. Keep them stable: they are stored and used as query filters.
+const (
+	EventRegister           = "register"
+	EventRegisterExisting   = "register.existing_email"
+	EventLoginSuccess       = "login.success"
+	EventLoginFailure       = "login.failure"
+	EventLoginStepUp        = "login.step_up"
+	EventProfileUpdate      = "profile.update"
+	EventPasswordChange     = "password.change"
+	EventAccountDelete      = "account.delete"
+	EventAccountRestore     = "account.restore"
+	EventAccountPurge       = "account.purge"
+	EventDataExport         = "data.export"
+	EventAdminAction        = "admin.action"
+	EventConsentUpdate      = "consent.update"
+	EventEmailChangeRequest = "email.change_request"
+	EventEmailChange        = "email.change"
+	EventIdentityLink       = "identity.link"
+	EventIdentityUnlink     = "identity.unlink"
+	EventOAuthGrant         = "oauth.grant"
+	EventOAuthRevoke        = "oauth.revoke"
+	EventMagicLinkRequest   = "magic_link.request"
+	EventPasskeyRegister    = "passkey.register"
+	EventPasskeyDelete      = "passkey.delete"
+	EventAPIKeyCreate       = "api_key.create"
+	EventAPIKeyRevoke       = "api_key.revoke"
+)
+
+var ErrAppendOnly = errors.New("audit events are append-only")
+
+// Event is a single security-relevant action. Rows are never updated or deleted.
+type Event struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	Type      string    `json:"type" gorm:"size:50;index;not null"`
+	// ActorID is who performed the action; nil for anonymous (e.g. failed login).
+	ActorID *uint `json:"actor_id" gorm:"index"`
+	// UserID is the account the action concerns.
+	UserID    *uint  `json:"user_id" gorm:"index"`
+	IP        string `json:"ip" gorm:"size:64"`
+	UserAgent string `json:"user_agent" gorm:"size:255"`
+	RequestID string `json:"request_id" gorm:"size:128"`
+	// Details is a JSON document specific to the event type (e.g. field diffs).
+	Details string `json:"details" gorm:"type:text"`
+}
+
+func (Event) TableName() string { return "audit_events" }
+
+func (Event) BeforeUpdate(*gorm.DB) error { return ErrAppendOnly }
+
+func (Event) BeforeDelete(*gorm.DB) error { return ErrAppendOnly }
+
+// InstallGuards adds SQLite triggers so the table stays append-only even for
+// writes that bypass GORM hooks (raw SQL, batch updates).
+func InstallGuards(d *gorm.DB) error {
+	stmts := []string{
+		`CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+		BEGIN SELECT RAISE(ABORT, 'audit_events is append-only'); END;`,
+		`CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+		BEGIN SELECT RAISE(ABORT, 'audit_events is append-only'); END;`,
+	}
+	for _, stmt := range stmts {
+		if err := d.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"workshop-be/internal/clientinfo"
+	"workshop-be/internal/db"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type Service struct{}
+
+func NewService() *Service { return &Service{} }
+
+// Entry is what callers supply; request metadata is taken from the context.
+type Entry struct {
+	Type    string
+	ActorID *uint
+	UserID  *uint
+	Details any
+}
+
+// FieldChange is the before/after pair recorded for profile diffs.
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Record appends an event. Failures are logged, never returned: an audit
+// outage must not block the user action it describes.
+func (s *Service) Record(ctx context.Context, e Entry) {
+	info := clientinfo.From(ctx)
+	ev := Event{
+		Type:      e.Type,
+		ActorID:   e.ActorID,
+		UserID:    e.UserID,
+		IP:        info.IP,
+		UserAgent: info.UserAgent,
+		RequestID: info.RequestID,
+	}
+	if e.Details != nil {
+		b, err := json.Marshal(e.Details)
+		if err != nil {
+			slog.ErrorContext(ctx, "audit details marshal failed", "type", e.Type, "err", err)
+		} else {
+			ev.Details = string(b)
+		}
+	}
+	if err := db.MustGet().WithContext(ctx).Create(&ev).Error; err != nil {
+		slog.ErrorContext(ctx, "audit record failed", "type", e.Type, "err", err)
+	}
+}
+
+// Filter narrows Query. UserID matches events where the user is actor or subject.
+type Filter struct {
+	UserID   *uint
+	Type     string
+	From     *time.Time
+	To       *time.Time
+	BeforeID uint
+	Limit    int
+}
+
+// EventView is the API shape of Event with Details decoded.
+type EventView struct {
+	ID        uint            `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Type      string          `json:"type"`
+	ActorID   *uint           `json:"actor_id"`
+	UserID    *uint           `json:"user_id"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	RequestID string          `json:"request_id"`
+	Details   json.RawMessage `json:"details,omitempty" swaggertype:"object"`
+}
+
+type QueryResult struct {
+	Events []EventView `json:"events"`
+	// NextBeforeID is passed as before_id to fetch the next (older) page; 0 when done.
+	NextBeforeID uint `json:"next_before_id"`
+}
+
+// ForUser returns every event concerning userID, oldest first, optionally
+// limited to the given types. Used for personal data exports.
+func (s *Service) ForUser(ctx context.Context, userID uint, types ...string) ([]EventView, error) {
+	q := db.MustGet().WithContext(ctx).Where("user_id = ?", userID)
+	if len(types) > 0 {
+		q = q.Where("type IN ?", types)
+	}
+	var rows []Event
+	if err := q.Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]EventView, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, toView(r))
+	}
+	return out, nil
+}
+
+// Query returns events newest first, paginated by id.
+func (s *Service) Query(ctx context.Context, f Filter) (*QueryResult, error) {
+	if f.From != nil && f.To != nil && f.To.Before(*f.From) {
+		return nil, ErrInvalidFilter
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	q := db.MustGet().WithContext(ctx).Model(&Event{})
+	if f.UserID != nil {
+		q = q.Where("user_id = ? OR actor_id = ?", *f.UserID, *f.UserID)
+	}
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	// SQLite compares the stored timestamps as text, so bounds must be in
+	// UTC like the rows, whatever offset the caller gave.
+	if f.From != nil {
+		q = q.Where("created_at >= ?", f.From.UTC())
+	}
+	if f.To != nil {
+		q = q.Where("created_at < ?", f.To.UTC())
+	}
+	if f.BeforeID > 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+	var rows []Event
+	if err := q.Order("id DESC").Limit(limit + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	res := &QueryResult{Events: make([]EventView, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		res.NextBeforeID = rows[len(rows)-1].ID
+	}
+	for _, r := range rows {
+		res.Events = append(res.Events, toView(r))
+	}
+	return res, nil
+}
+
+func toView(r Event) EventView {
+	v := EventView{
+		ID: r.ID, CreatedAt: r.CreatedAt, Type: r.Type, ActorID: r.ActorID, UserID: r.UserID,
+		IP: r.IP, UserAgent: r.UserAgent, RequestID: r.RequestID,
+	}
+	if r.Details != "" {
+		v.Details = json.RawMessage(r.Details)
+	}
+	return v
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"workshop-be/internal/db"
+)
+
+func TestQueryTimeRangeOffset(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "app.db"), &Event{})
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, h := range []int{10, 12, 14} {
+		ev := Event{Type: EventLoginSuccess, CreatedAt: day.Add(time.Duration(h) * time.Hour)}
+		if err := db.MustGet().Create(&ev).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 18:00-20:00 at +07:00 is 11:00-13:00 UTC and holds only the 12:00 event.
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	from := time.Date(2026, 3, 1, 18, 0, 0, 0, bangkok)
+	to := time.Date(2026, 3, 1, 20, 0, 0, 0, bangkok)
+	res, err := NewService().Query(ctx, Filter{From: &from, To: &to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Events) != 1 || !res.Events[0].CreatedAt.Equal(day.Add(12*time.Hour)) {
+		t.Fatalf("events = %+v, want only the 12:00 UTC one", res.Events)
+	}
+}
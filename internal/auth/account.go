@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/db"
+
+	"gorm.io/gorm"
+)
+
+// DeleteAccountRequest confirms the deletion with the password or an
+// emailed code, see Reauth.
+type DeleteAccountRequest struct {
+	Reauth
+}
+
+// LogValue keeps the password and code out of logs.
+func (DeleteAccountRequest) LogValue() slog.Value {
+	return slog.GroupValue()
+}
+
+type DeleteAccountOutput struct {
+	// RestorableUntil is the last moment logging in restores the account.
+	RestorableUntil time.Time `json:"restorable_until"`
+}
+
+// DeleteAccount soft-deletes the user after re-confirming the password or
+// an emailed code. The account can be restored by logging in until
+// RestorableUntil.
+func (s *Service) DeleteAccount(ctx context.Context, userID uint, req DeleteAccountRequest) (*DeleteAccountOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.DeleteAccount")
+	defer span.End()
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
+		return nil, err
+	}
+	method, err := s.reauthenticate(ctx, user, req.Reauth)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Delete(user).Error; err != nil {
+		return nil, err
+	}
+	until := time.Now().Add(s.DeletionGrace)
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAccountDelete, ActorID: &user.ID, UserID: &user.ID, Details: map[string]any{"restorable_until": until, "confirmed_with": method}})
+	return &DeleteAccountOutput{RestorableUntil: until}, nil
+}
+
+// restore undeletes an account inside its grace period; called by Login after
+// the password has been verified, or by VerifyStepUp after the code was.
+func (s *Service) restore(ctx context.Context, user *User) error {
+	if user.PurgedAt != nil || time.Since(user.DeletedAt.Time) > s.DeletionGrace {
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, UserID: &user.ID, Details: map[string]string{"reason": "account_deleted"}})
+		return ErrInvalidCredential
+	}
+	d := db.MustGet().WithContext(ctx)
+	if err := d.Unscoped().Model(user).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	user.DeletedAt.Valid = false
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAccountRestore, ActorID: &user.ID, UserID: &user.ID})
+	return nil
+}
+
+// PurgeFunc removes what another package keeps about a purged user, using
+// the purge transaction tx.
+type PurgeFunc func(ctx context.Context, tx *gorm.DB, userID uint) error
+
+// OnPurge adds fn to every account purge. Register purge functions before
+// RunPurger starts.
+func (s *Service) OnPurge(fn PurgeFunc) {
+	s.purgers = append(s.purgers, fn)
+}
+
+// PurgeDeleted anonymizes PII of accounts deleted longer ago than
+// DeletionGrace. Rows are kept (not hard-deleted) so anything referencing the
+// user id stays consistent; only identifying fields are overwritten. Each
+// account is purged in one transaction, so a failure leaves it for the next
+// run rather than half done.
+func (s *Service) PurgeDeleted(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.PurgeDeleted")
+	defer span.End()
+	d := db.MustGet().WithContext(ctx)
+	// Earlier versions kept unknown-email logins under user id 0.
+	if err := d.Where("user_id = 0").Delete(&LoginEvent{}).Error; err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-s.DeletionGrace)
+	var users []User
+	if err := d.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND purged_at IS NULL", cutoff).
+		Find(&users).Error; err != nil {
+		return 0, err
+	}
+	for i := range users {
+		u := &users[i]
+		if err := d.Transaction(func(tx *gorm.DB) error { return s.purge(ctx, tx, u) }); err != nil {
+			return i, err
+		}
+		if u.AvatarKey != nil && s.Avatars != nil {
+			s.Avatars.Delete(ctx, *u.AvatarKey)
+		}
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventAccountPurge, UserID: &u.ID})
+	}
+	return len(users), nil
+}
+
+// purge anonymizes u and deletes what belongs to the person inside tx.
+func (s *Service) purge(ctx context.Context, tx *gorm.DB, u *User) error {
+	now := time.Now()
+	err := tx.Unscoped().Model(u).Updates(map[string]any{
+		// Unique, non-routable placeholder keeps the unique index satisfied and frees the real address.
+		"email":               fmt.Sprintf("deleted-%d@deleted.invalid", u.ID),
+		"password_hash":       "",
+		"email_verified_at":   nil,
+		"first_name":          nil,
+		"last_name":           nil,
+		"phone":               nil,
+		"date_of_birth":       nil,
+		"gender":              nil,
+		"address_line":        nil,
+		"address_subdistrict": nil,
+		"address_district":    nil,
+		"address_province":    nil,
+		"address_postcode":    nil,
+		"avatar_key":          nil,
+		"is_active":           false,
+		"purged_at":           &now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	// Linked provider accounts, passkeys, API keys, login history (IPs,
+	// devices, locations), sign-in links and pending email changes belong
+	// to the person; drop them. Consent records and referrals are kept
+	// without the client details.
+	owned := []struct {
+		model  any
+		column string
+	}{
+		{&Identity{}, "user_id"},
+		{&Passkey{}, "user_id"},
+		{&APIKey{}, "owner_id"},
+		{&LoginEvent{}, "user_id"},
+		{&StepUpChallenge{}, "user_id"},
+		{&EmailChange{}, "user_id"},
+		{&MagicLink{}, "user_id"},
+	}
+	for _, o := range owned {
+		if err := tx.Where(o.column+" = ?", u.ID).Delete(o.model).Error; err != nil {
+			return err
+		}
+	}
+	if err := s.consents.Anonymize(ctx, tx, u.ID); err != nil {
+		return err
+	}
+	if s.Referrals != nil {
+		if err := s.Referrals.Forget(ctx, tx, u.ID); err != nil {
+			return err
+		}
+	}
+	for _, fn := range s.purgers {
+		if err := fn(ctx, tx, u.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunPurger calls PurgeDeleted every interval until ctx is cancelled. A
+// non-positive interval disables the job.
+func (s *Service) RunPurger(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.PurgeDeleted(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "account purge failed", "err", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "purged deleted accounts", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
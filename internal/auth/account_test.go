@@ -0,0 +1,144 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/auth"
+	"workshop-be/internal/consent"
+	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+	"workshop-be/internal/export"
+	"workshop-be/internal/oauth"
+	"workshop-be/internal/points"
+	"workshop-be/internal/referral"
+)
+
+// TestPurgeDeleted purges one deleted account next to a live one and checks
+// that everything personal of the first is gone and the second is untouched.
+func TestPurgeDeleted(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test secret")
+	ctx := context.Background()
+	db.Init(filepath.Join(t.TempDir(), "app.db"),
+		&auth.User{}, &audit.Event{}, &consent.Record{}, &auth.Identity{}, &auth.Passkey{}, &auth.APIKey{},
+		&auth.LoginEvent{}, &auth.StepUpChallenge{}, &auth.EmailChange{}, &auth.MagicLink{},
+		&referral.Referral{}, &referral.Device{}, &oauth.Grant{}, &oauth.AuthCode{}, &export.Export{})
+	d := db.MustGet()
+
+	s := auth.NewService()
+	s.DeletionGrace = time.Hour
+	s.Referrals = referral.NewService(points.NewService(events.NewBus()))
+	exports := export.NewService(t.TempDir(), []byte("export key"))
+	s.OnPurge(exports.Forget)
+	key, err := oauth.LoadKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.OnPurge(oauth.NewService("http://localhost", key, s).Forget)
+
+	name := "Somchai"
+	gone := auth.User{Email: "gone@example.com", PasswordHash: "x", FirstName: &name}
+	kept := auth.User{Email: "kept@example.com", PasswordHash: "x"}
+	for _, u := range []*auth.User{&gone, &kept} {
+		if err := d.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Delete(&gone).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Unscoped().Model(&gone).Update("deleted_at", time.Now().Add(-2*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[uint]string{}
+	for _, u := range []auth.User{gone, kept} {
+		id := u.ID
+		tag := u.Email
+		file := filepath.Join(t.TempDir(), "export.json")
+		if err := os.WriteFile(file, []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		files[id] = file
+		rows := []any{
+			&auth.Identity{UserID: id, Provider: "google", Subject: tag},
+			&auth.Passkey{UserID: id, CredentialID: tag, Name: "phone", PublicKey: []byte{1}},
+			&auth.APIKey{OwnerID: id, Name: "pos", Prefix: tag, KeyHash: tag},
+			&auth.LoginEvent{UserID: id, Method: "password", IP: "203.0.113.7"},
+			&auth.StepUpChallenge{UserID: id, TokenHash: "s" + tag, CodeHash: tag},
+			&auth.EmailChange{UserID: id, NewEmail: "new-" + tag, TokenHash: "e" + tag},
+			&auth.MagicLink{UserID: id, TokenHash: "m" + tag},
+			&consent.Record{UserID: id, Purpose: consent.PurposeTerms, Granted: true, IP: "203.0.113.7", UserAgent: "test"},
+			&referral.Device{UserID: id, DeviceHash: tag},
+			&oauth.Grant{UserID: id, ClientID: "app", Scopes: []string{"openid"}},
+			&oauth.AuthCode{UserID: id, CodeHash: tag, ClientID: "app", RedirectURI: "https://app.example/cb", Scope: "openid", CodeChallenge: "c"},
+			&export.Export{ID: "x" + tag[:4], UserID: id, Format: export.FormatJSON, Status: export.StatusReady, FilePath: file},
+		}
+		for _, r := range rows {
+			if err := d.Create(r).Error; err != nil {
+				t.Fatalf("create %T: %v", r, err)
+			}
+		}
+	}
+
+	if err := d.Create(&auth.LoginEvent{Method: "password", Outcome: auth.OutcomeFailure, Reason: "unknown_email"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.PurgeDeleted(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeDeleted = %d, %v", n, err)
+	}
+
+	var u auth.User
+	if err := d.Unscoped().First(&u, gone.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if u.PurgedAt == nil || u.Email == gone.Email || u.FirstName != nil {
+		t.Fatalf("purged user keeps personal data: %+v", u)
+	}
+	owned := []struct {
+		model  any
+		column string
+	}{
+		{&auth.Identity{}, "user_id"},
+		{&auth.Passkey{}, "user_id"},
+		{&auth.APIKey{}, "owner_id"},
+		{&auth.LoginEvent{}, "user_id"},
+		{&auth.StepUpChallenge{}, "user_id"},
+		{&auth.EmailChange{}, "user_id"},
+		{&auth.MagicLink{}, "user_id"},
+		{&referral.Device{}, "user_id"},
+		{&oauth.Grant{}, "user_id"},
+		{&oauth.AuthCode{}, "user_id"},
+		{&export.Export{}, "user_id"},
+	}
+	for _, o := range owned {
+		for id, want := range map[uint]int64{gone.ID: 0, kept.ID: 1, 0: 0} {
+			var n int64
+			if err := d.Model(o.model).Where(o.column+" = ?", id).Count(&n).Error; err != nil {
+				t.Fatal(err)
+			}
+			if n != want {
+				t.Errorf("%T rows for user %d = %d, want %d", o.model, id, n, want)
+			}
+		}
+	}
+	var rec consent.Record
+	if err := d.Where("user_id = ?", gone.ID).First(&rec).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rec.IP != "" || rec.UserAgent != "" {
+		t.Errorf("consent record keeps client details: %+v", rec)
+	}
+	if _, err := os.Stat(files[gone.ID]); !os.IsNotExist(err) {
+		t.Errorf("export archive of the purged user still exists: %v", err)
+	}
+	if _, err := os.Stat(files[kept.ID]); err != nil {
+		t.Errorf("export archive of the live user: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Address is a Thai postal address, stored inline on users (address_* columns).
+type Address struct {
+	Line        *string `json:"line" gorm:"size:255"`
+	Subdistrict *string `json:"subdistrict" gorm:"size:100"`
+	District    *string `json:"district" gorm:"size:100"`
+	Province    *string `json:"province" gorm:"size:100"`
+	Postcode    *string `json:"postcode" gorm:"size:5"`
+}
+
+// AddressInput replaces the whole address. Sending every field empty clears it.
+type AddressInput struct {
+	// Line is house number, building, road (optional).
+	Line        string `json:"line" example:"99/1 ถ.สุขุมวิท"`
+	Subdistrict string `json:"subdistrict" example:"คลองเตยเหนือ"`
+	District    string `json:"district" example:"วัฒนา"`
+	// Province accepts the Thai or English name; stored as the Thai name.
+	Province string `json:"province" example:"กรุงเทพมหานคร"`
+	Postcode string `json:"postcode" example:"10110"`
+}
+
+func (a AddressInput) empty() bool {
+	return strings.TrimSpace(a.Line+a.Subdistrict+a.District+a.Province+a.Postcode) == ""
+}
+
+var postcodeRegex = regexp.MustCompile(`^(1[0-9]|[2-8][0-9]|9[0-6])[0-9]{3}$`)
+
+// normalize validates the input and returns the values to store.
+func (a AddressInput) normalize() (*Address, error) {
+	line := strings.TrimSpace(a.Line)
+	sub := strings.TrimSpace(a.Subdistrict)
+	dist := strings.TrimSpace(a.District)
+	post := strings.TrimSpace(a.Postcode)
+	prov, ok := canonicalProvince(a.Province)
+	if !ok {
+		return nil, ErrInvalidAddress
+	}
+	if len(line) > 255 || sub == "" || len(sub) > 100 || dist == "" || len(dist) > 100 || !postcodeRegex.MatchString(post) {
+		return nil, ErrInvalidAddress
+	}
+	out := &Address{Subdistrict: &sub, District: &dist, Province: &prov, Postcode: &post}
+	if line != "" {
+		out.Line = &line
+	}
+	return out, nil
+}
+
+// canonicalProvince maps a Thai or English province name (with or without the
+// "จังหวัด"/"จ." prefix, case and spacing ignored) to its Thai name.
+func canonicalProvince(name string) (string, bool) {
+	n := strings.TrimSpace(name)
+	n = strings.TrimPrefix(n, "จังหวัด")
+	n = strings.TrimPrefix(n, "จ.")
+	key := provinceKey(n)
+	if key == "" {
+		return "", false
+	}
+	th, ok := provinceIndex[key]
+	return th, ok
+}
+
+func provinceKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
+
+// provinces lists the 77 provinces (Bangkok included) as Thai, English pairs.
+var provinces = [][2]string{
+	{"กรุงเทพมหานคร", "Bangkok"},
+	{"กระบี่", "Krabi"},
+	{"กาญจนบุรี", "Kanchanaburi"},
+	{"กาฬสินธุ์", "Kalasin"},
+	{"กำแพงเพชร", "Kamphaeng Phet"},
+	{"ขอนแก่น", "Khon Kaen"},
+	{"จันทบุรี", "Chanthaburi"},
+	{"ฉะเชิงเทรา", "Chachoengsao"},
+	{"ชลบุรี", "Chon Buri"},
+	{"ชัยนาท", "Chai Nat"},
+	{"ชัยภูมิ", "Chaiyaphum"},
+	{"ชุมพร", "Chumphon"},
+	{"เชียงราย", "Chiang Rai"},
+	{"เชียงใหม่", "Chiang Mai"},
+	{"ตรัง", "Trang"},
+	{"ตราด", "Trat"},
+	{"ตาก", "Tak"},
+	{"นครนายก", "Nakhon Nayok"},
+	{"นครปฐม", "Nakhon Pathom"},
+	{"นครพนม", "Nakhon Phanom"},
+	{"นครราชสีมา", "Nakhon Ratchasima"},
+	{"นครศรีธรรมราช", "Nakhon Si Thammarat"},
+	{"นครสวรรค์", "Nakhon Sawan"},
+	{"นนทบุรี", "Nonthaburi"},
+	{"นราธิวาส", "Narathiwat"},
+	{"น่าน", "Nan"},
+	{"บึงกาฬ", "Bueng Kan"},
+	{"บุรีรัมย์", "Buri Ram"},
+	{"ปทุมธานี", "Pathum Thani"},
+	{"ประจวบคีรีขันธ์", "Prachuap Khiri Khan"},
+	{"ปราจีนบุรี", "Prachin Buri"},
+	{"ปัตตานี", "Pattani"},
+	{"พระนครศรีอยุธยา", "Phra Nakhon Si Ayutthaya"},
+	{"พะเยา", "Phayao"},
+	{"พังงา", "Phangnga"},
+	{"พัทลุง", "Phatthalung"},
+	{"พิจิตร", "Phichit"},
+	{"พิษณุโลก", "Phitsanulok"},
+	{"เพชรบุรี", "Phetchaburi"},
+	{"เพชรบูรณ์", "Phetchabun"},
+	{"แพร่", "Phrae"},
+	{"ภูเก็ต", "Phuket"},
+	{"มหาสารคาม", "Maha Sarakham"},
+	{"มุกดาหาร", "Mukdahan"},
+	{"แม่ฮ่องสอน", "Mae Hong Son"},
+	{"ยโสธร", "Yasothon"},
+	{"ยะลา", "Yala"},
+	{"ร้อยเอ็ด", "Roi Et"},
+	{"ระนอง", "Ranong"},
+	{"ระยอง", "Rayong"},
+	{"ราชบุรี", "Ratchaburi"},
+	{"ลพบุรี", "Lop Buri"},
+	{"ลำปาง", "Lampang"},
+	{"ลำพูน", "Lamphun"},
+	{"เลย", "Loei"},
+	{"ศรีสะเกษ", "Si Sa Ket"},
+	{"สกลนคร", "Sakon Nakhon"},
+	{"สงขลา", "Songkhla"},
+	{"สตูล", "Satun"},
+	{"สมุทรปราการ", "Samut Prakan"},
+	{"สมุทรสงคราม", "Samut Songkhram"},
+	{"สมุทรสาคร", "Samut Sakhon"},
+	{"สระแก้ว", "Sa Kaeo"},
+	{"สระบุรี", "Saraburi"},
+	{"สิงห์บุรี", "Sing Buri"},
+	{"สุโขทัย", "Sukhothai"},
+	{"สุพรรณบุรี", "Suphan Buri"},
+	{"สุราษฎร์ธานี", "Surat Thani"},
+	{"สุรินทร์", "Surin"},
+	{"หนองคาย", "Nong Khai"},
+	{"หนองบัวลำภู", "Nong Bua Lam Phu"},
+	{"อ่างทอง", "Ang Thong"},
+	{"อำนาจเจริญ", "Amnat Charoen"},
+	{"อุดรธานี", "Udon Thani"},
+	{"อุตรดิตถ์", "Uttaradit"},
+	{"อุทัยธานี", "Uthai Thani"},
+	{"อุบลราชธานี", "Ubon Ratchathani"},
+}
+
+var provinceIndex = func() map[string]string {
+	m := make(map[string]string, len(provinces)*2+2)
+	for _, p := range provinces {
+		m[provinceKey(p[0])] = p[0]
+		m[provinceKey(p[1])] = p[0]
+	}
+	// Common alternative spellings.
+	m[provinceKey("กรุงเทพ")] = "กรุงเทพมหานคร"
+	m[provinceKey("กทม.")] = "กรุงเทพมหานคร"
+	m[provinceKey("Ayutthaya")] = "พระนครศรีอยุธยา"
+	m[provinceKey("Phang Nga")] = "พังงา"
+	m[provinceKey("Sisaket")] = "ศรีสะเกษ"
+	return m
+}()
@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/db"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKeyName   = errors.New("invalid api key name")
+	ErrInvalidAPIKeyScope  = errors.New("invalid api key scope")
+	ErrInvalidAPIKeyExpiry = errors.New("invalid api key expiry")
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	// ErrInvalidAPIKey covers malformed, unknown, expired and revoked keys
+	// and keys whose owner is gone.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+// API key scopes. A scope is only granted to owners whose role may use the
+// routes it opens, and the owner's role is checked again on every request.
+const (
+	ScopeCardsVerify = "cards:verify"
+	ScopePointsEarn  = "points:earn"
+	ScopeAdmin       = "admin"
+)
+
+// scopeRoles lists the roles allowed to hold each scope.
+var scopeRoles = map[string][]string{
+	ScopeCardsVerify: {RoleStaff, RoleAdmin},
+	ScopePointsEarn:  {RoleStaff, RoleAdmin},
+	ScopeAdmin:       {RoleAdmin},
+}
+
+// apiKeyPrefix starts every key so leaked keys are easy to recognise and
+// secret scanners can match them.
+const apiKeyPrefix = "wsk_"
+
+const (
+	defaultAPIKeyDays = 90
+	maxAPIKeyDays     = 365
+	// apiKeyTouchInterval limits last_used_at writes to one per key per minute.
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKey is a long-lived credential for machine clients, acting as its
+// owner within its scopes. The full key is shown once; only its SHA-256 is
+// stored, and Prefix identifies it in lists and logs.
+type APIKey struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	OwnerID uint   `json:"owner_id" gorm:"index;not null"`
+	Name    string `json:"name" gorm:"size:100;not null"`
+	// Prefix is the non-secret start of the key, e.g. wsk_3f9a1c7e20b4.
+	Prefix     string     `json:"prefix" gorm:"size:20;uniqueIndex;not null"`
+	KeyHash    string     `json:"-" gorm:"size:64;not null"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip" gorm:"size:64"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func (APIKey) TableName() string { return "api_keys" }
+
+type APIKeyInput struct {
+	Name   string   `json:"name" example:"POS terminal 12"`
+	Scopes []string `json:"scopes" example:"cards:verify,points:earn"`
+	// ExpiresInDays defaults to 90; at most 365.
+	ExpiresInDays int `json:"expires_in_days" example:"90"`
+}
+
+// APIKeyCreated carries the full key, returned only by CreateAPIKey.
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key" example:"wsk_3f9a1c7e20b4_Zx8..."`
+}
+
+type APIKeysOutput struct {
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+// APIKeyPrincipal is who a valid key authenticates as.
+type APIKeyPrincipal struct {
+	KeyID  uint
+	UserID uint
+	Email  string
+	Role   string
+	Scopes []string
+}
+
+// newAPIKey returns a key and its stored prefix.
+func newAPIKey() (key, prefix string) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	prefix = apiKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix
+}
+
+// CreateAPIKey issues a key owned by ownerID. Every scope must be allowed
+// for the owner's current role.
+func (s *Service) CreateAPIKey(ctx context.Context, ownerID uint, in APIKeyInput) (*APIKeyCreated, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.CreateAPIKey")
+	defer span.End()
+	name := strings.TrimSpace(in.Name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return nil, ErrInvalidAPIKeyName
+	}
+	days := in.ExpiresInDays
+	if days == 0 {
+		days = defaultAPIKeyDays
+	}
+	if days < 0 || days > maxAPIKeyDays {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+	d := db.MustGet().WithContext(ctx)
+	owner, err := findUser(d, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(in.Scopes) == 0 {
+		return nil, ErrInvalidAPIKeyScope
+	}
+	scopes := []string{}
+	for _, sc := range in.Scopes {
+		roles, ok := scopeRoles[sc]
+		if !ok || !slices.Contains(roles, owner.Role) {
+			return nil, ErrInvalidAPIKeyScope
+		}
+		if !slices.Contains(scopes, sc) {
+			scopes = append(scopes, sc)
+		}
+	}
+	key, prefix := newAPIKey()
+	k := APIKey{
+		OwnerID:   ownerID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   sha256Hex(key),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, days),
+	}
+	if err := d.Create(&k).Error; err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAPIKeyCreate, ActorID: &ownerID, UserID: &ownerID, Details: map[string]any{"api_key_id": k.ID, "prefix": k.Prefix, "scopes": k.Scopes, "expires_at": k.ExpiresAt}})
+	return &APIKeyCreated{APIKey: k, Key: key}, nil
+}
+
+// APIKeys lists keys, newest first: the owner's, or everyone's for ownerID 0.
+func (s *Service) APIKeys(ctx context.Context, ownerID uint) (*APIKeysOutput, error) {
+	q := db.MustGet().WithContext(ctx).Order("id DESC")
+	if ownerID != 0 {
+		q = q.Where("owner_id = ?", ownerID)
+	}
+	out := &APIKeysOutput{APIKeys: []APIKey{}}
+	if err := q.Find(&out.APIKeys).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RevokeAPIKey revokes one of the user's keys. Revoking twice is a no-op.
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, id uint) error {
+	k, err := s.revokeAPIKey(ctx, id, &userID)
+	if err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAPIKeyRevoke, ActorID: &userID, UserID: &userID, Details: map[string]any{"api_key_id": k.ID, "prefix": k.Prefix}})
+	return nil
+}
+
+// AdminRevokeAPIKey revokes any user's key.
+func (s *Service) AdminRevokeAPIKey(ctx context.Context, adminID, id uint) error {
+	k, err := s.revokeAPIKey(ctx, id, nil)
+	if err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, ActorID: &adminID, UserID: &k.OwnerID, Details: map[string]any{"action": "api_key.revoke", "api_key_id": k.ID, "prefix": k.Prefix}})
+	return nil
+}
+
+func (s *Service) revokeAPIKey(ctx context.Context, id uint, ownerID *uint) (*APIKey, error) {
+	d := db.MustGet().WithContext(ctx)
+	q := d.Where("id = ?", id)
+	if ownerID != nil {
+		q = q.Where("owner_id = ?", *ownerID)
+	}
+	var k APIKey
+	if err := q.Take(&k).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	if k.RevokedAt == nil {
+		now := time.Now()
+		if err := d.Model(&k).Update("revoked_at", &now).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &k, nil
+}
+
+// AuthenticateAPIKey resolves a key sent by a client. The owner's current
+// role is returned, so demoting or deleting the owner takes effect at once.
+// ip is recorded as the key's last use.
+func AuthenticateAPIKey(ctx context.Context, key, ip string) (*APIKeyPrincipal, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	prefix, _, ok := strings.Cut(key[len(apiKeyPrefix):], "_")
+	if !ok || len(prefix) != 12 {
+		return nil, ErrInvalidAPIKey
+	}
+	d := db.MustGet().WithContext(ctx)
+	var k APIKey
+	if err := d.Where("prefix = ?", apiKeyPrefix+prefix).Take(&k).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(sha256Hex(key)), []byte(k.KeyHash)) != 1 || k.RevokedAt != nil || now.After(k.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+	owner, err := findUser(d, k.OwnerID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	d.Model(&APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", k.ID, now.Add(-apiKeyTouchInterval)).
+		Updates(map[string]any{"last_used_at": &now, "last_used_ip": ip})
+	return &APIKeyPrincipal{KeyID: k.ID, UserID: owner.ID, Email: owner.Email, Role: owner.Role, Scopes: k.Scopes}, nil
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/db"
+	"workshop-be/internal/mailer"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSameEmail    = errors.New("new email equals current email")
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// emailChangeTTL is how long a confirmation link stays valid.
+const emailChangeTTL = 24 * time.Hour
+
+// EmailChange is a pending address change. Only the SHA-256 of the token is
+// stored, so a database leak does not yield usable confirmation links.
+type EmailChange struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index;not null"`
+	NewEmail   string `gorm:"size:255;not null"`
+	TokenHash  string `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (EmailChange) TableName() string { return "email_changes" }
+
+// EmailChangeRequest is confirmed with the password or an emailed code, see
+// Reauth.
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email"`
+	Reauth
+}
+
+// LogValue keeps the password and code out of logs.
+func (in EmailChangeRequest) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("new_email", in.NewEmail))
+}
+
+type EmailChangeOutput struct {
+	PendingEmail string    `json:"pending_email"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type EmailChangeConfirmRequest struct {
+	Token string `json:"token"`
+}
+
+// RequestEmailChange starts an address change: after re-checking the password
+// or an emailed code it mails a confirmation link to the new address and a
+// notice to the current one. The email is not changed until
+// ConfirmEmailChange. A new request supersedes any earlier pending one.
+func (s *Service) RequestEmailChange(ctx context.Context, userID uint, req EmailChangeRequest) (*EmailChangeOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.RequestEmailChange")
+	defer span.End()
+	newEmail := normalizeEmail(req.NewEmail)
+	if !emailRegex.MatchString(newEmail) {
+		return nil, ErrInvalidEmail
+	}
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
+		return nil, err
+	}
+	if newEmail == user.Email {
+		return nil, ErrSameEmail
+	}
+	method, err := s.reauthenticate(ctx, user, req.Reauth)
+	if err != nil {
+		return nil, err
+	}
+	if taken, err := emailTaken(d, newEmail, user.ID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrEmailExists
+	}
+	token, hash := newToken()
+	now := time.Now()
+	ch := EmailChange{UserID: user.ID, NewEmail: newEmail, TokenHash: hash, ExpiresAt: now.Add(emailChangeTTL)}
+	err = d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&EmailChange{}).
+			Where("user_id = ? AND consumed_at IS NULL", user.ID).
+			Update("consumed_at", &now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&ch).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	link := strings.TrimRight(s.AppBaseURL, "/") + "/email-change/confirm?token=" + url.QueryEscape(token)
+	if err := s.Mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Confirm that this address should replace the email on your account:\n\n%s\n\nThe link expires at %s. If you did not request this, ignore this email.\n",
+			link, ch.ExpiresAt.UTC().Format(time.RFC1123)),
+	}); err != nil {
+		return nil, err
+	}
+	s.notify(ctx, user.Email, "Email change requested",
+		fmt.Sprintf("A request was made to change your account email to %s. Nothing changes unless the new address is confirmed.\n\nIf this wasn't you, change your password now.\n", maskEmail(newEmail)))
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventEmailChangeRequest, ActorID: &user.ID, UserID: &user.ID, Details: map[string]any{"new_email_hash": auditEmailHash(newEmail), "expires_at": ch.ExpiresAt, "confirmed_with": method}})
+	return &EmailChangeOutput{PendingEmail: newEmail, ExpiresAt: ch.ExpiresAt}, nil
+}
+
+// ConfirmEmailChange swaps the account email for the one the token was
+// issued for. Uniqueness is checked again at commit time, since the address
+// may have been registered after the request; the unique index is the final
+// guard against a concurrent registration.
+func (s *Service) ConfirmEmailChange(ctx context.Context, token string) error {
+	ctx, span := tracer.Start(ctx, "auth.Service.ConfirmEmailChange")
+	defer span.End()
+	if token == "" {
+		return ErrInvalidToken
+	}
+	sum := sha256.Sum256([]byte(token))
+	d := db.MustGet().WithContext(ctx)
+	var ch EmailChange
+	err := d.Where("token_hash = ? AND consumed_at IS NULL AND expires_at > ?", hex.EncodeToString(sum[:]), time.Now()).First(&ch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	var oldEmail string
+	err = d.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, ch.UserID)
+		if err != nil {
+			return err
+		}
+		oldEmail = user.Email
+		if taken, err := emailTaken(tx, ch.NewEmail, user.ID); err != nil {
+			return err
+		} else if taken {
+			return ErrEmailExists
+		}
+		if err := tx.Model(user).Updates(map[string]any{"email": ch.NewEmail, "email_verified_at": time.Now()}).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrEmailExists
+			}
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&ch).Update("consumed_at", &now).Error
+	})
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventEmailChange, ActorID: &ch.UserID, UserID: &ch.UserID, Details: map[string]string{"old_email_hash": auditEmailHash(oldEmail), "new_email_hash": auditEmailHash(ch.NewEmail)}})
+	s.notify(ctx, oldEmail, "Your account email was changed",
+		fmt.Sprintf("The email on your account was changed to %s. Sign in with the new address from now on.\n\nIf this wasn't you, contact support immediately.\n", maskEmail(ch.NewEmail)))
+	return nil
+}
+
+// notify sends an informational email; failures are logged, not returned,
+// because the action it reports has already happened.
+func (s *Service) notify(ctx context.Context, to, subject, body string) {
+	if err := s.Mailer.Send(ctx, mailer.Message{To: to, Subject: subject, Body: body}); err != nil {
+		slog.WarnContext(ctx, "notification email failed", "subject", subject, "err", err)
+	}
+}
+
+// emailTaken reports whether another account, including one inside its
+// deletion grace period, owns email, which must be normalized.
+func emailTaken(d *gorm.DB, email string, exceptID uint) (bool, error) {
+	var n int64
+	err := d.Unscoped().Model(&User{}).Where("email = ? AND id <> ?", email, exceptID).Count(&n).Error
+	return n > 0, err
+}
+
+// newToken returns a random URL-safe token and its SHA-256 hex digest.
+func newToken() (string, string) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	token := hex.EncodeToString(b)
+	sum := sha256.Sum256([]byte(token))
+	return token, hex.EncodeToString(sum[:])
+}
+
+// maskEmail shows enough of an address to recognise it: "so***@example.com".
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return "***"
+	}
+	local := email[:at]
+	if len(local) > 2 {
+		local = local[:2]
+	}
+	return local + "***" + email[at:]
+}
+
+// auditEmailHash stands in for an address in audit details: the audit log is
+// append-only and outlives an account purge. The key comes from JWT_SECRET so
+// the hash can't be reversed with a list of known addresses, yet attempts on
+// the same address still correlate.
+func auditEmailHash(email string) string {
+	return auditHash("audit-email", strings.ToLower(strings.TrimSpace(email)))
+}
+
+// auditValueHash is auditEmailHash for other personal values, such as
+// profile fields.
+func auditValueHash(v string) string {
+	return auditHash("audit-value", v)
+}
+
+func auditHash(purpose, v string) string {
+	m := hmac.New(sha256.New, DeriveKey(purpose))
+	m.Write([]byte(v))
+	return hex.EncodeToString(m.Sum(nil))[:32]
+}
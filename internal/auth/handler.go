@@ -1,24 +1,33 @@
 package auth
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+
+	"workshop-be/internal/avatar"
+	"workshop-be/internal/consent"
+	"workshop-be/internal/httpx"
+	"workshop-be/internal/metrics"
+	"workshop-be/internal/social"
 )
 
-type ErrorResponse struct {
-	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+func writeError(c *fiber.Ctx, status int, code, msg string) error {
+	return httpx.WriteError(c, status, code, msg)
 }
 
-func writeError(c *fiber.Ctx, status int, code, msg string) error {
-	resp := ErrorResponse{}
-	resp.Error.Code = code
-	resp.Error.Message = msg
-	return c.Status(status).JSON(resp)
+// writeWeakPassword reports a password policy failure with each broken rule in details.
+func writeWeakPassword(c *fiber.Ctx, wp *WeakPasswordError) error {
+	return httpx.WriteErrorDetails(c, http.StatusBadRequest, "WEAK_PASSWORD", "password does not meet the password policy", wp.Violations)
+}
+
+// writeBusy asks the client to retry when password hashing is saturated.
+func writeBusy(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, "1")
+	return writeError(c, http.StatusServiceUnavailable, "SERVER_BUSY", "server is busy; retry shortly")
 }
 
 type Handler struct {
@@ -35,28 +44,57 @@ func NewHandler(svc *Service) *Handler {
 // @Accept json
 // @Produce json
 // @Param request body RegisterInput true "register"
+// @Description With REGISTER_CONCEAL_EXISTING=true, new and taken emails both get 202 and the result is emailed.
 // @Success 201 {object} RegisterOutput
-// @Failure 400 {object} ErrorResponse
-// @Failure 409 {object} ErrorResponse
+// @Success 202 {object} RegisterAcceptedOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse
+// @Failure 503 {object} httpx.ErrorResponse "SERVER_BUSY"
 // @Router /api/v1/auth/register [post]
 func (h *Handler) Register(c *fiber.Ctx) error {
 	var in RegisterInput
 	if err := c.BodyParser(&in); err != nil {
+		metrics.RegistrationFailed("INVALID_PAYLOAD")
 		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
 	}
-	out, err := h.svc.Register(in)
+	out, err := h.svc.Register(c.UserContext(), in)
+	if err == ErrEmailExists && h.svc.ConcealExistingAccounts {
+		metrics.RegistrationFailed("EMAIL_EXISTS")
+		return c.Status(http.StatusAccepted).JSON(registrationAccepted)
+	}
 	if err != nil {
+		var wp *WeakPasswordError
+		if errors.As(err, &wp) {
+			metrics.RegistrationFailed("WEAK_PASSWORD")
+			return writeWeakPassword(c, wp)
+		}
 		switch err {
 		case ErrInvalidEmail:
+			metrics.RegistrationFailed("INVALID_EMAIL")
 			return writeError(c, http.StatusBadRequest, "INVALID_EMAIL", "invalid email")
-		case ErrPasswordTooShort:
-			return writeError(c, http.StatusBadRequest, "PASSWORD_TOO_SHORT", "password too short")
 		case ErrEmailExists:
+			metrics.RegistrationFailed("EMAIL_EXISTS")
 			return writeError(c, http.StatusConflict, "EMAIL_EXISTS", "email already registered")
+		case ErrInvalidReferralCode:
+			metrics.RegistrationFailed("INVALID_REFERRAL_CODE")
+			return writeError(c, http.StatusBadRequest, "INVALID_REFERRAL_CODE", "referral code not found")
+		case ErrBusy:
+			metrics.RegistrationFailed("SERVER_BUSY")
+			return writeBusy(c)
 		default:
+			if code, msg, ok := consent.GrantError(err); ok {
+				metrics.RegistrationFailed(code)
+				return writeError(c, http.StatusBadRequest, code, msg)
+			}
+			metrics.RegistrationFailed("INTERNAL_ERROR")
+			slog.ErrorContext(c.UserContext(), "register failed", "err", err)
 			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
 		}
 	}
+	metrics.RegistrationSucceeded()
+	if h.svc.ConcealExistingAccounts {
+		return c.Status(http.StatusAccepted).JSON(registrationAccepted)
+	}
 	return c.Status(http.StatusCreated).JSON(out)
 }
 
@@ -65,23 +103,55 @@ func (h *Handler) Register(c *fiber.Ctx) error {
 // @Tags Auth
 // @Accept json
 // @Produce json
+// @Description With LOGIN_STEP_UP on, a login from a new device or an impossible location answers 202 and emails a code; finish it at /api/v1/auth/login/verify.
 // @Param request body LoginInput true "login"
 // @Success 200 {object} LoginOutput
-// @Failure 401 {object} ErrorResponse
-// @Failure 400 {object} ErrorResponse
+// @Success 202 {object} StepUpOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse "CONSENT_REQUIRED"
+// @Failure 429 {object} httpx.ErrorResponse "RATE_LIMITED"
+// @Failure 503 {object} httpx.ErrorResponse "SERVER_BUSY"
 // @Router /api/v1/auth/login [post]
 func (h *Handler) Login(c *fiber.Ctx) error {
 	var in LoginInput
 	if err := c.BodyParser(&in); err != nil {
+		metrics.LoginFailed("INVALID_PAYLOAD")
 		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
 	}
-	out, err := h.svc.Login(in)
+	out, err := h.svc.Login(c.UserContext(), in)
 	if err != nil {
 		if err == ErrInvalidCredential {
+			metrics.LoginFailed("INVALID_CREDENTIALS")
 			return writeError(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "invalid credentials")
 		}
+		if err == ErrBusy {
+			metrics.LoginFailed("SERVER_BUSY")
+			return writeBusy(c)
+		}
+		var su *StepUpRequiredError
+		if errors.As(err, &su) {
+			metrics.LoginFailed("STEP_UP_REQUIRED")
+			return c.Status(http.StatusAccepted).JSON(su.Challenge)
+		}
+		if err == ErrRateLimited {
+			metrics.LoginFailed("RATE_LIMITED")
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(defaultMagicLinkWindow.Seconds())))
+			return writeError(c, http.StatusTooManyRequests, "RATE_LIMITED", "too many verification codes requested for this account; try again later")
+		}
+		if err == ErrConsentRequired {
+			metrics.LoginFailed("CONSENT_REQUIRED")
+			return writeError(c, http.StatusForbidden, "CONSENT_REQUIRED", "updated terms must be accepted; resend login with consents from /api/v1/legal/documents")
+		}
+		if code, msg, ok := consent.GrantError(err); ok {
+			metrics.LoginFailed(code)
+			return writeError(c, http.StatusBadRequest, code, msg)
+		}
+		metrics.LoginFailed("INTERNAL_ERROR")
+		slog.ErrorContext(c.UserContext(), "login failed", "err", err)
 		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
 	}
+	metrics.LoginSucceeded()
 	return c.JSON(out)
 }
 
@@ -91,7 +161,7 @@ func (h *Handler) Login(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Produce json
 // @Success 200 {object} ProfileResponse
-// @Failure 401 {object} ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
 // @Router /api/v1/profile [get]
 func (h *Handler) GetProfile(c *fiber.Ctx) error {
 	idStr := c.Locals("user_sub")
@@ -102,8 +172,11 @@ func (h *Handler) GetProfile(c *fiber.Ctx) error {
 	if err != nil {
 		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
 	}
-	prof, err := h.svc.GetProfile(uint(uid))
+	prof, err := h.svc.GetProfile(c.UserContext(), uint(uid))
 	if err != nil {
+		if err == ErrUserNotFound {
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		}
 		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
 	}
 	return c.JSON(prof)
@@ -117,8 +190,8 @@ func (h *Handler) GetProfile(c *fiber.Ctx) error {
 // @Produce json
 // @Param request body ProfileUpdateRequest true "profile update"
 // @Success 200 {object} ProfileResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 401 {object} ErrorResponse
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
 // @Router /api/v1/profile [put]
 func (h *Handler) UpdateProfile(c *fiber.Ctx) error {
 	idStr := c.Locals("user_sub")
@@ -133,13 +206,23 @@ func (h *Handler) UpdateProfile(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
 	}
-	prof, err := h.svc.UpdateProfile(uint(uid), req)
+	prof, err := h.svc.UpdateProfile(c.UserContext(), uint(uid), req)
 	if err != nil {
 		switch err {
 		case ErrInvalidPhone:
 			return writeError(c, http.StatusBadRequest, "INVALID_PHONE", "invalid phone")
 		case ErrInvalidName:
 			return writeError(c, http.StatusBadRequest, "INVALID_NAME", "invalid name")
+		case ErrInvalidBirthDate:
+			return writeError(c, http.StatusBadRequest, "INVALID_DATE_OF_BIRTH", "date_of_birth must be YYYY-MM-DD, not in the future")
+		case ErrInvalidGender:
+			return writeError(c, http.StatusBadRequest, "INVALID_GENDER", "gender must be male, female, other or prefer_not_to_say")
+		case ErrInvalidLanguage:
+			return writeError(c, http.StatusBadRequest, "INVALID_LANGUAGE", "language must be th or en")
+		case ErrInvalidAddress:
+			return writeError(c, http.StatusBadRequest, "INVALID_ADDRESS", "address needs a Thai province, district, subdistrict and 5-digit postcode")
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
 		default:
 			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
 		}
@@ -153,7 +236,7 @@ func (h *Handler) UpdateProfile(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Produce json
 // @Success 200 {object} MeOutput
-// @Failure 401 {object} ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
 // @Router /api/v1/auth/me [get]
 func (h *Handler) Me(c *fiber.Ctx) error {
 	idStr := c.Locals("user_sub")
@@ -164,16 +247,899 @@ func (h *Handler) Me(c *fiber.Ctx) error {
 	if err != nil {
 		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
 	}
-	out, err := h.svc.Me(uint(uid))
+	out, err := h.svc.Me(c.UserContext(), uint(uid))
 	if err != nil {
+		if err == ErrUserNotFound {
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		}
 		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
 	}
 	return c.JSON(out)
 }
 
+// ChangePassword godoc
+// @Summary Change password
+// @Description Requires the current password. Recorded in the audit log.
+// @Tags Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "password change"
+// @Success 204
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 503 {object} httpx.ErrorResponse "SERVER_BUSY"
+// @Router /api/v1/profile/password [put]
+func (h *Handler) ChangePassword(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	if err := h.svc.ChangePassword(c.UserContext(), uid, req); err != nil {
+		var wp *WeakPasswordError
+		if errors.As(err, &wp) {
+			return writeWeakPassword(c, wp)
+		}
+		switch err {
+		case ErrInvalidCurrentPassword:
+			return writeError(c, http.StatusBadRequest, "INVALID_CURRENT_PASSWORD", "current password is incorrect")
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		case ErrBusy:
+			return writeBusy(c)
+		default:
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// RequestEmailChange godoc
+// @Summary Request email change
+// @Description Requires the current password, or the step_up_token and code from POST /api/v1/profile/reauth for accounts without a password. Sends a confirmation link to the new address and a notice to the current one; the email changes only once the link is confirmed.
+// @Tags Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body EmailChangeRequest true "new email"
+// @Success 202 {object} EmailChangeOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse "INVALID_STEP_UP"
+// @Failure 403 {object} httpx.ErrorResponse "REAUTH_REQUIRED"
+// @Failure 409 {object} httpx.ErrorResponse
+// @Failure 503 {object} httpx.ErrorResponse "SERVER_BUSY"
+// @Router /api/v1/profile/email [post]
+func (h *Handler) RequestEmailChange(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var req EmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.RequestEmailChange(c.UserContext(), uid, req)
+	if err != nil {
+		switch err {
+		case ErrInvalidEmail:
+			return writeError(c, http.StatusBadRequest, "INVALID_EMAIL", "invalid email")
+		case ErrSameEmail:
+			return writeError(c, http.StatusBadRequest, "SAME_EMAIL", "new email equals current email")
+		case ErrInvalidCurrentPassword:
+			return writeError(c, http.StatusBadRequest, "INVALID_CURRENT_PASSWORD", "current password is incorrect")
+		case ErrInvalidStepUp:
+			return writeError(c, http.StatusUnauthorized, "INVALID_STEP_UP", "verification code is invalid or expired")
+		case ErrReauthRequired:
+			return writeError(c, http.StatusForbidden, "REAUTH_REQUIRED", "confirm with the code from POST /api/v1/profile/reauth")
+		case ErrEmailExists:
+			return writeError(c, http.StatusConflict, "EMAIL_EXISTS", "email already registered")
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		case ErrBusy:
+			return writeBusy(c)
+		default:
+			slog.ErrorContext(c.UserContext(), "email change request failed", "err", err)
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusAccepted).JSON(out)
+}
+
+// ConfirmEmailChange godoc
+// @Summary Confirm email change
+// @Description Token from the link sent to the new address. No bearer token needed. Existing access tokens keep working until they expire.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body EmailChangeConfirmRequest true "token"
+// @Success 204
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse
+// @Router /api/v1/auth/email-change/confirm [post]
+func (h *Handler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req EmailChangeConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	if err := h.svc.ConfirmEmailChange(c.UserContext(), req.Token); err != nil {
+		switch err {
+		case ErrInvalidToken:
+			return writeError(c, http.StatusBadRequest, "INVALID_TOKEN", "invalid or expired token")
+		case ErrEmailExists:
+			return writeError(c, http.StatusConflict, "EMAIL_EXISTS", "email already registered")
+		default:
+			slog.ErrorContext(c.UserContext(), "email change confirm failed", "err", err)
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// UploadAvatar godoc
+// @Summary Upload avatar
+// @Description Multipart upload (field "avatar"). JPEG, PNG or WebP detected from content, at most 2 MiB and 32-8000 px per side. The image is center-cropped, resized to 512/256/64 px JPEGs and stripped of metadata (EXIF).
+// @Tags Profile
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param avatar formData file true "image"
+// @Success 200 {object} ProfileResponse
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 413 {object} httpx.ErrorResponse
+// @Failure 415 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/avatar [put]
+func (h *Handler) UploadAvatar(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	fh, err := c.FormFile("avatar")
+	if err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "multipart field avatar is required")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	defer f.Close()
+	prof, err := h.svc.SetAvatar(c.UserContext(), uid, f)
+	if err != nil {
+		switch err {
+		case avatar.ErrTooLarge:
+			return writeError(c, http.StatusRequestEntityTooLarge, "AVATAR_TOO_LARGE", "image exceeds size limit")
+		case avatar.ErrUnsupportedType:
+			return writeError(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_IMAGE_TYPE", "image must be JPEG, PNG or WebP")
+		case avatar.ErrInvalidImage:
+			return writeError(c, http.StatusBadRequest, "INVALID_IMAGE", "image could not be decoded")
+		case avatar.ErrDimensions:
+			return writeError(c, http.StatusBadRequest, "INVALID_IMAGE_DIMENSIONS", "image sides must be between 32 and 8000 px")
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		default:
+			slog.ErrorContext(c.UserContext(), "avatar upload failed", "err", err)
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.JSON(prof)
+}
+
+// DeleteAccount godoc
+// @Summary Delete account
+// @Description Soft-deletes the account after confirmation with the current password, or with the step_up_token and code from POST /api/v1/profile/reauth for accounts without a password. Logging in before restorable_until restores it; afterwards personal data is anonymized.
+// @Tags Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body DeleteAccountRequest true "password or emailed code"
+// @Success 200 {object} DeleteAccountOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse "INVALID_STEP_UP"
+// @Failure 403 {object} httpx.ErrorResponse "REAUTH_REQUIRED"
+// @Failure 503 {object} httpx.ErrorResponse "SERVER_BUSY"
+// @Router /api/v1/profile [delete]
+func (h *Handler) DeleteAccount(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var req DeleteAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.DeleteAccount(c.UserContext(), uid, req)
+	if err != nil {
+		switch err {
+		case ErrInvalidCurrentPassword:
+			return writeError(c, http.StatusBadRequest, "INVALID_CURRENT_PASSWORD", "current password is incorrect")
+		case ErrInvalidStepUp:
+			return writeError(c, http.StatusUnauthorized, "INVALID_STEP_UP", "verification code is invalid or expired")
+		case ErrReauthRequired:
+			return writeError(c, http.StatusForbidden, "REAUTH_REQUIRED", "confirm with the code from POST /api/v1/profile/reauth")
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		case ErrBusy:
+			return writeBusy(c)
+		default:
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.JSON(out)
+}
+
+// StartReauth godoc
+// @Summary Email a confirmation code
+// @Description Sends a one-time code that confirms the next account deletion or email change, for accounts without a password (social, magic link or passkey only). Send the returned step_up_token and the code with that request. The code is valid for 10 minutes and allows 5 attempts; a new request replaces the outstanding code.
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 202 {object} StepUpOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 429 {object} httpx.ErrorResponse
+// @Failure 503 {object} httpx.ErrorResponse "SERVER_BUSY"
+// @Router /api/v1/profile/reauth [post]
+func (h *Handler) StartReauth(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.StartReauth(c.UserContext(), uid)
+	if err != nil {
+		switch err {
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		case ErrRateLimited:
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(defaultMagicLinkWindow.Seconds())))
+			return writeError(c, http.StatusTooManyRequests, "RATE_LIMITED", "too many verification codes requested for this account; try again later")
+		case ErrBusy:
+			return writeBusy(c)
+		default:
+			slog.ErrorContext(c.UserContext(), "reauth request failed", "err", err)
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusAccepted).JSON(out)
+}
+
 func RegisterRoutes(r fiber.Router, svc *Service) {
 	h := NewHandler(svc)
 	r.Post("/register", h.Register)
 	r.Post("/login", h.Login)
+	r.Post("/login/verify", h.VerifyStepUp)
+	r.Post("/email-change/confirm", h.ConfirmEmailChange)
 	r.Get("/me", h.Me)
+	r.Post("/magic-link", h.RequestMagicLink)
+	r.Post("/magic-link/verify", h.VerifyMagicLink)
+	r.Post("/passkey/options", h.PasskeyLoginOptions)
+	r.Post("/passkey/login", h.PasskeyLogin)
+	r.Get("/social/providers", h.SocialProviders)
+	r.Post("/social/:provider/start", h.StartSocial)
+	r.Get("/social/:provider/callback", h.SocialCallback)
+}
+
+// socialBindingCookie ties a social login to the browser that started it.
+const socialBindingCookie = "oauth_binding"
+
+// SocialProviders godoc
+// @Summary List social login providers
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} ProvidersOutput
+// @Router /api/v1/auth/social/providers [get]
+func (h *Handler) SocialProviders(c *fiber.Ctx) error {
+	return c.JSON(h.svc.SocialProviders())
+}
+
+// StartSocial godoc
+// @Summary Start social login
+// @Description Returns the provider's authorization URL (authorization code with PKCE) and sets a short-lived cookie binding the login to this browser. Send the consents a new account would need; they are applied at the callback.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param provider path string true "provider name, e.g. google, line, facebook"
+// @Param request body SocialStartInput false "consents"
+// @Success 200 {object} SocialStartOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Failure 502 {object} httpx.ErrorResponse
+// @Router /api/v1/auth/social/{provider}/start [post]
+func (h *Handler) StartSocial(c *fiber.Ctx) error {
+	var in SocialStartInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&in); err != nil {
+			return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+		}
+	}
+	binding := social.RandomString(32)
+	out, err := h.svc.StartSocial(c.UserContext(), c.Params("provider"), binding, in)
+	if err != nil {
+		if err == ErrUnknownProvider {
+			return writeError(c, http.StatusNotFound, "UNKNOWN_PROVIDER", "social login provider not enabled")
+		}
+		if code, msg, ok := consent.GrantError(err); ok {
+			return writeError(c, http.StatusBadRequest, code, msg)
+		}
+		slog.ErrorContext(c.UserContext(), "social login start failed", "err", err)
+		return writeError(c, http.StatusBadGateway, "SOCIAL_LOGIN_FAILED", "social login provider unavailable")
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     socialBindingCookie,
+		Value:    binding,
+		Path:     "/api/v1/auth/social",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return c.JSON(out)
+}
+
+// SocialCallback godoc
+// @Summary Complete social login
+// @Description Redirect target registered with the provider. Signs in the linked account, links the identity to the account with the same verified email, or creates a new account.
+// @Tags Auth
+// @Produce json
+// @Param provider path string true "provider name"
+// @Param code query string true "authorization code"
+// @Param state query string true "state from start"
+// @Success 200 {object} LoginOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse "INVALID_CREDENTIALS"
+// @Failure 403 {object} httpx.ErrorResponse "CONSENT_REQUIRED"
+// @Failure 404 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse "ACCOUNT_EXISTS"
+// @Failure 502 {object} httpx.ErrorResponse
+// @Router /api/v1/auth/social/{provider}/callback [get]
+func (h *Handler) SocialCallback(c *fiber.Ctx) error {
+	c.ClearCookie(socialBindingCookie)
+	if c.Query("error") != "" {
+		metrics.LoginFailed("SOCIAL_LOGIN_CANCELLED")
+		return writeError(c, http.StatusBadRequest, "SOCIAL_LOGIN_CANCELLED", "sign-in was cancelled at the provider")
+	}
+	out, err := h.svc.SocialCallback(c.UserContext(), c.Params("provider"), c.Query("code"), c.Query("state"), c.Cookies(socialBindingCookie))
+	if err != nil {
+		switch err {
+		case ErrUnknownProvider:
+			return writeError(c, http.StatusNotFound, "UNKNOWN_PROVIDER", "social login provider not enabled")
+		case ErrInvalidOAuthState:
+			metrics.LoginFailed("INVALID_OAUTH_STATE")
+			return writeError(c, http.StatusBadRequest, "INVALID_OAUTH_STATE", "sign-in link expired or was opened in another browser; start again")
+		case ErrSocialLogin:
+			metrics.LoginFailed("SOCIAL_LOGIN_FAILED")
+			return writeError(c, http.StatusBadGateway, "SOCIAL_LOGIN_FAILED", "provider rejected the sign-in")
+		case ErrSocialEmailRequired:
+			metrics.LoginFailed("EMAIL_REQUIRED")
+			return writeError(c, http.StatusBadRequest, "EMAIL_REQUIRED", "allow access to your email address to create an account")
+		case ErrInvalidCredential:
+			// The account was deleted and its grace period is over.
+			metrics.LoginFailed("INVALID_CREDENTIALS")
+			return writeError(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "invalid credentials")
+		case ErrSocialAccountExists:
+			metrics.LoginFailed("ACCOUNT_EXISTS")
+			return writeError(c, http.StatusConflict, "ACCOUNT_EXISTS", "an account with this email exists; sign in with an emailed sign-in link once to confirm the address, then this provider can be linked")
+		case ErrConsentRequired:
+			metrics.LoginFailed("CONSENT_REQUIRED")
+			return writeError(c, http.StatusForbidden, "CONSENT_REQUIRED", "updated terms must be accepted; start again with consents from /api/v1/legal/documents")
+		}
+		if code, msg, ok := consent.GrantError(err); ok {
+			metrics.LoginFailed(code)
+			return writeError(c, http.StatusBadRequest, code, msg)
+		}
+		metrics.LoginFailed("INTERNAL_ERROR")
+		slog.ErrorContext(c.UserContext(), "social login failed", "err", err)
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	metrics.LoginSucceeded()
+	return c.JSON(out)
+}
+
+// ListIdentities godoc
+// @Summary List linked social accounts
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} IdentitiesOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/identities [get]
+func (h *Handler) ListIdentities(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.Identities(c.UserContext(), uid)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		}
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// UnlinkIdentity godoc
+// @Summary Unlink a social account
+// @Description Refused with LAST_LOGIN_METHOD when the account has no password, other linked provider or passkey.
+// @Tags Profile
+// @Security BearerAuth
+// @Param provider path string true "provider name"
+// @Success 204
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/identities/{provider} [delete]
+func (h *Handler) UnlinkIdentity(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	if err := h.svc.UnlinkIdentity(c.UserContext(), uid, c.Params("provider")); err != nil {
+		switch err {
+		case ErrIdentityNotFound:
+			return writeError(c, http.StatusNotFound, "IDENTITY_NOT_FOUND", "no linked account for this provider")
+		case ErrLastLoginMethod:
+			return writeError(c, http.StatusConflict, "LAST_LOGIN_METHOD", "this is the only way to sign in to the account")
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		default:
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// RequestMagicLink godoc
+// @Summary Email a sign-in link
+// @Description Sends a single-use sign-in link to the account with this email. The response is the same whether or not the account exists. Each email can ask for a few links per 15 minutes.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body MagicLinkRequest true "email"
+// @Success 202 {object} MagicLinkOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 429 {object} httpx.ErrorResponse
+// @Router /api/v1/auth/magic-link [post]
+func (h *Handler) RequestMagicLink(c *fiber.Ctx) error {
+	var in MagicLinkRequest
+	if err := c.BodyParser(&in); err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.RequestMagicLink(c.UserContext(), in)
+	if err != nil {
+		switch err {
+		case ErrInvalidEmail:
+			return writeError(c, http.StatusBadRequest, "INVALID_EMAIL", "invalid email")
+		case ErrRateLimited:
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(defaultMagicLinkWindow.Seconds())))
+			return writeError(c, http.StatusTooManyRequests, "RATE_LIMITED", "too many sign-in links requested for this email; try again later")
+		default:
+			slog.ErrorContext(c.UserContext(), "magic link request failed", "err", err)
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusAccepted).JSON(out)
+}
+
+// VerifyMagicLink godoc
+// @Summary Sign in with a magic link
+// @Description Exchanges the token from the emailed link for an access token. A link works once.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body MagicLinkVerifyInput true "token"
+// @Success 200 {object} LoginOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse "CONSENT_REQUIRED"
+// @Router /api/v1/auth/magic-link/verify [post]
+func (h *Handler) VerifyMagicLink(c *fiber.Ctx) error {
+	var in MagicLinkVerifyInput
+	if err := c.BodyParser(&in); err != nil {
+		metrics.LoginFailed("INVALID_PAYLOAD")
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.VerifyMagicLink(c.UserContext(), in)
+	if err != nil {
+		switch err {
+		case ErrInvalidMagicLink:
+			metrics.LoginFailed("INVALID_MAGIC_LINK")
+			return writeError(c, http.StatusUnauthorized, "INVALID_MAGIC_LINK", "sign-in link is invalid, expired or already used")
+		case ErrConsentRequired:
+			metrics.LoginFailed("CONSENT_REQUIRED")
+			return writeError(c, http.StatusForbidden, "CONSENT_REQUIRED", "updated terms must be accepted; resend with consents from /api/v1/legal/documents")
+		}
+		if code, msg, ok := consent.GrantError(err); ok {
+			metrics.LoginFailed(code)
+			return writeError(c, http.StatusBadRequest, code, msg)
+		}
+		metrics.LoginFailed("INTERNAL_ERROR")
+		slog.ErrorContext(c.UserContext(), "magic link login failed", "err", err)
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	metrics.LoginSucceeded()
+	return c.JSON(out)
+}
+
+// VerifyStepUp godoc
+// @Summary Finish a login with the emailed code
+// @Description Completes a login that answered 202. The code is valid for 10 minutes and allows 5 attempts. Consents sent with the login are saved now.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body StepUpVerifyInput true "step-up token and code"
+// @Success 200 {object} LoginOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse "INVALID_STEP_UP"
+// @Failure 403 {object} httpx.ErrorResponse "CONSENT_REQUIRED"
+// @Router /api/v1/auth/login/verify [post]
+func (h *Handler) VerifyStepUp(c *fiber.Ctx) error {
+	var in StepUpVerifyInput
+	if err := c.BodyParser(&in); err != nil {
+		metrics.LoginFailed("INVALID_PAYLOAD")
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.VerifyStepUp(c.UserContext(), in)
+	if err != nil {
+		switch err {
+		case ErrInvalidStepUp:
+			metrics.LoginFailed("INVALID_STEP_UP")
+			return writeError(c, http.StatusUnauthorized, "INVALID_STEP_UP", "verification code is invalid or expired")
+		case ErrConsentRequired:
+			metrics.LoginFailed("CONSENT_REQUIRED")
+			return writeError(c, http.StatusForbidden, "CONSENT_REQUIRED", "updated terms must be accepted; log in again with consents from /api/v1/legal/documents")
+		}
+		if code, msg, ok := consent.GrantError(err); ok {
+			metrics.LoginFailed(code)
+			return writeError(c, http.StatusBadRequest, code, msg)
+		}
+		metrics.LoginFailed("INTERNAL_ERROR")
+		slog.ErrorContext(c.UserContext(), "step-up login failed", "err", err)
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	metrics.LoginSucceeded()
+	return c.JSON(out)
+}
+
+// PasskeyLoginOptions godoc
+// @Summary Start a passkey login
+// @Description Returns WebAuthn request options for navigator.credentials.get(). No account is named; the device offers its passkeys for this site. The challenge is valid for 5 minutes and works once.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} webauthn.RequestOptions
+// @Router /api/v1/auth/passkey/options [post]
+func (h *Handler) PasskeyLoginOptions(c *fiber.Ctx) error {
+	out, err := h.svc.PasskeyRequestOptions(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "passkey options failed", "err", err)
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// PasskeyLogin godoc
+// @Summary Sign in with a passkey
+// @Description Verifies the assertion from navigator.credentials.get() and returns an access token.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyLoginInput true "credential"
+// @Success 200 {object} LoginOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse "CONSENT_REQUIRED"
+// @Router /api/v1/auth/passkey/login [post]
+func (h *Handler) PasskeyLogin(c *fiber.Ctx) error {
+	var in PasskeyLoginInput
+	if err := c.BodyParser(&in); err != nil {
+		metrics.LoginFailed("INVALID_PAYLOAD")
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.LoginWithPasskey(c.UserContext(), in)
+	if err != nil {
+		switch err {
+		case ErrInvalidPasskey:
+			metrics.LoginFailed("INVALID_PASSKEY")
+			return writeError(c, http.StatusUnauthorized, "INVALID_PASSKEY", "passkey could not be verified; start again")
+		case ErrConsentRequired:
+			metrics.LoginFailed("CONSENT_REQUIRED")
+			return writeError(c, http.StatusForbidden, "CONSENT_REQUIRED", "updated terms must be accepted; sign in again with consents from /api/v1/legal/documents")
+		}
+		if code, msg, ok := consent.GrantError(err); ok {
+			metrics.LoginFailed(code)
+			return writeError(c, http.StatusBadRequest, code, msg)
+		}
+		metrics.LoginFailed("INTERNAL_ERROR")
+		slog.ErrorContext(c.UserContext(), "passkey login failed", "err", err)
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	metrics.LoginSucceeded()
+	return c.JSON(out)
+}
+
+// PasskeyCreationOptions godoc
+// @Summary Start registering a passkey
+// @Description Returns WebAuthn creation options for navigator.credentials.create(). The challenge is valid for 5 minutes and works once.
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} webauthn.CreationOptions
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/passkeys/options [post]
+func (h *Handler) PasskeyCreationOptions(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.PasskeyCreationOptions(c.UserContext(), uid)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		}
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// RegisterPasskey godoc
+// @Summary Register a passkey
+// @Description Verifies the attestation from navigator.credentials.create() and stores the passkey.
+// @Tags Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyRegisterInput true "credential"
+// @Success 201 {object} Passkey
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/passkeys [post]
+func (h *Handler) RegisterPasskey(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in PasskeyRegisterInput
+	if err := c.BodyParser(&in); err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.RegisterPasskey(c.UserContext(), uid, in)
+	if err != nil {
+		switch err {
+		case ErrInvalidPasskeyName:
+			return writeError(c, http.StatusBadRequest, "INVALID_PASSKEY_NAME", "name must be at most 100 characters")
+		case ErrInvalidPasskey:
+			return writeError(c, http.StatusBadRequest, "INVALID_PASSKEY", "passkey could not be verified; start again")
+		case ErrPasskeyExists:
+			return writeError(c, http.StatusConflict, "PASSKEY_EXISTS", "this passkey is already registered")
+		default:
+			slog.ErrorContext(c.UserContext(), "passkey registration failed", "err", err)
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusCreated).JSON(out)
+}
+
+// ListPasskeys godoc
+// @Summary List registered passkeys
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} PasskeysOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/passkeys [get]
+func (h *Handler) ListPasskeys(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.Passkeys(c.UserContext(), uid)
+	if err != nil {
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// DeletePasskey godoc
+// @Summary Remove a passkey
+// @Description Refused with LAST_LOGIN_METHOD when it is the only way to sign in to the account.
+// @Tags Profile
+// @Security BearerAuth
+// @Param id path int true "passkey id"
+// @Success 204
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/passkeys/{id} [delete]
+func (h *Handler) DeletePasskey(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return writeError(c, http.StatusNotFound, "PASSKEY_NOT_FOUND", "passkey not found")
+	}
+	if err := h.svc.DeletePasskey(c.UserContext(), uid, uint(id)); err != nil {
+		switch err {
+		case ErrPasskeyNotFound:
+			return writeError(c, http.StatusNotFound, "PASSKEY_NOT_FOUND", "passkey not found")
+		case ErrLastLoginMethod:
+			return writeError(c, http.StatusConflict, "LAST_LOGIN_METHOD", "this is the only way to sign in to the account")
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		default:
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Issues a key for machine clients acting as the caller. The key is returned only in this response; send it in the X-API-Key header. Scopes must be allowed for the caller's role: cards:verify and points:earn for staff and admins, admin for admins.
+// @Tags Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body APIKeyInput true "key"
+// @Success 201 {object} APIKeyCreated
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/api-keys [post]
+func (h *Handler) CreateAPIKey(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in APIKeyInput
+	if err := c.BodyParser(&in); err != nil {
+		return writeError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.CreateAPIKey(c.UserContext(), uid, in)
+	if err != nil {
+		switch err {
+		case ErrInvalidAPIKeyName:
+			return writeError(c, http.StatusBadRequest, "INVALID_API_KEY_NAME", "name is required and at most 100 characters")
+		case ErrInvalidAPIKeyScope:
+			return writeError(c, http.StatusBadRequest, "INVALID_API_KEY_SCOPE", "at least one scope allowed for your role is required")
+		case ErrInvalidAPIKeyExpiry:
+			return writeError(c, http.StatusBadRequest, "INVALID_API_KEY_EXPIRY", "expires_in_days must be between 1 and 365")
+		case ErrUserNotFound:
+			return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "account not found")
+		default:
+			slog.ErrorContext(c.UserContext(), "create api key failed", "err", err)
+			return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusCreated).JSON(out)
+}
+
+// ListAPIKeys godoc
+// @Summary List your API keys
+// @Description Includes expired and revoked keys. The secret part is never shown again.
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} APIKeysOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/api-keys [get]
+func (h *Handler) ListAPIKeys(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.APIKeys(c.UserContext(), uid)
+	if err != nil {
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// ListLogins godoc
+// @Summary List recent sign-ins
+// @Description The latest 50 sign-in attempts on your account, newest first, with device, approximate location and whether the login was flagged.
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} LoginHistoryOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/logins [get]
+func (h *Handler) ListLogins(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.LoginHistory(c.UserContext(), uid, loginHistoryLimit)
+	if err != nil {
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke one of your API keys
+// @Tags Profile
+// @Security BearerAuth
+// @Param id path int true "api key id"
+// @Success 204
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return writeError(c, http.StatusNotFound, "API_KEY_NOT_FOUND", "api key not found")
+	}
+	return h.revokeResult(c, h.svc.RevokeAPIKey(c.UserContext(), uid, uint(id)))
+}
+
+// AdminListAPIKeys godoc
+// @Summary List API keys of all users
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Produce json
+// @Param owner_id query int false "only keys of this user"
+// @Success 200 {object} APIKeysOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/api-keys [get]
+func (h *Handler) AdminListAPIKeys(c *fiber.Ctx) error {
+	owner := c.QueryInt("owner_id")
+	if owner < 0 {
+		owner = 0
+	}
+	out, err := h.svc.APIKeys(c.UserContext(), uint(owner))
+	if err != nil {
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// AdminRevokeAPIKey godoc
+// @Summary Revoke any API key
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param id path int true "api key id"
+// @Success 204
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/api-keys/{id} [delete]
+func (h *Handler) AdminRevokeAPIKey(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return writeError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return writeError(c, http.StatusNotFound, "API_KEY_NOT_FOUND", "api key not found")
+	}
+	return h.revokeResult(c, h.svc.AdminRevokeAPIKey(c.UserContext(), uid, uint(id)))
+}
+
+func (h *Handler) revokeResult(c *fiber.Ctx, err error) error {
+	switch err {
+	case nil:
+		return c.SendStatus(http.StatusNoContent)
+	case ErrAPIKeyNotFound:
+		return writeError(c, http.StatusNotFound, "API_KEY_NOT_FOUND", "api key not found")
+	default:
+		return writeError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+}
+
+// RegisterAdminRoutes mounts API key administration on an admin-only group.
+func RegisterAdminRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	r.Get("/api-keys", h.AdminListAPIKeys)
+	r.Delete("/api-keys/:id", h.AdminRevokeAPIKey)
 }
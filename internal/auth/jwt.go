@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"time"
@@ -11,9 +14,16 @@ import (
 
 var issuer = "workshop-be"
 
+// insecureDevSecret is the fallback main.go installs when JWT_SECRET is unset in dev.
+const insecureDevSecret = "insecure-dev-secret-change-me"
+
+// minProdSecretLen is the shortest HS256 secret accepted when APP_ENV=prod.
+const minProdSecretLen = 32
+
 // Claims wraps jwt.RegisteredClaims with custom fields.
 type Claims struct {
 	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -25,9 +35,36 @@ func tokenSecret() []byte {
 	return []byte(secret)
 }
 
-func GenerateToken(userID uint, email string, ttl time.Duration) (string, error) {
+// CheckKeyMaterial reports whether the signing secret is usable. In prod the
+// dev fallback and short secrets are rejected.
+func CheckKeyMaterial() error {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return errors.New("JWT_SECRET not set")
+	}
+	if os.Getenv("APP_ENV") == "prod" {
+		if secret == insecureDevSecret {
+			return errors.New("JWT_SECRET uses the insecure dev fallback")
+		}
+		if len(secret) < minProdSecretLen {
+			return fmt.Errorf("JWT_SECRET shorter than %d bytes", minProdSecretLen)
+		}
+	}
+	return nil
+}
+
+// DeriveKey returns a purpose-specific HMAC key derived from JWT_SECRET so other
+// signed artifacts (download links, card payloads) never reuse the token key.
+func DeriveKey(purpose string) []byte {
+	m := hmac.New(sha256.New, tokenSecret())
+	m.Write([]byte(purpose))
+	return m.Sum(nil)
+}
+
+func GenerateToken(userID uint, email, role string, ttl time.Duration) (string, error) {
 	claims := Claims{
 		Email: email,
+		Role:  role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   strconv.FormatUint(uint64(userID), 10),
 			Issuer:    issuer,
@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/clientinfo"
+	"workshop-be/internal/consent"
+	"workshop-be/internal/db"
+	"workshop-be/internal/geoip"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidStepUp covers unknown, expired, used and over-guessed step-up
+// challenges and wrong codes.
+var ErrInvalidStepUp = errors.New("invalid or expired verification code")
+
+// Login outcomes in the login history.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeStepUp is a correct password held back for a step-up code.
+	OutcomeStepUp = "step_up"
+)
+
+const (
+	loginHistoryLimit = 50
+	stepUpTTL         = 10 * time.Minute
+	stepUpMaxAttempts = 5
+	// maxTravelKmh is about airliner speed; two logins further apart than
+	// that allows are impossible travel. Below travelMinKm coarse GeoIP
+	// positions are too noisy to judge.
+	maxTravelKmh = 1000
+	travelMinKm  = 300
+)
+
+// LoginEvent is one sign-in attempt on an existing account.
+type LoginEvent struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	UserID uint `json:"-" gorm:"index;not null"`
+	// Method is password, magic_link, passkey or a social provider name.
+	Method    string `json:"method" gorm:"size:32" example:"password"`
+	Outcome   string `json:"outcome" gorm:"size:16" example:"success"`
+	Reason    string `json:"reason,omitempty" gorm:"size:32" example:"wrong_password"`
+	IP        string `json:"ip" gorm:"size:64"`
+	UserAgent string `json:"user_agent" gorm:"size:255"`
+	// DeviceHash is the SHA-256 of the X-Device-ID header; empty when the
+	// client sent none.
+	DeviceHash     string `json:"-" gorm:"size:64"`
+	geoip.Location `gorm:"embedded"`
+	NewDevice      bool `json:"new_device"`
+	// ImpossibleTravel means the previous login was too far away to reach
+	// in the time since.
+	ImpossibleTravel bool `json:"impossible_travel"`
+	// StepUp means the login was confirmed with an emailed code.
+	StepUp    bool      `json:"step_up"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+func (LoginEvent) TableName() string { return "login_history" }
+
+type LoginHistoryOutput struct {
+	Logins []LoginEvent `json:"logins"`
+}
+
+// Step-up challenge purposes: a code only works for what it was sent for.
+const (
+	// StepUpLogin holds back a risky password login.
+	StepUpLogin = "login"
+	// StepUpReauth confirms a sensitive change by a signed-in user, e.g. one
+	// without a password, see StartReauth.
+	StepUpReauth = "reauth"
+)
+
+// StepUpChallenge holds back a risky password login, or confirms a
+// sensitive change, until the code emailed to the account is entered. Only
+// hashes of the token and code are stored.
+type StepUpChallenge struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	Purpose   string `gorm:"size:16;not null;default:login"`
+	TokenHash string `gorm:"size:64;uniqueIndex;not null"`
+	CodeHash  string `gorm:"size:64;not null"`
+	Attempts  int    `gorm:"not null;default:0"`
+	// Consents are the grants sent with the held-back login, saved only once
+	// the code is verified.
+	Consents   []consent.Grant `gorm:"serializer:json"`
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (StepUpChallenge) TableName() string { return "login_challenges" }
+
+// StepUpOutput is Login's answer when a code was emailed.
+type StepUpOutput struct {
+	StepUpToken string    `json:"step_up_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Message     string    `json:"message" example:"a verification code was sent to your email"`
+}
+
+type StepUpVerifyInput struct {
+	StepUpToken string `json:"step_up_token"`
+	Code        string `json:"code" example:"042519"`
+}
+
+// StepUpRequiredError is returned by Login for a risky sign-in when
+// LoginStepUp is set; finish it with VerifyStepUp.
+type StepUpRequiredError struct {
+	Challenge *StepUpOutput
+}
+
+func (e *StepUpRequiredError) Error() string { return "step-up verification required" }
+
+// loginRisk describes the client of a sign-in and what is unusual about it.
+type loginRisk struct {
+	client           clientinfo.Info
+	device           string
+	loc              geoip.Location
+	newDevice        bool
+	impossibleTravel bool
+	steppedUp        bool
+}
+
+func (r *loginRisk) risky() bool { return r.newDevice || r.impossibleTravel }
+
+// clientRisk identifies the caller without comparing it to history.
+func (s *Service) clientRisk(ctx context.Context) *loginRisk {
+	info := clientinfo.From(ctx)
+	r := &loginRisk{client: info}
+	if info.DeviceID != "" {
+		r.device = sha256Hex("device:" + info.DeviceID)
+	}
+	r.loc, _ = s.GeoIP.Lookup(info.IP)
+	return r
+}
+
+// assessLogin compares the caller with the account's earlier successful
+// logins. An account's first recorded login is the baseline and is never
+// flagged. A client without a device id is an unknown device: a user agent
+// is shared by too many devices to recognise one.
+func (s *Service) assessLogin(ctx context.Context, userID uint) *loginRisk {
+	r := s.clientRisk(ctx)
+	d := db.MustGet().WithContext(ctx)
+	q := func() *gorm.DB {
+		return d.Model(&LoginEvent{}).Where("user_id = ? AND outcome = ?", userID, OutcomeSuccess)
+	}
+	var seen, known int64
+	if q().Count(&seen).Error != nil || seen == 0 {
+		return r
+	}
+	if r.device == "" {
+		r.newDevice = true
+	} else if q().Where("device_hash = ?", r.device).Count(&known).Error == nil {
+		r.newDevice = known == 0
+	}
+	var last LoginEvent
+	if r.loc.Latitude != nil && q().Where("latitude IS NOT NULL").Order("id DESC").Take(&last).Error == nil {
+		if km, ok := geoip.Distance(last.Location, r.loc); ok && km > travelMinKm {
+			hours := math.Max(time.Since(last.CreatedAt).Hours(), 1.0/60)
+			r.impossibleTravel = km/hours > maxTravelKmh
+		}
+	}
+	return r
+}
+
+// recordLogin appends to the login history. Failures are logged only; the
+// audit log still has the attempt.
+func (s *Service) recordLogin(ctx context.Context, userID uint, method, outcome, reason string, r *loginRisk) {
+	if method == "" {
+		method = "password"
+	}
+	ev := LoginEvent{
+		UserID:           userID,
+		Method:           method,
+		Outcome:          outcome,
+		Reason:           reason,
+		IP:               r.client.IP,
+		UserAgent:        r.client.UserAgent,
+		DeviceHash:       r.device,
+		Location:         r.loc,
+		NewDevice:        r.newDevice,
+		ImpossibleTravel: r.impossibleTravel,
+		StepUp:           r.steppedUp,
+	}
+	if err := db.MustGet().WithContext(ctx).Create(&ev).Error; err != nil {
+		slog.WarnContext(ctx, "record login history failed", "user_id", userID, "err", err)
+	}
+}
+
+// LoginHistory lists the account's sign-in attempts, newest first; limit 0
+// returns all of them.
+func (s *Service) LoginHistory(ctx context.Context, userID uint, limit int) (*LoginHistoryOutput, error) {
+	q := db.MustGet().WithContext(ctx).Where("user_id = ?", userID).Order("id DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	out := &LoginHistoryOutput{Logins: []LoginEvent{}}
+	if err := q.Find(&out.Logins).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// notifyRiskyLogin tells the owner about a completed sign-in from a new
+// device or an implausible location.
+func (s *Service) notifyRiskyLogin(ctx context.Context, user *User, r *loginRisk) {
+	s.notify(ctx, user.Email, "New sign-in to your account",
+		fmt.Sprintf("Your account was just signed in %s.\n\n%s\nIf this was you, you can ignore this email. If not, change your password now.\n",
+			r.why(), r.describe(time.Now())))
+}
+
+func (r *loginRisk) why() string {
+	switch {
+	case r.newDevice && r.impossibleTravel:
+		return "from a new device, far from where you last signed in"
+	case r.impossibleTravel:
+		return "from a location far from where you last signed in"
+	}
+	return "from a new device"
+}
+
+func (r *loginRisk) describe(at time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Time: %s\n", at.UTC().Format(time.RFC1123))
+	if r.client.UserAgent != "" {
+		fmt.Fprintf(&b, "Device: %s\n", r.client.UserAgent)
+	}
+	if place := strings.Trim(r.loc.City+", "+r.loc.Country, ", "); place != "" {
+		fmt.Fprintf(&b, "Location (approximate): %s\n", place)
+	}
+	if r.client.IP != "" {
+		fmt.Fprintf(&b, "IP address: %s\n", r.client.IP)
+	}
+	return b.String()
+}
+
+// startStepUp emails a one-time code for a risky password login and
+// returns the StepUpRequiredError carrying the challenge token. A new
+// challenge replaces the account's outstanding ones. grants are kept with
+// the challenge until VerifyStepUp.
+func (s *Service) startStepUp(ctx context.Context, user *User, r *loginRisk, grants []consent.Grant) error {
+	ch, token, code, err := s.newStepUpChallenge(ctx, user, StepUpLogin, grants)
+	if err != nil {
+		return err
+	}
+	s.notify(ctx, user.Email, "Your sign-in verification code",
+		fmt.Sprintf("Someone entered your password to sign in %s.\n\n%s\nIf this was you, enter this code to finish signing in: %s\nIt expires at %s.\n\nIf this wasn't you, do not share the code and change your password now.\n",
+			r.why(), r.describe(ch.CreatedAt), code, ch.ExpiresAt.UTC().Format(time.RFC1123)))
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginStepUp, ActorID: &user.ID, UserID: &user.ID, Details: map[string]any{"new_device": r.newDevice, "impossible_travel": r.impossibleTravel}})
+	s.recordLogin(ctx, user.ID, "password", OutcomeStepUp, "", r)
+	return &StepUpRequiredError{Challenge: &StepUpOutput{StepUpToken: token, ExpiresAt: ch.ExpiresAt, Message: "a verification code was sent to your email"}}
+}
+
+// newStepUpChallenge stores a challenge for purpose, replacing the account's
+// outstanding ones for the same purpose, and returns it with its token and
+// code for the caller to send. Codes are rate limited per account.
+func (s *Service) newStepUpChallenge(ctx context.Context, user *User, purpose string, grants []consent.Grant) (*StepUpChallenge, string, string, error) {
+	if !s.stepUpLimiter.allow(user.Email, time.Now()) {
+		return nil, "", "", ErrRateLimited
+	}
+	token, tokenHash := newToken()
+	code := newStepUpCode()
+	now := time.Now()
+	ch := StepUpChallenge{UserID: user.ID, Purpose: purpose, TokenHash: tokenHash, CodeHash: sha256Hex(token + ":" + code), Consents: grants, ExpiresAt: now.Add(stepUpTTL), CreatedAt: now}
+	err := db.MustGet().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&StepUpChallenge{}).
+			Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", user.ID, purpose).
+			Update("consumed_at", &now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&ch).Error
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+	return &ch, token, code, nil
+}
+
+// errWrongStepUpCode is consumeStepUp's answer to a wrong code for a live
+// challenge, so callers can record the failure; they return ErrInvalidStepUp.
+var errWrongStepUpCode = errors.New("wrong step-up code")
+
+// consumeStepUp uses up the live challenge for purpose identified by
+// token when code matches. Each challenge allows stepUpMaxAttempts guesses.
+// A wrong code returns the challenge with errWrongStepUpCode.
+func (s *Service) consumeStepUp(ctx context.Context, purpose, token, code string) (*StepUpChallenge, error) {
+	if token == "" || code == "" {
+		return nil, ErrInvalidStepUp
+	}
+	d := db.MustGet().WithContext(ctx)
+	var ch StepUpChallenge
+	err := d.Where("token_hash = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", sha256Hex(token), purpose, time.Now()).First(&ch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidStepUp
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Count the guess before checking it so parallel requests can't exceed the limit.
+	res := d.Model(&StepUpChallenge{}).
+		Where("id = ? AND attempts < ?", ch.ID, stepUpMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidStepUp
+	}
+	if subtle.ConstantTimeCompare([]byte(sha256Hex(token+":"+strings.TrimSpace(code))), []byte(ch.CodeHash)) != 1 {
+		return &ch, errWrongStepUpCode
+	}
+	now := time.Now()
+	res = d.Model(&StepUpChallenge{}).Where("id = ? AND consumed_at IS NULL", ch.ID).Update("consumed_at", &now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidStepUp
+	}
+	return &ch, nil
+}
+
+// VerifyStepUp finishes a login held back by Login with the emailed code.
+func (s *Service) VerifyStepUp(ctx context.Context, in StepUpVerifyInput) (*LoginOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.VerifyStepUp")
+	defer span.End()
+	ch, err := s.consumeStepUp(ctx, StepUpLogin, in.StepUpToken, in.Code)
+	if err == errWrongStepUpCode {
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, UserID: &ch.UserID, Details: map[string]string{"reason": "wrong_step_up_code"}})
+		s.recordLogin(ctx, ch.UserID, "password", OutcomeFailure, "wrong_step_up_code", s.clientRisk(ctx))
+		return nil, ErrInvalidStepUp
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	if err := db.MustGet().WithContext(ctx).Unscoped().Where("purged_at IS NULL").First(&user, ch.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidStepUp
+		}
+		return nil, err
+	}
+	// Documents published since Login are checked again.
+	if err := s.acceptOutstanding(ctx, &user, ch.Consents); err != nil {
+		return nil, err
+	}
+	if user.DeletedAt.Valid {
+		if err := s.restore(ctx, &user); err != nil {
+			if err == ErrInvalidCredential {
+				return nil, ErrInvalidStepUp
+			}
+			return nil, err
+		}
+	}
+	r := s.assessLogin(ctx, user.ID)
+	r.steppedUp = true
+	return s.completeLogin(ctx, &user, "", r)
+}
+
+func newStepUpCode() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%06d", n.Int64())
+}
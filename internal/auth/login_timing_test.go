@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/db"
+)
+
+// TestLoginTimingUnknownEmail checks that an unknown email costs what a wrong
+// password does, both for an account still on a legacy bcrypt-12 hash and
+// for one on the current argon2id settings, and that login history only
+// gets rows for the existing accounts.
+func TestLoginTimingUnknownEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test")
+	}
+	t.Setenv("JWT_SECRET", "test secret")
+	ctx := context.Background()
+	db.Init(filepath.Join(t.TempDir(), "app.db"), &User{}, &audit.Event{}, &LoginEvent{})
+	s := NewService()
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("legacy password 1"), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := s.Hasher.Hash(ctx, "current password 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := db.MustGet()
+	for _, u := range []User{
+		{Email: "legacy@example.com", PasswordHash: string(legacy)},
+		{Email: "current@example.com", PasswordHash: current},
+	} {
+		if err := d.Create(&u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CalibratePasswordDummy(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	emails := []string{"nobody@example.com", "legacy@example.com", "current@example.com"}
+	took := map[string][]time.Duration{}
+	for range 5 {
+		for _, email := range emails {
+			start := time.Now()
+			_, err := s.Login(ctx, LoginInput{Email: email, Password: "wrong password"})
+			took[email] = append(took[email], time.Since(start))
+			if err != ErrInvalidCredential {
+				t.Fatalf("Login(%s): err = %v", email, err)
+			}
+		}
+	}
+	median := func(email string) time.Duration {
+		ds := slices.Clone(took[email])
+		slices.Sort(ds)
+		return ds[len(ds)/2]
+	}
+	unknown := median("nobody@example.com")
+	for _, email := range emails[1:] {
+		known := median(email)
+		diff := known - unknown
+		if diff < 0 {
+			diff = -diff
+		}
+		// bcrypt-12 is several times argon2id's cost, so an unpadded path
+		// misses this by far more than scheduling noise.
+		if diff > max(known, unknown)/5 {
+			t.Errorf("wrong password for %s took %v, unknown email %v", email, known, unknown)
+		}
+	}
+
+	var rows []LoginEvent
+	if err := d.Order("id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	per := map[uint]int{}
+	for _, r := range rows {
+		per[r.UserID]++
+	}
+	if len(per) != 2 || per[1] != 5 || per[2] != 5 {
+		t.Errorf("login history rows per user = %v, want 5 for each account and none without one", per)
+	}
+}
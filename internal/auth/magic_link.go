@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/consent"
+	"workshop-be/internal/db"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRateLimited is returned when an email asked for too many links; it
+	// applies to unknown addresses too, so it reveals nothing.
+	ErrRateLimited = errors.New("too many requests")
+	// ErrInvalidMagicLink covers unknown, expired and already used links.
+	ErrInvalidMagicLink = errors.New("invalid or expired magic link")
+)
+
+const (
+	defaultMagicLinkTTL    = 15 * time.Minute
+	defaultMagicLinkLimit  = 3
+	defaultMagicLinkWindow = 15 * time.Minute
+)
+
+// MagicLink is a single-use sign-in link. As with EmailChange only the
+// SHA-256 of the token is stored.
+type MagicLink struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index;not null"`
+	TokenHash  string `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (MagicLink) TableName() string { return "magic_links" }
+
+type MagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+type MagicLinkOutput struct {
+	Message string `json:"message" example:"if an account exists for this email, a sign-in link has been sent"`
+}
+
+type MagicLinkVerifyInput struct {
+	Token string `json:"token"`
+	// Consents re-accepts documents published since the last login; only
+	// needed after a CONSENT_REQUIRED response.
+	Consents []consent.Grant `json:"consents,omitempty"`
+}
+
+// magicLinkSent is the answer whether or not the email has an account.
+var magicLinkSent = &MagicLinkOutput{Message: "if an account exists for this email, a sign-in link has been sent"}
+
+// RequestMagicLink emails a sign-in link to the account with this email. The
+// response is the same for unknown addresses, as Login's is, and takes the
+// same time: the link is stored and mailed after the response, since an SMTP
+// round trip would otherwise reveal that the account exists. A new link
+// replaces any unused earlier one.
+func (s *Service) RequestMagicLink(ctx context.Context, in MagicLinkRequest) (*MagicLinkOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.RequestMagicLink")
+	defer span.End()
+	email := normalizeEmail(in.Email)
+	if !emailRegex.MatchString(email) {
+		return nil, ErrInvalidEmail
+	}
+	if !s.magicLimiter.allow(email, time.Now()) {
+		return nil, ErrRateLimited
+	}
+	d := db.MustGet().WithContext(ctx)
+	var user User
+	// Unscoped: as with Login, signing in restores an account inside its grace period.
+	err := d.Unscoped().Where("email = ? AND purged_at IS NULL", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return magicLinkSent, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		s.sendMagicLink(context.WithoutCancel(ctx), &user)
+	}()
+	return magicLinkSent, nil
+}
+
+// sendMagicLink stores a new link for user and mails it. It runs after the
+// response has gone out, so failures are only logged.
+func (s *Service) sendMagicLink(ctx context.Context, user *User) {
+	token, hash := newToken()
+	now := time.Now()
+	link := MagicLink{UserID: user.ID, TokenHash: hash, ExpiresAt: now.Add(s.MagicLinkTTL)}
+	err := db.MustGet().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&MagicLink{}).
+			Where("user_id = ? AND consumed_at IS NULL", user.ID).
+			Update("consumed_at", &now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&link).Error
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "store magic link failed", "user_id", user.ID, "err", err)
+		return
+	}
+	u := strings.TrimRight(s.AppBaseURL, "/") + "/login/magic?token=" + url.QueryEscape(token)
+	s.notify(ctx, user.Email, "Your sign-in link",
+		fmt.Sprintf("Use this link to sign in:\n\n%s\n\nIt works once and expires at %s. If you did not ask for it, ignore this email; your account is safe.\n",
+			u, link.ExpiresAt.UTC().Format(time.RFC1123)))
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventMagicLinkRequest, ActorID: &user.ID, UserID: &user.ID, Details: map[string]any{"expires_at": link.ExpiresAt}})
+}
+
+// VerifyMagicLink signs in with a link from RequestMagicLink. The link is
+// consumed only once the login succeeds, so a CONSENT_REQUIRED answer can be
+// retried with consents.
+func (s *Service) VerifyMagicLink(ctx context.Context, in MagicLinkVerifyInput) (*LoginOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.VerifyMagicLink")
+	defer span.End()
+	if in.Token == "" {
+		return nil, ErrInvalidMagicLink
+	}
+	sum := sha256.Sum256([]byte(in.Token))
+	d := db.MustGet().WithContext(ctx)
+	var link MagicLink
+	err := d.Where("token_hash = ? AND consumed_at IS NULL AND expires_at > ?", hex.EncodeToString(sum[:]), time.Now()).First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, Details: map[string]string{"reason": "invalid_magic_link", "method": "magic_link"}})
+		return nil, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	if err := d.Unscoped().Where("purged_at IS NULL").First(&user, link.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+	// A link held back for new terms stays usable for the retry with
+	// consents; anything that changes the account waits until it is used up.
+	if err := s.checkOutstanding(ctx, &user, in.Consents); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	res := d.Model(&MagicLink{}).Where("id = ? AND consumed_at IS NULL", link.ID).Update("consumed_at", &now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		// Used concurrently by another request.
+		return nil, ErrInvalidMagicLink
+	}
+	if user.DeletedAt.Valid {
+		if err := s.restore(ctx, &user); err != nil {
+			if err == ErrInvalidCredential {
+				return nil, ErrInvalidMagicLink
+			}
+			return nil, err
+		}
+	}
+	if err := s.saveGrants(ctx, &user, in.Consents); err != nil {
+		return nil, err
+	}
+	if user.EmailVerifiedAt == nil {
+		// The link reached the inbox, so the address is the member's.
+		d.Unscoped().Model(&user).Update("email_verified_at", &now)
+	}
+	return s.issueLogin(ctx, &user, "magic_link")
+}
+
+// emailLimiter allows limit requests per key in a sliding window. It is
+// in-memory, which is enough for this single-instance service.
+type emailLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	hits   map[string][]time.Time
+}
+
+func newEmailLimiter(limit int, window time.Duration) *emailLimiter {
+	return &emailLimiter{limit: limit, window: window, hits: map[string][]time.Time{}}
+}
+
+// allow records a request for key at now and reports whether it is within
+// the limit. Keys are hashed so the map holds no addresses.
+func (l *emailLimiter) allow(key string, now time.Time) bool {
+	sum := sha256.Sum256([]byte(key))
+	k := hex.EncodeToString(sum[:16])
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := now.Add(-l.window)
+	if len(l.hits) > 10000 {
+		for key, ts := range l.hits {
+			if len(ts) == 0 || ts[len(ts)-1].Before(cutoff) {
+				delete(l.hits, key)
+			}
+		}
+	}
+	recent := l.hits[k][:0]
+	for _, t := range l.hits[k] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= l.limit {
+		l.hits[k] = recent
+		return false
+	}
+	l.hits[k] = append(recent, now)
+	return true
+}
@@ -1,6 +1,19 @@
 package auth
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Roles carried in the JWT and checked by middleware.RequireRole.
+const (
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+	// RoleStaff is store staff and POS systems: card verification and
+	// crediting purchases.
+	RoleStaff = "staff"
+)
 
 // User represents a system user.
 type User struct {
@@ -10,7 +23,12 @@ type User struct {
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 	LastLoginAt  *time.Time `json:"last_login_at"`
-	IsActive     bool       `json:"-" gorm:"default:true"`
+	// EmailVerifiedAt is when the owner last proved control of Email: a
+	// magic link, a confirmed email change or a social sign-up with a
+	// provider-verified address. Registering with a password does not set it.
+	EmailVerifiedAt *time.Time `json:"-"`
+	IsActive        bool       `json:"-" gorm:"default:true"`
+	Role            string     `json:"role" gorm:"size:20;not null;default:member"`
 	// Profile fields
 	FirstName       *string    `json:"first_name" gorm:"size:100"`
 	LastName        *string    `json:"last_name" gorm:"size:100"`
@@ -19,4 +37,48 @@ type User struct {
 	MembershipCode  *string    `json:"membership_code" gorm:"size:50;uniqueIndex"`
 	Points          int        `json:"points" gorm:"default:0"`
 	JoinedAt        *time.Time `json:"joined_at"`
+	// Account lifecycle: DeletedAt marks a self-service deletion, restorable by
+	// logging in until the grace period ends; PurgedAt is set once PII is anonymized.
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	PurgedAt  *time.Time     `json:"-"`
+	// AvatarKey is the blob key prefix of the current avatar renditions.
+	AvatarKey *string `json:"-" gorm:"size:100"`
+	// Extended profile. DateOfBirth is a YYYY-MM-DD calendar date (no time zone).
+	DateOfBirth *string      `json:"date_of_birth" gorm:"size:10"`
+	Gender      *string      `json:"gender" gorm:"size:20"`
+	Language    string       `json:"language" gorm:"size:5;not null;default:th"`
+	Address     Address      `json:"address" gorm:"embedded;embeddedPrefix:address_"`
+	Contact     ContactPrefs `json:"contact_preferences" gorm:"embedded;embeddedPrefix:contact_"`
+
+	// ReferralCode is the member's own invite code (referral package).
+	ReferralCode *string `json:"-" gorm:"size:8;uniqueIndex"`
+}
+
+// profileComplete reports whether every field counted for the profile
+// completion campaign is filled in.
+func (u *User) profileComplete() bool {
+	return u.FirstName != nil && u.LastName != nil && u.Phone != nil &&
+		u.DateOfBirth != nil && u.Gender != nil && u.Address.Province != nil
+}
+
+// Genders accepted on the profile.
+const (
+	GenderMale           = "male"
+	GenderFemale         = "female"
+	GenderOther          = "other"
+	GenderPreferNotToSay = "prefer_not_to_say"
+)
+
+// Languages the app is localised into.
+const (
+	LanguageThai    = "th"
+	LanguageEnglish = "en"
+)
+
+// ContactPrefs are the channels the member agrees to be contacted on for
+// service messages. Marketing permission is a separate consent (consent package).
+type ContactPrefs struct {
+	Email bool `json:"email" gorm:"not null;default:true"`
+	SMS   bool `json:"sms" gorm:"not null;default:false"`
+	Push  bool `json:"push" gorm:"not null;default:true"`
 }
@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/consent"
+	"workshop-be/internal/db"
+	"workshop-be/internal/webauthn"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidPasskey covers responses that fail verification and
+	// challenges that are unknown, expired or already used.
+	ErrInvalidPasskey     = errors.New("invalid passkey response")
+	ErrPasskeyExists      = errors.New("passkey already registered")
+	ErrPasskeyNotFound    = errors.New("passkey not found")
+	ErrInvalidPasskeyName = errors.New("invalid passkey name")
+)
+
+// Ceremony purposes stored with a challenge.
+const (
+	passkeyRegister = "register"
+	passkeyLogin    = "login"
+)
+
+// Passkey is a WebAuthn credential registered to a user.
+type Passkey struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	UserID uint `json:"-" gorm:"index;not null"`
+	// CredentialID is the authenticator's credential id, base64url.
+	CredentialID string `json:"-" gorm:"size:1400;uniqueIndex;not null"`
+	Name         string `json:"name" gorm:"size:100;not null"`
+	// PublicKey is the COSE_Key from registration.
+	PublicKey []byte `json:"-" gorm:"not null"`
+	// SignCount is the authenticator's signature counter; a value that does
+	// not increase marks a possibly cloned authenticator. Synced passkeys
+	// always report 0.
+	SignCount  uint32   `json:"-" gorm:"not null;default:0"`
+	AAGUID     string   `json:"-" gorm:"size:32"`
+	Transports []string `json:"-" gorm:"serializer:json"`
+	// Synced is true for passkeys backed up to a cloud keychain.
+	Synced     bool       `json:"synced"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func (Passkey) TableName() string { return "webauthn_credentials" }
+
+// PasskeyChallenge is an issued ceremony challenge, used once. Only its
+// hash is stored; responses are matched to it through their client data.
+type PasskeyChallenge struct {
+	ID            uint   `gorm:"primaryKey"`
+	ChallengeHash string `gorm:"size:64;uniqueIndex;not null"`
+	Purpose       string `gorm:"size:10;not null"`
+	// UserID is the registering user; nil for login challenges.
+	UserID    *uint
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (PasskeyChallenge) TableName() string { return "webauthn_challenges" }
+
+type PasskeyRegisterInput struct {
+	// Name labels the passkey in the list, e.g. the device; default "Passkey".
+	Name       string                         `json:"name" example:"iPhone"`
+	Credential webauthn.AttestationCredential `json:"credential"`
+}
+
+type PasskeyLoginInput struct {
+	Credential webauthn.AssertionCredential `json:"credential"`
+	// Consents re-accepts documents published since the last login; only
+	// needed after a CONSENT_REQUIRED response.
+	Consents []consent.Grant `json:"consents,omitempty"`
+}
+
+type PasskeysOutput struct {
+	Passkeys []Passkey `json:"passkeys"`
+}
+
+// userHandle is the opaque WebAuthn user id: the account id, which is not PII.
+func userHandle(id uint) []byte { return []byte(strconv.FormatUint(uint64(id), 10)) }
+
+func base64URL(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// newChallenge stores a challenge for purpose and returns it.
+func (s *Service) newChallenge(ctx context.Context, purpose string, userID *uint) (string, error) {
+	d := db.MustGet().WithContext(ctx)
+	d.Where("expires_at < ?", time.Now()).Delete(&PasskeyChallenge{})
+	challenge := webauthn.Challenge()
+	err := d.Create(&PasskeyChallenge{
+		ChallengeHash: sha256Hex(challenge),
+		Purpose:       purpose,
+		UserID:        userID,
+		ExpiresAt:     time.Now().Add(webauthn.Timeout),
+	}).Error
+	return challenge, err
+}
+
+// takeChallenge consumes the challenge clientDataJSON answers. It is single
+// use whatever the outcome, so a failed ceremony must be started again.
+func (s *Service) takeChallenge(ctx context.Context, purpose string, clientDataJSON string) (*PasskeyChallenge, string, error) {
+	challenge, err := webauthn.ClientChallenge(clientDataJSON)
+	if err != nil {
+		return nil, "", ErrInvalidPasskey
+	}
+	d := db.MustGet().WithContext(ctx)
+	var ch PasskeyChallenge
+	if err := d.Where("challenge_hash = ? AND purpose = ?", sha256Hex(challenge), purpose).Take(&ch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrInvalidPasskey
+		}
+		return nil, "", err
+	}
+	if res := d.Delete(&ch); res.Error != nil || res.RowsAffected == 0 {
+		return nil, "", ErrInvalidPasskey
+	}
+	if time.Now().After(ch.ExpiresAt) {
+		return nil, "", ErrInvalidPasskey
+	}
+	return &ch, challenge, nil
+}
+
+// PasskeyCreationOptions starts registering a passkey for the user. The
+// user's existing passkeys are excluded so a device is not registered twice.
+func (s *Service) PasskeyCreationOptions(ctx context.Context, userID uint) (*webauthn.CreationOptions, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.PasskeyCreationOptions")
+	defer span.End()
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
+		return nil, err
+	}
+	var existing []Passkey
+	if err := d.Where("user_id = ?", userID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(existing))
+	for _, p := range existing {
+		exclude = append(exclude, webauthn.CredentialDescriptor{Type: "public-key", ID: p.CredentialID, Transports: p.Transports})
+	}
+	challenge, err := s.newChallenge(ctx, passkeyRegister, &userID)
+	if err != nil {
+		return nil, err
+	}
+	display := user.Email
+	if user.FirstName != nil {
+		display = *user.FirstName
+		if user.LastName != nil {
+			display += " " + *user.LastName
+		}
+	}
+	opts := s.WebAuthn.CreationOptions(challenge, webauthn.UserEntity{
+		ID:          base64URL(userHandle(user.ID)),
+		Name:        user.Email,
+		DisplayName: display,
+	}, exclude)
+	return &opts, nil
+}
+
+// RegisterPasskey verifies the authenticator's response to
+// PasskeyCreationOptions and stores the new credential.
+func (s *Service) RegisterPasskey(ctx context.Context, userID uint, in PasskeyRegisterInput) (*Passkey, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.RegisterPasskey")
+	defer span.End()
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if utf8.RuneCountInString(name) > 100 {
+		return nil, ErrInvalidPasskeyName
+	}
+	ch, challenge, err := s.takeChallenge(ctx, passkeyRegister, in.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if ch.UserID == nil || *ch.UserID != userID {
+		return nil, ErrInvalidPasskey
+	}
+	cred, err := s.WebAuthn.VerifyRegistration(challenge, in.Credential)
+	if err != nil {
+		slog.WarnContext(ctx, "passkey registration rejected", "user_id", userID, "err", err)
+		return nil, ErrInvalidPasskey
+	}
+	pk := Passkey{
+		UserID:       userID,
+		CredentialID: base64URL(cred.ID),
+		Name:         name,
+		PublicKey:    cred.PublicKey,
+		SignCount:    cred.SignCount,
+		AAGUID:       webauthn.AAGUIDString(cred.AAGUID),
+		Transports:   cred.Transports,
+		Synced:       cred.BackedUp,
+	}
+	if err := db.MustGet().WithContext(ctx).Create(&pk).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrPasskeyExists
+		}
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventPasskeyRegister, ActorID: &userID, UserID: &userID, Details: map[string]any{"passkey_id": pk.ID, "name": pk.Name, "synced": pk.Synced}})
+	return &pk, nil
+}
+
+// PasskeyRequestOptions starts a passkey login. No account is named: the
+// authenticator offers the user's discoverable passkeys for this site.
+func (s *Service) PasskeyRequestOptions(ctx context.Context) (*webauthn.RequestOptions, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.PasskeyRequestOptions")
+	defer span.End()
+	challenge, err := s.newChallenge(ctx, passkeyLogin, nil)
+	if err != nil {
+		return nil, err
+	}
+	opts := s.WebAuthn.RequestOptions(challenge)
+	return &opts, nil
+}
+
+// LoginWithPasskey verifies the authenticator's response to
+// PasskeyRequestOptions and signs in the passkey's owner. The sign count is
+// advanced before the account is restored or consents are saved.
+func (s *Service) LoginWithPasskey(ctx context.Context, in PasskeyLoginInput) (*LoginOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.LoginWithPasskey")
+	defer span.End()
+	_, challenge, err := s.takeChallenge(ctx, passkeyLogin, in.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	rawID, err := webauthn.DecodeBase64(in.Credential.ID)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	d := db.MustGet().WithContext(ctx)
+	var pk Passkey
+	if err := d.Where("credential_id = ?", base64URL(rawID)).Take(&pk).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, Details: map[string]string{"reason": "unknown_passkey", "method": "passkey"}})
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+	fail := func(reason string) (*LoginOutput, error) {
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, UserID: &pk.UserID, Details: map[string]any{"reason": reason, "method": "passkey", "passkey_id": pk.ID}})
+		return nil, ErrInvalidPasskey
+	}
+	a, err := s.WebAuthn.VerifyAssertion(challenge, pk.PublicKey, base64URL(userHandle(pk.UserID)), in.Credential)
+	if err != nil {
+		slog.WarnContext(ctx, "passkey login rejected", "passkey_id", pk.ID, "err", err)
+		return fail("invalid_assertion")
+	}
+	if !webauthn.SignCountValid(pk.SignCount, a.SignCount) {
+		return fail("sign_count")
+	}
+	var user User
+	if err := d.Unscoped().Where("purged_at IS NULL").First(&user, pk.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+	if err := s.checkOutstanding(ctx, &user, in.Consents); err != nil {
+		return nil, err
+	}
+	// The counter check and update are one statement so two logins with the
+	// same assertion cannot both pass.
+	now := time.Now()
+	res := d.Model(&Passkey{}).Where("id = ? AND sign_count = ?", pk.ID, pk.SignCount).
+		Updates(map[string]any{"sign_count": a.SignCount, "synced": a.BackedUp, "last_used_at": &now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fail("sign_count")
+	}
+	if user.DeletedAt.Valid {
+		if err := s.restore(ctx, &user); err != nil {
+			if err == ErrInvalidCredential {
+				return nil, ErrInvalidPasskey
+			}
+			return nil, err
+		}
+	}
+	if err := s.saveGrants(ctx, &user, in.Consents); err != nil {
+		return nil, err
+	}
+	return s.issueLogin(ctx, &user, "passkey")
+}
+
+// Passkeys lists the user's registered passkeys.
+func (s *Service) Passkeys(ctx context.Context, userID uint) (*PasskeysOutput, error) {
+	out := &PasskeysOutput{Passkeys: []Passkey{}}
+	err := db.MustGet().WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&out.Passkeys).Error
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeletePasskey removes one of the user's passkeys, unless it is the only
+// way left to sign in.
+func (s *Service) DeletePasskey(ctx context.Context, userID, id uint) error {
+	d := db.MustGet().WithContext(ctx)
+	var pk Passkey
+	if err := d.Where("id = ? AND user_id = ?", id, userID).Take(&pk).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPasskeyNotFound
+		}
+		return err
+	}
+	n, err := s.loginMethods(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return ErrLastLoginMethod
+	}
+	if err := d.Delete(&pk).Error; err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventPasskeyDelete, ActorID: &userID, UserID: &userID, Details: map[string]any{"passkey_id": pk.ID, "name": pk.Name}})
+	return nil
+}
+
+// loginMethods counts the ways the user can sign in: a password, each linked
+// provider and each passkey.
+func (s *Service) loginMethods(ctx context.Context, userID uint) (int64, error) {
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
+		return 0, err
+	}
+	var identities, passkeys int64
+	if err := d.Model(&Identity{}).Where("user_id = ?", userID).Count(&identities).Error; err != nil {
+		return 0, err
+	}
+	if err := d.Model(&Passkey{}).Where("user_id = ?", userID).Count(&passkeys).Error; err != nil {
+		return 0, err
+	}
+	n := identities + passkeys
+	if user.PasswordHash != "" {
+		n++
+	}
+	return n, nil
+}
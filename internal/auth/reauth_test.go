@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/db"
+	"workshop-be/internal/mailer"
+)
+
+// outbox keeps sent messages so a test can read the emailed code.
+type outbox []mailer.Message
+
+func (o *outbox) Send(_ context.Context, m mailer.Message) error {
+	*o = append(*o, m)
+	return nil
+}
+
+var codeRE = regexp.MustCompile(`\b\d{6}\b`)
+
+// TestReauthWithoutPassword checks that a social-only account, which has no
+// password, can change its email and delete itself with an emailed code.
+func TestReauthWithoutPassword(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test secret")
+	ctx := context.Background()
+	db.Init(filepath.Join(t.TempDir(), "app.db"), &User{}, &audit.Event{}, &Identity{}, &StepUpChallenge{}, &EmailChange{})
+	s := NewService()
+	mail := &outbox{}
+	s.Mailer = mail
+
+	user := User{Email: "social@example.com"}
+	if err := db.MustGet().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MustGet().Create(&Identity{UserID: user.ID, Provider: "google", Subject: "g-1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.DeleteAccount(ctx, user.ID, DeleteAccountRequest{Reauth{Password: "anything"}}); err != ErrReauthRequired {
+		t.Fatalf("DeleteAccount with a password: err = %v, want ErrReauthRequired", err)
+	}
+
+	reauth := func() Reauth {
+		t.Helper()
+		out, err := s.StartReauth(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := (*mail)[len(*mail)-1]
+		code := codeRE.FindString(m.Body)
+		if m.To != user.Email || code == "" {
+			t.Fatalf("no code emailed: %+v", m)
+		}
+		return Reauth{StepUpToken: out.StepUpToken, Code: code}
+	}
+
+	wrong := reauth()
+	wrong.Code = "wrong"
+	if _, err := s.DeleteAccount(ctx, user.ID, DeleteAccountRequest{wrong}); err != ErrInvalidStepUp {
+		t.Fatalf("DeleteAccount with a wrong code: err = %v, want ErrInvalidStepUp", err)
+	}
+
+	r := reauth()
+	if _, err := s.RequestEmailChange(ctx, user.ID, EmailChangeRequest{NewEmail: "new@example.com", Reauth: r}); err != nil {
+		t.Fatalf("RequestEmailChange: %v", err)
+	}
+	if _, err := s.DeleteAccount(ctx, user.ID, DeleteAccountRequest{r}); err != ErrInvalidStepUp {
+		t.Fatalf("DeleteAccount reusing a code: err = %v, want ErrInvalidStepUp", err)
+	}
+	if _, err := s.DeleteAccount(ctx, user.ID, DeleteAccountRequest{reauth()}); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+}
@@ -1,34 +1,168 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"workshop-be/internal/audit"
+	"workshop-be/internal/avatar"
+	"workshop-be/internal/card"
+	"workshop-be/internal/consent"
 	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+	"workshop-be/internal/geoip"
+	"workshop-be/internal/mailer"
+	"workshop-be/internal/metrics"
+	"workshop-be/internal/referral"
+	"workshop-be/internal/social"
+	"workshop-be/internal/webauthn"
 	"workshop-be/pkg/password"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
 )
 
+var tracer = otel.Tracer("workshop-be/internal/auth")
+
 var emailRegex = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
 var phoneDigitsRegex = regexp.MustCompile(`\D+`)
 
+// normalizeEmail is how addresses are stored and looked up: trimmed and
+// lowercased, so Foo@example.com and foo@example.com are one account.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 var (
 	ErrEmailExists       = errors.New("email already exists")
 	ErrInvalidEmail      = errors.New("invalid email")
-	ErrPasswordTooShort  = errors.New("password too short")
 	ErrInvalidCredential = errors.New("invalid credentials")
 	ErrInvalidPhone      = errors.New("invalid phone")
 	ErrInvalidName       = errors.New("invalid name")
+	// ErrInvalidCurrentPassword is returned when an authenticated user fails to
+	// re-confirm their password; distinct from ErrInvalidCredential so clients
+	// do not treat it as a logged-out session.
+	ErrInvalidCurrentPassword = errors.New("invalid current password")
+	// ErrUserNotFound is returned for token subjects that no longer exist or were deleted.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrConsentRequired is returned by Login when a required legal document
+	// changed since the user last accepted it and the request did not accept it.
+	ErrConsentRequired  = errors.New("consent required")
+	ErrAvatarsDisabled  = errors.New("avatar storage not configured")
+	ErrInvalidBirthDate = errors.New("invalid date of birth")
+	ErrInvalidGender    = errors.New("invalid gender")
+	ErrInvalidLanguage  = errors.New("invalid language")
+	ErrInvalidAddress   = errors.New("invalid address")
+	// ErrInvalidReferralCode is returned by Register for an unknown code.
+	ErrInvalidReferralCode = errors.New("invalid referral code")
+	// ErrBusy is returned when password hashing is saturated; the client
+	// should retry shortly.
+	ErrBusy = password.ErrBusy
 )
 
-type Service struct{}
+// WeakPasswordError is returned when a new password breaks the password
+// policy; Violations lists every rule broken.
+type WeakPasswordError struct {
+	Violations []password.Violation
+}
+
+func (e *WeakPasswordError) Error() string { return "password does not meet the policy" }
+
+// defaultDeletionGrace is how long a deleted account stays restorable before its PII is purged.
+const defaultDeletionGrace = 30 * 24 * time.Hour
+
+type Service struct {
+	audit    *audit.Service
+	consents *consent.Service
+	// DeletionGrace is the restore window after DeleteAccount; PurgeDeleted
+	// anonymizes accounts deleted longer ago than this.
+	DeletionGrace time.Duration
+	// Avatars stores profile pictures; nil disables avatar upload.
+	Avatars *avatar.Service
+	// Mailer delivers confirmation links and security notices.
+	Mailer mailer.Mailer
+	// AppBaseURL is the frontend origin used to build links in emails.
+	AppBaseURL string
+	// Events receives domain events (registration, login, profile
+	// completion); nil publishes nothing.
+	Events *events.Bus
+	// Referrals links new members to the member whose code they used; nil
+	// rejects every referral code.
+	Referrals *referral.Service
+	// Social holds the enabled social login providers; nil disables social login.
+	Social *social.Registry
+	// SocialRedirectBase is the public origin of this API, used to build the
+	// callback URL registered with each provider.
+	SocialRedirectBase string
+	// MagicLinkTTL is how long an emailed sign-in link stays valid.
+	MagicLinkTTL time.Duration
+	magicLimiter *emailLimiter
+	// WebAuthn is the relying party passkeys are registered with.
+	WebAuthn *webauthn.RelyingParty
+	// PasswordPolicy checks passwords set at registration and password change.
+	PasswordPolicy *password.Policy
+	// Hasher hashes new passwords; Login upgrades older hashes to its settings.
+	Hasher *password.Hasher
+	// ConcealExistingAccounts makes Register answer the same for new and
+	// taken emails: the owner of a taken email is notified by email instead,
+	// and new members get a welcome email.
+	ConcealExistingAccounts bool
+	registerLimiter         *emailLimiter
+	// GeoIP locates login IPs for the login history and impossible-travel
+	// checks; nil records no locations.
+	GeoIP *geoip.DB
+	// LoginStepUp makes a password login from a new device or an impossible
+	// location confirm a code emailed to the account before a token is issued.
+	LoginStepUp   bool
+	stepUpLimiter *emailLimiter
+	// purgers remove other packages' data of purged accounts, see OnPurge.
+	purgers []PurgeFunc
+	// background tracks work finished after the response, see Wait.
+	background sync.WaitGroup
+}
+
+func NewService() *Service {
+	return &Service{
+		audit:              audit.NewService(),
+		consents:           consent.NewService(),
+		DeletionGrace:      defaultDeletionGrace,
+		Mailer:             mailer.NewLog(),
+		AppBaseURL:         "http://localhost:3000",
+		SocialRedirectBase: "http://localhost:3000",
+		MagicLinkTTL:       defaultMagicLinkTTL,
+		magicLimiter:       newEmailLimiter(defaultMagicLinkLimit, defaultMagicLinkWindow),
+		registerLimiter:    newEmailLimiter(defaultMagicLinkLimit, defaultMagicLinkWindow),
+		stepUpLimiter:      newEmailLimiter(defaultMagicLinkLimit, defaultMagicLinkWindow),
+		PasswordPolicy:     password.DefaultPolicy(),
+		Hasher:             password.DefaultHasher(),
+		WebAuthn:           &webauthn.RelyingParty{ID: "localhost", Name: "Workshop", Origins: []string{"http://localhost:3000"}},
+	}
+}
 
-func NewService() *Service { return &Service{} }
+// Wait blocks until work started after a response (magic link emails) is
+// done. Call it once the server stops accepting requests, before closing the
+// database.
+func (s *Service) Wait() { s.background.Wait() }
 
 type RegisterInput struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Consents must accept the current terms and privacy documents, if published.
+	Consents []consent.Grant `json:"consents"`
+	// ReferralCode is an optional invite code from an existing member.
+	ReferralCode string `json:"referral_code" example:"K7QM3XPA"`
+}
+
+// LogValue keeps the password out of logs if the input is ever logged whole.
+func (in RegisterInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("email", in.Email))
 }
 
 type RegisterOutput struct {
@@ -37,9 +171,25 @@ type RegisterOutput struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// RegisterAcceptedOutput replaces RegisterOutput when
+// ConcealExistingAccounts is set, for new and taken emails alike.
+type RegisterAcceptedOutput struct {
+	Message string `json:"message" example:"registration received; check your email to continue"`
+}
+
+var registrationAccepted = &RegisterAcceptedOutput{Message: "registration received; check your email to continue"}
+
 type LoginInput struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Consents re-accepts documents published since the last login; only
+	// needed after a CONSENT_REQUIRED response.
+	Consents []consent.Grant `json:"consents,omitempty"`
+}
+
+// LogValue keeps the password out of logs if the input is ever logged whole.
+func (in LoginInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("email", in.Email))
 }
 
 type LoginOutput struct {
@@ -61,74 +211,316 @@ type ProfileResponse struct {
 	Points          int        `json:"points"`
 	JoinedAt        *time.Time `json:"joined_at"`
 	CreatedAt       time.Time  `json:"created_at"`
+	// AvatarURLs maps size (large 512px, medium 256px, small 64px) to URL; null without an avatar.
+	AvatarURLs map[string]string `json:"avatar_urls"`
+
+	DateOfBirth *string `json:"date_of_birth" example:"1990-04-21"`
+	Gender      *string `json:"gender" example:"female"`
+	Language    string  `json:"language" example:"th"`
+	// Address is null until one is set.
+	Address            *Address     `json:"address"`
+	ContactPreferences ContactPrefs `json:"contact_preferences"`
 }
 
+// ProfileUpdateRequest is a partial update: omitted (or null) fields keep
+// their stored value.
 type ProfileUpdateRequest struct {
 	FirstName *string `json:"first_name"`
 	LastName  *string `json:"last_name"`
 	Phone     *string `json:"phone"`
+	// DateOfBirth is YYYY-MM-DD.
+	DateOfBirth *string `json:"date_of_birth" example:"1990-04-21"`
+	// Gender is male, female, other or prefer_not_to_say.
+	Gender *string `json:"gender" example:"female"`
+	// Language is th or en.
+	Language *string       `json:"language" example:"th"`
+	Address  *AddressInput `json:"address"`
+	// ContactPreferences updates only the channels present.
+	ContactPreferences *ContactPrefsInput `json:"contact_preferences"`
+}
+
+type ContactPrefsInput struct {
+	Email *bool `json:"email"`
+	SMS   *bool `json:"sms"`
+	Push  *bool `json:"push"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// LogValue keeps both passwords out of logs.
+func (ChangePasswordRequest) LogValue() slog.Value {
+	return slog.GroupValue()
 }
 
 type MeOutput struct {
 	ID          uint       `json:"id"`
 	Email       string     `json:"email"`
+	Role        string     `json:"role"`
 	LastLoginAt *time.Time `json:"last_login_at"`
 }
 
-func (s *Service) Register(input RegisterInput) (*RegisterOutput, error) {
+func (s *Service) Register(ctx context.Context, input RegisterInput) (*RegisterOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.Register")
+	defer span.End()
+	input.Email = normalizeEmail(input.Email)
 	if !emailRegex.MatchString(input.Email) {
 		return nil, ErrInvalidEmail
 	}
-	if len(input.Password) < 8 {
-		return nil, ErrPasswordTooShort
+	if err := s.checkPassword(ctx, input.Password, input.Email); err != nil {
+		return nil, err
+	}
+	if err := s.consents.ValidateRegistration(ctx, input.Consents); err != nil {
+		return nil, err
+	}
+	var referrerID uint
+	if strings.TrimSpace(input.ReferralCode) != "" {
+		if s.Referrals == nil {
+			return nil, ErrInvalidReferralCode
+		}
+		id, err := s.Referrals.Resolve(ctx, input.ReferralCode)
+		if errors.Is(err, referral.ErrInvalidCode) {
+			return nil, ErrInvalidReferralCode
+		}
+		if err != nil {
+			return nil, err
+		}
+		referrerID = id
 	}
-	d := db.MustGet()
+	d := db.MustGet().WithContext(ctx)
 	var count int64
-	d.Model(&User{}).Where("email = ?", input.Email).Count(&count)
+	// Unscoped: an account inside its deletion grace period still owns its email.
+	d.Unscoped().Model(&User{}).Where("email = ?", input.Email).Count(&count)
 	if count > 0 {
+		if s.ConcealExistingAccounts {
+			if err := s.registerExisting(ctx, input); err != nil {
+				return nil, err
+			}
+		}
 		return nil, ErrEmailExists
 	}
-	h, err := password.Hash(input.Password)
+	start := time.Now()
+	h, err := s.Hasher.Hash(ctx, input.Password)
+	metrics.ObservePassword("hash", time.Since(start))
 	if err != nil {
 		return nil, err
 	}
 	user := User{Email: input.Email, PasswordHash: h}
-	if err := d.Create(&user).Error; err != nil {
+	err = d.Transaction(func(tx *gorm.DB) error {
+		if err := s.createUser(tx, &user); err != nil {
+			return err
+		}
+		if referrerID != 0 {
+			if err := s.Referrals.Attach(ctx, tx, referrerID, user.ID, user.Email); err != nil {
+				return err
+			}
+		}
+		return s.consents.Save(ctx, tx, user.ID, input.Consents, consent.SourceRegistration)
+	})
+	if err != nil {
 		return nil, err
 	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventRegister, ActorID: &user.ID, UserID: &user.ID})
+	s.consents.Audit(ctx, user.ID, input.Consents, consent.SourceRegistration)
+	s.Events.Publish(ctx, events.Event{Type: events.UserRegistered, UserID: user.ID})
+	if s.ConcealExistingAccounts {
+		s.notify(ctx, user.Email, "Welcome to Workshop",
+			fmt.Sprintf("Your account is ready. Sign in at %s with this email and the password you chose.\n", strings.TrimRight(s.AppBaseURL, "/")+"/login"))
+	}
 	return &RegisterOutput{ID: user.ID, Email: user.Email, CreatedAt: user.CreatedAt}, nil
 }
 
-func (s *Service) Login(input LoginInput) (*LoginOutput, error) {
+// registerExisting handles a registration for a taken email when
+// ConcealExistingAccounts is set: it hashes the password as a new account
+// would, so the timing matches, and tells the owner instead of the caller.
+// The notice is rate limited per email like magic links. Only hashing
+// errors are returned, as a new account would return them.
+func (s *Service) registerExisting(ctx context.Context, input RegisterInput) error {
+	start := time.Now()
+	_, err := s.Hasher.Hash(ctx, input.Password)
+	metrics.ObservePassword("hash", time.Since(start))
+	if err != nil {
+		return err
+	}
+	var user User
+	if err := db.MustGet().WithContext(ctx).Unscoped().Where("email = ?", input.Email).First(&user).Error; err != nil {
+		return nil
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventRegisterExisting, UserID: &user.ID})
+	if !s.registerLimiter.allow(user.Email, time.Now()) {
+		return nil
+	}
+	s.notify(ctx, user.Email, "Someone tried to register with your email",
+		fmt.Sprintf("Someone tried to create a Workshop account with this email, which already has one.\n\nIf it was you, sign in at %s, or use a sign-in link if you forgot your password. Otherwise you can ignore this email; your account is safe.\n",
+			strings.TrimRight(s.AppBaseURL, "/")+"/login"))
+	return nil
+}
+
+func (s *Service) Login(ctx context.Context, input LoginInput) (*LoginOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.Login")
+	defer span.End()
+	input.Email = normalizeEmail(input.Email)
 	if input.Email == "" || input.Password == "" {
 		return nil, ErrInvalidCredential
 	}
-	d := db.MustGet()
+	d := db.MustGet().WithContext(ctx)
 	var user User
-	if err := d.Where("email = ?", input.Email).First(&user).Error; err != nil {
+	if err := d.Unscoped().Where("email = ?", input.Email).First(&user).Error; err != nil {
+		// Spend the time a wrong password would, so timing doesn't reveal
+		// which emails are registered. Only the audit log hears of it: login
+		// history belongs to an account and is purged with it.
+		if err := s.Hasher.VerifyDummy(ctx, input.Password); err != nil {
+			return nil, err
+		}
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, Details: map[string]string{"email_hash": auditEmailHash(input.Email), "reason": "unknown_email"}})
+		s.clientRisk(ctx)
 		return nil, ErrInvalidCredential
 	}
-	if !password.Verify(user.PasswordHash, input.Password) {
+	start := time.Now()
+	ok, rehash, err := s.Hasher.Verify(ctx, user.PasswordHash, input.Password)
+	metrics.ObservePassword("verify", time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, UserID: &user.ID, Details: map[string]string{"reason": "wrong_password"}})
+		s.recordLogin(ctx, user.ID, "password", OutcomeFailure, "wrong_password", s.clientRisk(ctx))
 		return nil, ErrInvalidCredential
 	}
+	if err := s.checkOutstanding(ctx, &user, input.Consents); err != nil {
+		return nil, err
+	}
+	// Nothing about the account changes before the step-up gate: a held-back
+	// login carries its consents in the challenge, and the hash upgrade waits
+	// for a login that is not held back.
+	risk := s.assessLogin(ctx, user.ID)
+	if s.LoginStepUp && risk.risky() {
+		return nil, s.startStepUp(ctx, &user, risk, input.Consents)
+	}
+	if err := s.saveGrants(ctx, &user, input.Consents); err != nil {
+		return nil, err
+	}
+	if rehash {
+		s.rehashPassword(ctx, &user, input.Password)
+	}
+	if user.DeletedAt.Valid {
+		if err := s.restore(ctx, &user); err != nil {
+			return nil, err
+		}
+	}
+	return s.completeLogin(ctx, &user, "", risk)
+}
+
+// rehashPassword replaces an outdated hash after the password was verified.
+// Failures are only logged since the old hash still works, it is skipped
+// while hashing is busy, and a password changed meanwhile is left alone.
+func (s *Service) rehashPassword(ctx context.Context, user *User, pw string) {
+	start := time.Now()
+	h, err := s.Hasher.Hash(ctx, pw)
+	metrics.ObservePassword("hash", time.Since(start))
+	if errors.Is(err, ErrBusy) {
+		return // the next login tries again
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "password rehash failed", "user_id", user.ID, "err", err)
+		return
+	}
+	res := db.MustGet().WithContext(ctx).Unscoped().Model(&User{}).
+		Where("id = ? AND password_hash = ?", user.ID, user.PasswordHash).
+		Update("password_hash", h)
+	if res.Error != nil {
+		slog.WarnContext(ctx, "password rehash failed", "user_id", user.ID, "err", res.Error)
+		return
+	}
+	if res.RowsAffected == 1 {
+		user.PasswordHash = h
+		slog.InfoContext(ctx, "password hash upgraded", "user_id", user.ID, "algorithm", s.Hasher.Algorithm)
+	}
+}
+
+// CalibratePasswordDummy makes unknown emails cost as much as the slowest
+// password hash still stored: it hands one hash per algorithm and cost to
+// Hasher.CalibrateDummy. Call it once at startup.
+func (s *Service) CalibratePasswordDummy(ctx context.Context) error {
+	var samples []string
+	// The format is the prefix before the salt: "$2b$12$" for bcrypt,
+	// "$argon2id$v=19$m=19456,t=2,p=1" for argon2id.
+	err := db.MustGet().WithContext(ctx).Unscoped().Model(&User{}).
+		Where("password_hash <> ''").
+		Group("CASE WHEN password_hash LIKE '$2%' THEN substr(password_hash, 1, 7) "+
+			"ELSE substr(password_hash, 1, 14 + instr(substr(password_hash, 16), '$')) END").
+		Pluck("MIN(password_hash)", &samples).Error
+	if err != nil {
+		return err
+	}
+	return s.Hasher.CalibrateDummy(samples)
+}
+
+// createUser inserts a new member with its referral and membership codes.
+func (s *Service) createUser(tx *gorm.DB, user *User) error {
+	code := referral.NewCode()
+	user.ReferralCode = &code
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+	return tx.Model(user).Update("membership_code", card.MembershipCode(user.ID)).Error
+}
+
+// issueLogin finishes a successful sign-in, whatever the method: it stamps
+// last_login_at, records the login history and returns an access token.
+// method names a sign-in other than email and password in the audit event.
+func (s *Service) issueLogin(ctx context.Context, user *User, method string) (*LoginOutput, error) {
+	return s.completeLogin(ctx, user, method, s.assessLogin(ctx, user.ID))
+}
+
+// completeLogin is issueLogin with the login already assessed. The owner is
+// emailed about a risky login unless it was confirmed by step-up.
+func (s *Service) completeLogin(ctx context.Context, user *User, method string, risk *loginRisk) (*LoginOutput, error) {
 	now := time.Now()
-	d.Model(&user).Update("last_login_at", &now)
+	db.MustGet().WithContext(ctx).Model(user).Update("last_login_at", &now)
 	expiry := 15 * time.Minute
-	token, err := GenerateToken(user.ID, user.Email, expiry)
+	token, err := GenerateToken(user.ID, user.Email, user.Role, expiry)
 	if err != nil {
 		return nil, err
 	}
+	details := map[string]any{}
+	if method != "" {
+		details["method"] = method
+	}
+	if risk.newDevice {
+		details["new_device"] = true
+	}
+	if risk.impossibleTravel {
+		details["impossible_travel"] = true
+	}
+	if risk.steppedUp {
+		details["step_up"] = true
+	}
+	entry := audit.Entry{Type: audit.EventLoginSuccess, ActorID: &user.ID, UserID: &user.ID}
+	if len(details) > 0 {
+		entry.Details = details
+	}
+	s.audit.Record(ctx, entry)
+	s.recordLogin(ctx, user.ID, method, OutcomeSuccess, "", risk)
+	if risk.risky() && !risk.steppedUp {
+		s.notifyRiskyLogin(ctx, user, risk)
+	}
+	s.Events.Publish(ctx, events.Event{Type: events.UserLoggedIn, UserID: user.ID})
 	return &LoginOutput{AccessToken: token, TokenType: "Bearer", ExpiresIn: int64(expiry.Seconds())}, nil
 }
 
 // GetProfile returns user profile by id
-func (s *Service) GetProfile(userID uint) (*ProfileResponse, error) {
-	d := db.MustGet()
-	var user User
-	if err := d.First(&user, userID).Error; err != nil {
+func (s *Service) GetProfile(ctx context.Context, userID uint) (*ProfileResponse, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.GetProfile")
+	defer span.End()
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
 		return nil, err
 	}
-	return &ProfileResponse{
+	prof := &ProfileResponse{
 		ID:              user.ID,
 		Email:           user.Email,
 		FirstName:       user.FirstName,
@@ -139,22 +531,76 @@ func (s *Service) GetProfile(userID uint) (*ProfileResponse, error) {
 		Points:          user.Points,
 		JoinedAt:        user.JoinedAt,
 		CreatedAt:       user.CreatedAt,
-	}, nil
+	}
+	prof.DateOfBirth = user.DateOfBirth
+	prof.Gender = user.Gender
+	prof.Language = user.Language
+	prof.ContactPreferences = user.Contact
+	if user.Address.Province != nil {
+		addr := user.Address
+		prof.Address = &addr
+	}
+	if user.AvatarKey != nil && s.Avatars != nil {
+		prof.AvatarURLs = s.Avatars.URLs(*user.AvatarKey)
+	}
+	return prof, nil
+}
+
+// SetAvatar replaces the user's avatar with the image read from r.
+func (s *Service) SetAvatar(ctx context.Context, userID uint, r io.Reader) (*ProfileResponse, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.SetAvatar")
+	defer span.End()
+	if s.Avatars == nil {
+		return nil, ErrAvatarsDisabled
+	}
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.Avatars.Upload(ctx, user.ID, r)
+	if err != nil {
+		return nil, err
+	}
+	var old string
+	if user.AvatarKey != nil {
+		old = *user.AvatarKey
+	}
+	if err := d.Model(user).Update("avatar_key", key).Error; err != nil {
+		s.Avatars.Delete(ctx, key)
+		return nil, err
+	}
+	change := audit.FieldChange{To: key}
+	if old != "" {
+		change.From = old
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventProfileUpdate, ActorID: &user.ID, UserID: &user.ID, Details: map[string]any{"changes": map[string]audit.FieldChange{"avatar": change}}})
+	if old != "" {
+		s.Avatars.Delete(ctx, old)
+	}
+	return s.GetProfile(ctx, user.ID)
 }
 
 // UpdateProfile updates editable fields
-func (s *Service) UpdateProfile(userID uint, req ProfileUpdateRequest) (*ProfileResponse, error) {
-	d := db.MustGet()
-	var user User
-	if err := d.First(&user, userID).Error; err != nil {
+func (s *Service) UpdateProfile(ctx context.Context, userID uint, req ProfileUpdateRequest) (*ProfileResponse, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.UpdateProfile")
+	defer span.End()
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
 		return nil, err
 	}
+	wasComplete := user.profileComplete()
+	// The audit log is append-only and outlives an account purge, so personal
+	// values are recorded as keyed hashes (see auditValue).
+	changes := map[string]audit.FieldChange{}
 	// Validate names
 	if req.FirstName != nil {
 		fn := strings.TrimSpace(*req.FirstName)
 		if len(fn) == 0 || len(fn) > 100 {
 			return nil, ErrInvalidName
 		}
+		diffField(changes, "first_name", user.FirstName, fn)
 		user.FirstName = &fn
 	}
 	if req.LastName != nil {
@@ -162,6 +608,7 @@ func (s *Service) UpdateProfile(userID uint, req ProfileUpdateRequest) (*Profile
 		if len(ln) == 0 || len(ln) > 100 {
 			return nil, ErrInvalidName
 		}
+		diffField(changes, "last_name", user.LastName, ln)
 		user.LastName = &ln
 	}
 	if req.Phone != nil {
@@ -169,19 +616,306 @@ func (s *Service) UpdateProfile(userID uint, req ProfileUpdateRequest) (*Profile
 		if len(processed) != 10 {
 			return nil, ErrInvalidPhone
 		}
+		diffField(changes, "phone", user.Phone, processed)
 		user.Phone = &processed
 	}
-	if err := d.Save(&user).Error; err != nil {
+	if req.DateOfBirth != nil {
+		dob := strings.TrimSpace(*req.DateOfBirth)
+		if !validBirthDate(dob, time.Now()) {
+			return nil, ErrInvalidBirthDate
+		}
+		diffField(changes, "date_of_birth", user.DateOfBirth, dob)
+		user.DateOfBirth = &dob
+	}
+	if req.Gender != nil {
+		g := *req.Gender
+		if g != GenderMale && g != GenderFemale && g != GenderOther && g != GenderPreferNotToSay {
+			return nil, ErrInvalidGender
+		}
+		diffField(changes, "gender", user.Gender, g)
+		user.Gender = &g
+	}
+	if req.Language != nil {
+		lang := *req.Language
+		if lang != LanguageThai && lang != LanguageEnglish {
+			return nil, ErrInvalidLanguage
+		}
+		diffField(changes, "language", &user.Language, lang)
+		user.Language = lang
+	}
+	if req.Address != nil {
+		next := Address{}
+		if !req.Address.empty() {
+			addr, err := req.Address.normalize()
+			if err != nil {
+				return nil, err
+			}
+			next = *addr
+		}
+		diffAddress(changes, user.Address, next)
+		user.Address = next
+	}
+	if p := req.ContactPreferences; p != nil {
+		diffBool(changes, "contact_preferences.email", &user.Contact.Email, p.Email)
+		diffBool(changes, "contact_preferences.sms", &user.Contact.SMS, p.SMS)
+		diffBool(changes, "contact_preferences.push", &user.Contact.Push, p.Push)
+	}
+	if err := d.Save(user).Error; err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventProfileUpdate, ActorID: &user.ID, UserID: &user.ID, Details: map[string]any{"changes": changes}})
+	}
+	if !wasComplete && user.profileComplete() {
+		s.Events.Publish(ctx, events.Event{Type: events.ProfileCompleted, UserID: user.ID})
+	}
+	return s.GetProfile(ctx, user.ID)
+}
+
+func (s *Service) Me(ctx context.Context, userID uint) (*MeOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.Me")
+	defer span.End()
+	u, err := findUser(db.MustGet().WithContext(ctx), userID)
+	if err != nil {
 		return nil, err
 	}
-	return s.GetProfile(user.ID)
+	return &MeOutput{ID: u.ID, Email: u.Email, Role: u.Role, LastLoginAt: u.LastLoginAt}, nil
+}
+
+// Account returns the full user row (without credentials) for data exports.
+func (s *Service) Account(ctx context.Context, userID uint) (*User, error) {
+	return findUser(db.MustGet().WithContext(ctx), userID)
+}
+
+// ChangePassword replaces the password after re-verifying the current one.
+func (s *Service) ChangePassword(ctx context.Context, userID uint, req ChangePasswordRequest) error {
+	ctx, span := tracer.Start(ctx, "auth.Service.ChangePassword")
+	defer span.End()
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	ok, _, err := s.Hasher.Verify(ctx, user.PasswordHash, req.CurrentPassword)
+	metrics.ObservePassword("verify", time.Since(start))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCurrentPassword
+	}
+	// The policy, and its network breach lookup, only runs once the caller
+	// has proved they hold the account.
+	if err := s.checkPassword(ctx, req.NewPassword, user.Email); err != nil {
+		return err
+	}
+	start = time.Now()
+	h, err := s.Hasher.Hash(ctx, req.NewPassword)
+	metrics.ObservePassword("hash", time.Since(start))
+	if err != nil {
+		return err
+	}
+	if err := d.Model(user).Update("password_hash", h).Error; err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventPasswordChange, ActorID: &user.ID, UserID: &user.ID})
+	return nil
+}
+
+// checkPassword applies the password policy to a new password for email.
+func (s *Service) checkPassword(ctx context.Context, pw, email string) error {
+	if v := s.PasswordPolicy.Check(ctx, pw, email); len(v) > 0 {
+		return &WeakPasswordError{Violations: v}
+	}
+	return nil
+}
+
+// NormalizeEmails lowercases addresses stored before emails were normalized,
+// in accounts and pending email changes. An account whose address differs
+// from another only by case is left as it is and logged: the two must be
+// merged by hand. Returns how many accounts were changed.
+func (s *Service) NormalizeEmails(ctx context.Context) (int, error) {
+	d := db.MustGet().WithContext(ctx)
+	var users []User
+	if err := d.Unscoped().Select("id", "email").Where("email <> LOWER(email)").Find(&users).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, u := range users {
+		err := d.Unscoped().Model(&User{}).Where("id = ?", u.ID).Update("email", normalizeEmail(u.Email)).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			slog.WarnContext(ctx, "email differs from another account only by case, left unchanged", "user_id", u.ID)
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	var changes []EmailChange
+	if err := d.Select("id", "new_email").Where("new_email <> LOWER(new_email)").Find(&changes).Error; err != nil {
+		return n, err
+	}
+	for _, ch := range changes {
+		if err := d.Model(&EmailChange{}).Where("id = ?", ch.ID).Update("new_email", normalizeEmail(ch.NewEmail)).Error; err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// PromoteAdmins grants the admin role to existing users with the given emails
+// (bootstrap from ADMIN_EMAILS). Returns how many users were promoted.
+func (s *Service) PromoteAdmins(ctx context.Context, emails []string) (int, error) {
+	return s.grantRole(ctx, emails, RoleAdmin, "ADMIN_EMAILS", RoleMember, RoleStaff)
+}
+
+// PromoteStaff grants the staff role to existing members with the given
+// emails (bootstrap from STAFF_EMAILS). Admins keep their role.
+func (s *Service) PromoteStaff(ctx context.Context, emails []string) (int, error) {
+	return s.grantRole(ctx, emails, RoleStaff, "STAFF_EMAILS", RoleMember)
+}
+
+// grantRole sets role on users with the given emails whose current role is one of from.
+func (s *Service) grantRole(ctx context.Context, emails []string, role, source string, from ...string) (int, error) {
+	if len(emails) == 0 {
+		return 0, nil
+	}
+	normalized := make([]string, len(emails))
+	for i, e := range emails {
+		normalized[i] = normalizeEmail(e)
+	}
+	d := db.MustGet().WithContext(ctx)
+	var users []User
+	if err := d.Where("email IN ? AND role IN ?", normalized, from).Find(&users).Error; err != nil {
+		return 0, err
+	}
+	for i := range users {
+		u := &users[i]
+		if err := d.Model(u).Update("role", role).Error; err != nil {
+			return i, err
+		}
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, UserID: &u.ID, Details: map[string]string{"action": "role.grant", "role": role, "source": source}})
+	}
+	return len(users), nil
+}
+
+// acceptOutstanding enforces re-acceptance of required legal documents at
+// login and records any grants sent with the request.
+func (s *Service) acceptOutstanding(ctx context.Context, user *User, grants []consent.Grant) error {
+	if err := s.checkOutstanding(ctx, user, grants); err != nil {
+		return err
+	}
+	return s.saveGrants(ctx, user, grants)
+}
+
+// checkOutstanding is acceptOutstanding without saving: it fails when grants
+// are invalid or do not accept every document the user still has to.
+func (s *Service) checkOutstanding(ctx context.Context, user *User, grants []consent.Grant) error {
+	if err := s.consents.Validate(ctx, grants); err != nil {
+		return err
+	}
+	missing, err := s.consents.Outstanding(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !consent.Covers(missing, grants) {
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, UserID: &user.ID, Details: map[string]string{"reason": "consent_required", "documents": consent.Describe(missing)}})
+		return ErrConsentRequired
+	}
+	return nil
+}
+
+// saveGrants records consents sent with a login.
+func (s *Service) saveGrants(ctx context.Context, user *User, grants []consent.Grant) error {
+	if err := s.consents.Save(ctx, db.MustGet(), user.ID, grants, consent.SourceLogin); err != nil {
+		return err
+	}
+	s.consents.Audit(ctx, user.ID, grants, consent.SourceLogin)
+	return nil
 }
 
-func (s *Service) Me(userID uint) (*MeOutput, error) {
-	d := db.MustGet()
+// findUser loads an active (not soft-deleted) user, mapping a miss to ErrUserNotFound.
+func findUser(d *gorm.DB, id uint) (*User, error) {
 	var u User
-	if err := d.First(&u, userID).Error; err != nil {
+	if err := d.First(&u, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
 		return nil, err
 	}
-	return &MeOutput{ID: u.ID, Email: u.Email, LastLoginAt: u.LastLoginAt}, nil
+	return &u, nil
+}
+
+// validBirthDate accepts a YYYY-MM-DD date that is not in the future and at
+// most 120 years ago.
+func validBirthDate(s string, now time.Time) bool {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return !t.After(today) && t.After(today.AddDate(-120, 0, 0))
+}
+
+// diffBool applies next to *field when present and records the change.
+func diffBool(changes map[string]audit.FieldChange, name string, field *bool, next *bool) {
+	if next == nil || *next == *field {
+		return
+	}
+	changes[name] = audit.FieldChange{From: *field, To: *next}
+	*field = *next
+}
+
+// diffAddress records per-component address changes.
+func diffAddress(changes map[string]audit.FieldChange, old, next Address) {
+	pairs := []struct {
+		name      string
+		old, next *string
+	}{
+		{"address.line", old.Line, next.Line},
+		{"address.subdistrict", old.Subdistrict, next.Subdistrict},
+		{"address.district", old.District, next.District},
+		{"address.province", old.Province, next.Province},
+		{"address.postcode", old.Postcode, next.Postcode},
+	}
+	for _, p := range pairs {
+		var from, to any
+		if p.old != nil {
+			from = *p.old
+		}
+		if p.next != nil {
+			to = *p.next
+		}
+		if from != to {
+			changes[p.name] = audit.FieldChange{From: auditValue(p.name, p.old), To: auditValue(p.name, p.next)}
+		}
+	}
+}
+
+// diffField records a change when the new value differs from the stored one.
+func diffField(changes map[string]audit.FieldChange, name string, old *string, next string) {
+	if old != nil && *old == next {
+		return
+	}
+	changes[name] = audit.FieldChange{From: auditValue(name, old), To: auditValue(name, &next)}
+}
+
+// plainAuditFields are profile fields that say nothing about who the member
+// is, so their values are audited as they are.
+var plainAuditFields = map[string]bool{"language": true}
+
+// auditValue is how a profile value appears in audit details: nil when unset,
+// as-is for plainAuditFields and otherwise as a keyed hash, which shows that
+// and when a value changed, and whether it went back to an earlier one,
+// without keeping personal data past a purge.
+func auditValue(name string, v *string) any {
+	if v == nil {
+		return nil
+	}
+	if plainAuditFields[name] {
+		return *v
+	}
+	return auditValueHash(*v)
 }
@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/consent"
+	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+	"workshop-be/internal/social"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown social login provider")
+	// ErrInvalidOAuthState covers unknown, expired, reused or browser-mismatched state.
+	ErrInvalidOAuthState = errors.New("invalid oauth state")
+	ErrSocialLogin       = errors.New("social login failed")
+	// ErrSocialEmailRequired is returned when a new account would be created
+	// but the provider did not share an email address.
+	ErrSocialEmailRequired = errors.New("email required")
+	// ErrSocialAccountExists is returned when the provider's email belongs to
+	// an existing account but the provider did not verify it, or the account
+	// never proved it controls the address.
+	ErrSocialAccountExists = errors.New("account exists")
+	ErrIdentityNotFound    = errors.New("identity not found")
+	// ErrLastLoginMethod stops unlinking the only way a user can sign in.
+	ErrLastLoginMethod = errors.New("last login method")
+)
+
+// oauthStateTTL bounds the time between starting a social login and the callback.
+const oauthStateTTL = 10 * time.Minute
+
+// Identity is an external account (provider + subject) linked to a user.
+type Identity struct {
+	ID          uint       `json:"-" gorm:"primaryKey"`
+	UserID      uint       `json:"-" gorm:"index;not null"`
+	Provider    string     `json:"provider" gorm:"size:20;not null;uniqueIndex:idx_identity_subject"`
+	Subject     string     `json:"-" gorm:"size:255;not null;uniqueIndex:idx_identity_subject"`
+	Email       string     `json:"email" gorm:"size:255"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+func (Identity) TableName() string { return "user_identities" }
+
+// OAuthState is a pending social login between start and callback. Only
+// hashes of the state and the browser binding cookie are stored.
+type OAuthState struct {
+	ID          uint   `gorm:"primaryKey"`
+	StateHash   string `gorm:"size:64;uniqueIndex;not null"`
+	BindingHash string `gorm:"size:64;not null"`
+	Provider    string `gorm:"size:20;not null"`
+	// Verifier is the PKCE code verifier, needed again at the callback.
+	Verifier  string          `gorm:"size:100;not null"`
+	Nonce     string          `gorm:"size:100;not null"`
+	Consents  []consent.Grant `gorm:"serializer:json"`
+	ExpiresAt time.Time       `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (OAuthState) TableName() string { return "oauth_states" }
+
+type SocialStartInput struct {
+	// Consents are applied if the callback creates an account or the user
+	// must re-accept updated documents, as for register and login.
+	Consents []consent.Grant `json:"consents"`
+}
+
+type SocialStartOutput struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type ProvidersOutput struct {
+	Providers []string `json:"providers" example:"line,google"`
+}
+
+// SocialProviders lists the enabled providers.
+func (s *Service) SocialProviders() *ProvidersOutput {
+	return &ProvidersOutput{Providers: s.Social.Names()}
+}
+
+// socialRedirectURI is the callback registered with every provider.
+func (s *Service) socialRedirectURI(provider string) string {
+	return strings.TrimSuffix(s.SocialRedirectBase, "/") + "/api/v1/auth/social/" + provider + "/callback"
+}
+
+// StartSocial begins an authorization-code + PKCE login. binding is a random
+// value the handler also stores in a cookie, so the callback only completes
+// in the browser that started it (login CSRF).
+func (s *Service) StartSocial(ctx context.Context, provider, binding string, in SocialStartInput) (*SocialStartOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.StartSocial")
+	defer span.End()
+	p, ok := s.Social.Get(provider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if err := s.consents.Validate(ctx, in.Consents); err != nil {
+		return nil, err
+	}
+	state, verifier, nonce := social.RandomString(32), social.RandomString(48), social.RandomString(24)
+	u, err := p.AuthURL(ctx, social.AuthRequest{
+		State:         state,
+		Nonce:         nonce,
+		CodeChallenge: social.Challenge(verifier),
+		RedirectURI:   s.socialRedirectURI(provider),
+	})
+	if err != nil {
+		return nil, err
+	}
+	d := db.MustGet().WithContext(ctx)
+	d.Where("expires_at < ?", time.Now()).Delete(&OAuthState{})
+	st := OAuthState{
+		StateHash:   sha256Hex(state),
+		BindingHash: sha256Hex(binding),
+		Provider:    provider,
+		Verifier:    verifier,
+		Nonce:       nonce,
+		Consents:    in.Consents,
+		ExpiresAt:   time.Now().Add(oauthStateTTL),
+	}
+	if err := d.Create(&st).Error; err != nil {
+		return nil, err
+	}
+	return &SocialStartOutput{AuthorizationURL: u}, nil
+}
+
+// SocialCallback completes a social login: it exchanges the code, then signs
+// in the user linked to the identity, links the identity to the account with
+// the same email when both the provider and the account have verified it, or
+// creates a new account.
+func (s *Service) SocialCallback(ctx context.Context, provider, code, state, binding string) (*LoginOutput, error) {
+	ctx, span := tracer.Start(ctx, "auth.Service.SocialCallback")
+	defer span.End()
+	p, ok := s.Social.Get(provider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	d := db.MustGet().WithContext(ctx)
+	var st OAuthState
+	if err := d.Where("state_hash = ?", sha256Hex(state)).Take(&st).Error; err != nil {
+		return nil, ErrInvalidOAuthState
+	}
+	// Single use, whatever the outcome.
+	if res := d.Delete(&st); res.Error != nil || res.RowsAffected == 0 {
+		return nil, ErrInvalidOAuthState
+	}
+	if st.Provider != provider || time.Now().After(st.ExpiresAt) || st.BindingHash != sha256Hex(binding) {
+		return nil, ErrInvalidOAuthState
+	}
+	id, err := p.Exchange(ctx, code, st.Verifier, s.socialRedirectURI(provider), st.Nonce)
+	if err != nil {
+		slog.WarnContext(ctx, "social login exchange failed", "provider", provider, "err", err)
+		return nil, ErrSocialLogin
+	}
+
+	var ident Identity
+	err = d.Where("provider = ? AND subject = ?", provider, id.Subject).Take(&ident).Error
+	switch {
+	case err == nil:
+		var user User
+		if err := d.Unscoped().First(&user, ident.UserID).Error; err != nil {
+			return nil, err
+		}
+		return s.socialLogin(ctx, &user, &ident, st.Consents)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+	if id.Email == "" {
+		return nil, ErrSocialEmailRequired
+	}
+	var user User
+	err = d.Unscoped().Where("email = ?", normalizeEmail(id.Email)).First(&user).Error
+	switch {
+	case err == nil:
+		if !id.EmailVerified {
+			s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, UserID: &user.ID, Details: map[string]string{"reason": "social_email_unverified", "method": provider}})
+			return nil, ErrSocialAccountExists
+		}
+		// Registering with a password proves nothing about the address;
+		// without this, someone could register a victim's email first and
+		// keep their password once the victim links a social account.
+		if user.EmailVerifiedAt == nil {
+			s.audit.Record(ctx, audit.Entry{Type: audit.EventLoginFailure, UserID: &user.ID, Details: map[string]string{"reason": "account_email_unverified", "method": provider}})
+			return nil, ErrSocialAccountExists
+		}
+		ident = Identity{UserID: user.ID, Provider: provider, Subject: id.Subject, Email: id.Email}
+		return s.socialLogin(ctx, &user, &ident, st.Consents)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+	return s.socialRegister(ctx, provider, id, st.Consents)
+}
+
+// socialRegister creates an account for a first-time social login. It has
+// no password; the user signs in through the provider.
+func (s *Service) socialRegister(ctx context.Context, provider string, id *social.Identity, grants []consent.Grant) (*LoginOutput, error) {
+	if !emailRegex.MatchString(id.Email) {
+		return nil, ErrSocialEmailRequired
+	}
+	if err := s.consents.ValidateRegistration(ctx, grants); err != nil {
+		return nil, err
+	}
+	user := User{Email: normalizeEmail(id.Email)}
+	if id.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	ident := Identity{Provider: provider, Subject: id.Subject, Email: id.Email}
+	err := db.MustGet().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.createUser(tx, &user); err != nil {
+			return err
+		}
+		ident.UserID = user.ID
+		if err := tx.Create(&ident).Error; err != nil {
+			return err
+		}
+		return s.consents.Save(ctx, tx, user.ID, grants, consent.SourceRegistration)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventRegister, ActorID: &user.ID, UserID: &user.ID, Details: map[string]string{"method": provider}})
+	s.consents.Audit(ctx, user.ID, grants, consent.SourceRegistration)
+	s.Events.Publish(ctx, events.Event{Type: events.UserRegistered, UserID: user.ID})
+	return s.socialLogin(ctx, &user, &ident, nil)
+}
+
+// socialLogin signs user in through ident. An ident without an ID is a new
+// link to an existing account, stored only once the login is allowed. The
+// OAuth state is used up by then; consents are saved once the account is
+// known to be live.
+func (s *Service) socialLogin(ctx context.Context, user *User, ident *Identity, grants []consent.Grant) (*LoginOutput, error) {
+	if err := s.checkOutstanding(ctx, user, grants); err != nil {
+		return nil, err
+	}
+	if user.DeletedAt.Valid {
+		if err := s.restore(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.saveGrants(ctx, user, grants); err != nil {
+		return nil, err
+	}
+	d := db.MustGet().WithContext(ctx)
+	now := time.Now()
+	if ident.ID == 0 {
+		ident.LastLoginAt = &now
+		if err := d.Create(ident).Error; err != nil {
+			return nil, err
+		}
+		s.audit.Record(ctx, audit.Entry{Type: audit.EventIdentityLink, ActorID: &user.ID, UserID: &user.ID, Details: map[string]string{"provider": ident.Provider, "reason": "verified_email_match"}})
+		s.notify(ctx, user.Email, "A sign-in method was added to your account",
+			fmt.Sprintf("Your %s account was linked to your Workshop account, and can now be used to sign in.\n\nIf this wasn't you, remove it under Profile > Linked accounts and change your password now.\n", ident.Provider))
+	} else {
+		d.Model(ident).Update("last_login_at", &now)
+	}
+	return s.issueLogin(ctx, user, ident.Provider)
+}
+
+type IdentitiesOutput struct {
+	Identities []Identity `json:"identities"`
+	// HasPassword is false for accounts created through social login.
+	HasPassword bool `json:"has_password"`
+}
+
+// Identities lists the social accounts linked to the user.
+func (s *Service) Identities(ctx context.Context, userID uint) (*IdentitiesOutput, error) {
+	d := db.MustGet().WithContext(ctx)
+	user, err := findUser(d, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := &IdentitiesOutput{Identities: []Identity{}, HasPassword: user.PasswordHash != ""}
+	if err := d.Where("user_id = ?", userID).Order("id ASC").Find(&out.Identities).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UnlinkIdentity removes the user's identity at provider, unless it is the
+// only way left to sign in.
+func (s *Service) UnlinkIdentity(ctx context.Context, userID uint, provider string) error {
+	d := db.MustGet().WithContext(ctx)
+	out, err := s.Identities(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var target *Identity
+	for i := range out.Identities {
+		if out.Identities[i].Provider == provider {
+			target = &out.Identities[i]
+		}
+	}
+	if target == nil {
+		return ErrIdentityNotFound
+	}
+	n, err := s.loginMethods(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return ErrLastLoginMethod
+	}
+	if err := d.Delete(target).Error; err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventIdentityUnlink, ActorID: &userID, UserID: &userID, Details: map[string]string{"provider": provider}})
+	return nil
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,123 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrInvalidImage    = errors.New("invalid image")
+	ErrDimensions      = errors.New("image dimensions out of range")
+)
+
+// Size is one rendition generated for every upload.
+type Size struct {
+	Name string
+	Px   int
+}
+
+// Sizes are the square renditions stored per avatar, largest first.
+var Sizes = []Size{{"large", 512}, {"medium", 256}, {"small", 64}}
+
+const (
+	minSide   = 32
+	maxSide   = 8000
+	maxPixels = 40_000_000
+	quality   = 85
+)
+
+// allowed lists sniffed content types we decode. The client-declared type is
+// ignored; only the bytes decide.
+var allowed = map[string]bool{"image/jpeg": true, "image/png": true, "image/webp": true}
+
+// render validates data and returns one JPEG per entry in Sizes. Output is
+// re-encoded from pixels, so EXIF (GPS, camera serials) and any other
+// metadata or trailing payload in the upload is dropped; JPEG orientation is
+// applied first so photos are not shown sideways.
+func render(data []byte) (map[string][]byte, error) {
+	if !allowed[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedType
+	}
+	// Check dimensions from the header before decoding to avoid allocating
+	// for decompression bombs.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width < minSide || cfg.Height < minSide || cfg.Width > maxSide || cfg.Height > maxSide || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrDimensions
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	orientation := jpegOrientation(data)
+	crop := squareCrop(src.Bounds())
+	out := make(map[string][]byte, len(Sizes))
+	for _, sz := range Sizes {
+		dst := image.NewRGBA(image.Rect(0, 0, sz.Px, sz.Px))
+		// White background so transparent PNG/WebP areas don't turn black in JPEG.
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, orient(dst, orientation), &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		out[sz.Name] = buf.Bytes()
+	}
+	return out, nil
+}
+
+// squareCrop returns the centered square of r. Because it is centered, it
+// commutes with the EXIF rotations/flips, so orientation can be applied to
+// the small output instead of the full-size source.
+func squareCrop(r image.Rectangle) image.Rectangle {
+	w, h := r.Dx(), r.Dy()
+	if w > h {
+		off := (w - h) / 2
+		return image.Rect(r.Min.X+off, r.Min.Y, r.Min.X+off+h, r.Max.Y)
+	}
+	off := (h - w) / 2
+	return image.Rect(r.Min.X, r.Min.Y+off, r.Max.X, r.Min.Y+off+w)
+}
+
+// orient applies an EXIF orientation (1-8) to a square image.
+func orient(src *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return src
+	}
+	n := src.Bounds().Dx()
+	dst := image.NewRGBA(src.Bounds())
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sx, sy int
+			switch o {
+			case 2: // mirror horizontal
+				sx, sy = n-1-x, y
+			case 3: // rotate 180
+				sx, sy = n-1-x, n-1-y
+			case 4: // mirror vertical
+				sx, sy = x, n-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 CW
+				sx, sy = y, n-1-x
+			case 7: // transverse
+				sx, sy = n-1-y, n-1-x
+			case 8: // rotate 90 CCW
+				sx, sy = n-1-y, x
+			}
+			dst.SetRGBA(x, y, src.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,90 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"workshop-be/pkg/blob"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("workshop-be/internal/avatar")
+
+var ErrTooLarge = errors.New("image too large")
+
+// DefaultMaxBytes caps uploads; it must stay below the server body limit.
+const DefaultMaxBytes = 2 << 20
+
+type Service struct {
+	store blob.Store
+	// MaxBytes is the largest accepted upload.
+	MaxBytes int64
+}
+
+func NewService(store blob.Store) *Service {
+	return &Service{store: store, MaxBytes: DefaultMaxBytes}
+}
+
+// Upload validates and resizes the image in r and stores every rendition. It
+// returns the avatar key: the common prefix of the stored objects, saved on
+// the user and passed to URLs/Delete. Each upload gets a fresh key so
+// clients and CDNs never serve a stale cached image.
+func (s *Service) Upload(ctx context.Context, userID uint, r io.Reader) (string, error) {
+	ctx, span := tracer.Start(ctx, "avatar.Service.Upload")
+	defer span.End()
+	data, err := io.ReadAll(io.LimitReader(r, s.MaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > s.MaxBytes {
+		return "", ErrTooLarge
+	}
+	images, err := render(data)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("avatars/%d/%s", userID, newID())
+	for _, sz := range Sizes {
+		if err := s.store.Put(ctx, objectKey(key, sz), bytes.NewReader(images[sz.Name]), "image/jpeg"); err != nil {
+			s.Delete(ctx, key)
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// URLs maps size names to public URLs for an avatar key.
+func (s *Service) URLs(key string) map[string]string {
+	out := make(map[string]string, len(Sizes))
+	for _, sz := range Sizes {
+		out[sz.Name] = s.store.URL(objectKey(key, sz))
+	}
+	return out
+}
+
+// Delete removes every rendition of key. Failures are logged: a leftover
+// file is harmless once no user references it.
+func (s *Service) Delete(ctx context.Context, key string) {
+	for _, sz := range Sizes {
+		if err := s.store.Delete(ctx, objectKey(key, sz)); err != nil {
+			slog.WarnContext(ctx, "avatar delete failed", "key", key, "size", sz.Name, "err", err)
+		}
+	}
+}
+
+func objectKey(key string, sz Size) string {
+	return fmt.Sprintf("%s-%d.jpg", key, sz.Px)
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
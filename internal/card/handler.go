@@ -0,0 +1,104 @@
+package card
+
+import (
+	"net/http"
+
+	"workshop-be/internal/httpx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GetCard godoc
+// @Summary Get my membership card
+// @Description Returns a signed payload valid for a few minutes and its QR code. With format=png the QR code image is returned directly. Refresh before expires_at.
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Produce png
+// @Param format query string false "json (default) or png"
+// @Success 200 {object} Card
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/card [get]
+func (h *Handler) GetCard(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	card, err := h.svc.Issue(c.UserContext(), uid)
+	if err != nil {
+		if err == ErrMemberNotFound {
+			return httpx.WriteError(c, http.StatusNotFound, "USER_NOT_FOUND", "user not found")
+		}
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	// The payload is a short-lived credential; keep it out of shared caches.
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if c.Query("format") == "png" {
+		png, err := QRCode(card.Payload)
+		if err != nil {
+			return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+		c.Set(fiber.HeaderContentType, "image/png")
+		return c.Send(png)
+	}
+	return c.JSON(card)
+}
+
+// VerifyCard godoc
+// @Summary Verify a scanned membership card (staff)
+// @Description Validates the signature and expiry of a scanned card payload and returns the member it belongs to.
+// @Tags Staff
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body VerifyInput true "scanned payload"
+// @Success 200 {object} VerifyOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Router /api/v1/cards/verify [post]
+func (h *Handler) VerifyCard(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in VerifyInput
+	if err := c.BodyParser(&in); err != nil || in.Payload == "" {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.Verify(c.UserContext(), uid, in.Payload)
+	if err != nil {
+		switch err {
+		case ErrInvalidPayload:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_CARD", "card payload is not valid")
+		case ErrExpired:
+			return httpx.WriteError(c, http.StatusBadRequest, "CARD_EXPIRED", "card payload expired; ask the member to refresh the card")
+		case ErrMemberNotFound:
+			return httpx.WriteError(c, http.StatusNotFound, "MEMBER_NOT_FOUND", "member not found")
+		default:
+			return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.JSON(out)
+}
+
+// RegisterProfileRoutes mounts the member's card on the profile group.
+func RegisterProfileRoutes(r fiber.Router, svc *Service) {
+	r.Get("/card", NewHandler(svc).GetCard)
+}
+
+// RegisterVerifyRoutes mounts card verification; r must restrict access to
+// store staff.
+func RegisterVerifyRoutes(r fiber.Router, svc *Service) {
+	r.Post("/verify", NewHandler(svc).VerifyCard)
+}
@@ -0,0 +1,247 @@
+// Package card issues the digital membership card: a short-lived signed
+// payload shown as a QR code, which store staff scan and verify server-side.
+package card
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/db"
+
+	"github.com/skip2/go-qrcode"
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+)
+
+var tracer = otel.Tracer("workshop-be/internal/card")
+
+var (
+	ErrInvalidPayload = errors.New("invalid card payload")
+	ErrExpired        = errors.New("card payload expired")
+	ErrMemberNotFound = errors.New("member not found")
+)
+
+// MembershipCode is the code printed on the card for user id, e.g. LBK001234.
+func MembershipCode(userID uint) string {
+	return fmt.Sprintf("LBK%06d", userID)
+}
+
+// payloadVersion prefixes payloads so the format can change later.
+const payloadVersion = "MC1"
+
+// sigLen is the truncated HMAC length; 128 bits keeps the QR code small.
+const sigLen = 16
+
+const defaultTTL = 5 * time.Minute
+
+type Service struct {
+	key   []byte
+	audit *audit.Service
+	// TTL is how long a card payload stays valid; apps refresh before expiry.
+	TTL time.Duration
+	now func() time.Time
+}
+
+// NewService signs payloads with key (use auth.DeriveKey("membership-card")).
+func NewService(key []byte) *Service {
+	return &Service{key: key, audit: audit.NewService(), TTL: defaultTTL, now: time.Now}
+}
+
+// member is the slice of the users row shown on the card.
+type member struct {
+	ID              uint
+	FirstName       *string
+	LastName        *string
+	MembershipCode  *string
+	MembershipLevel string
+	Points          int
+}
+
+func (m *member) name() string {
+	var parts []string
+	if m.FirstName != nil {
+		parts = append(parts, *m.FirstName)
+	}
+	if m.LastName != nil {
+		parts = append(parts, *m.LastName)
+	}
+	return strings.Join(parts, " ")
+}
+
+// shortName is the first name and last initial, enough for staff to match
+// the customer without reading out their full name.
+func (m *member) shortName() string {
+	name := ""
+	if m.FirstName != nil {
+		name = *m.FirstName
+	}
+	if m.LastName != nil {
+		if r := []rune(*m.LastName); len(r) > 0 {
+			name = strings.TrimSpace(name + " " + string(r[0]) + ".")
+		}
+	}
+	return name
+}
+
+const memberColumns = "id, first_name, last_name, membership_code, membership_level, points"
+
+func loadMember(d *gorm.DB, query string, arg any) (*member, error) {
+	var m member
+	err := d.Table("users").Select(memberColumns).Where(query+" AND deleted_at IS NULL", arg).Take(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMemberNotFound
+	}
+	return &m, err
+}
+
+type Card struct {
+	MembershipCode string `json:"membership_code" example:"LBK001234"`
+	Name           string `json:"name"`
+	Tier           string `json:"tier" example:"Gold"`
+	Points         int    `json:"points"`
+	// Payload is the signed text encoded in the QR code; clients may also
+	// render it as a Code 128 barcode.
+	Payload   string    `json:"payload" example:"MC1.LBK001234.1767225600.Ab3dEf..."`
+	ExpiresAt time.Time `json:"expires_at"`
+	// QRPNG is the QR code as a data URL (image/png, 256x256).
+	QRPNG string `json:"qr_png"`
+}
+
+// Issue returns a fresh card for the user, provisioning their membership
+// code if they registered before codes were assigned.
+func (s *Service) Issue(ctx context.Context, userID uint) (*Card, error) {
+	ctx, span := tracer.Start(ctx, "card.Service.Issue")
+	defer span.End()
+	d := db.MustGet().WithContext(ctx)
+	m, err := loadMember(d, "id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	if m.MembershipCode == nil {
+		code := MembershipCode(m.ID)
+		if err := d.Table("users").Where("id = ? AND membership_code IS NULL", m.ID).Update("membership_code", code).Error; err != nil {
+			return nil, err
+		}
+		m.MembershipCode = &code
+	}
+	exp := s.now().Add(s.TTL).Truncate(time.Second)
+	payload := s.sign(*m.MembershipCode, exp.Unix())
+	png, err := QRCode(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Card{
+		MembershipCode: *m.MembershipCode,
+		Name:           m.name(),
+		Tier:           m.MembershipLevel,
+		Points:         m.Points,
+		Payload:        payload,
+		ExpiresAt:      exp,
+		QRPNG:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// QRCode renders payload as a 256x256 PNG.
+func QRCode(payload string) ([]byte, error) {
+	return qrcode.Encode(payload, qrcode.Medium, 256)
+}
+
+func (s *Service) sign(code string, exp int64) string {
+	msg := payloadVersion + "." + code + "." + strconv.FormatInt(exp, 10)
+	return msg + "." + base64.RawURLEncoding.EncodeToString(s.mac(msg))
+}
+
+func (s *Service) mac(msg string) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(msg))
+	return m.Sum(nil)[:sigLen]
+}
+
+type VerifyInput struct {
+	Payload string `json:"payload" example:"MC1.LBK001234.1767225600.Ab3dEf..."`
+}
+
+type VerifyOutput struct {
+	Valid          bool      `json:"valid"`
+	MembershipCode string    `json:"membership_code" example:"LBK001234"`
+	Name           string    `json:"name" example:"Somchai J."`
+	Tier           string    `json:"tier" example:"Gold"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// Verify checks a scanned payload's signature and expiry and returns the
+// member it belongs to. Each scan is audited against the scanning staff.
+func (s *Service) Verify(ctx context.Context, actorID uint, payload string) (*VerifyOutput, error) {
+	ctx, span := tracer.Start(ctx, "card.Service.Verify")
+	defer span.End()
+	code, exp, err := s.parse(payload)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loadMember(db.MustGet().WithContext(ctx), "membership_code = ?", code)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, ActorID: &actorID, UserID: &m.ID, Details: map[string]any{"action": "card.verify", "membership_code": code}})
+	return &VerifyOutput{
+		Valid:          true,
+		MembershipCode: code,
+		Name:           m.shortName(),
+		Tier:           m.MembershipLevel,
+		ExpiresAt:      exp,
+	}, nil
+}
+
+var codeRegex = regexp.MustCompile(`^LBK[0-9]{6,}$`)
+
+// Lookup resolves what a scanner or cashier entered - a card payload or a
+// bare membership code - to the member's user id and membership code.
+// Payloads must be valid and unexpired; bare codes are for typed-in entry.
+func (s *Service) Lookup(ctx context.Context, scanned string) (uint, string, error) {
+	code := strings.ToUpper(strings.TrimSpace(scanned))
+	if strings.HasPrefix(code, payloadVersion+".") {
+		c, _, err := s.parse(scanned)
+		if err != nil {
+			return 0, "", err
+		}
+		code = c
+	} else if !codeRegex.MatchString(code) {
+		return 0, "", ErrInvalidPayload
+	}
+	m, err := loadMember(db.MustGet().WithContext(ctx), "membership_code = ?", code)
+	if err != nil {
+		return 0, "", err
+	}
+	return m.ID, code, nil
+}
+
+// parse checks a payload's signature and expiry.
+func (s *Service) parse(payload string) (string, time.Time, error) {
+	parts := strings.Split(strings.TrimSpace(payload), ".")
+	if len(parts) != 4 || parts[0] != payloadVersion {
+		return "", time.Time{}, ErrInvalidPayload
+	}
+	exp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidPayload
+	}
+	// Strict: otherwise the unused low bits of the last character are
+	// ignored and several spellings of one signature would verify.
+	sig, err := base64.RawURLEncoding.Strict().DecodeString(parts[3])
+	if err != nil || !hmac.Equal(sig, s.mac(strings.Join(parts[:3], "."))) {
+		return "", time.Time{}, ErrInvalidPayload
+	}
+	if s.now().Unix() >= exp {
+		return "", time.Time{}, ErrExpired
+	}
+	return parts[1], time.Unix(exp, 0).UTC(), nil
+}
@@ -0,0 +1,79 @@
+package card_test
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/auth"
+	"workshop-be/internal/card"
+	"workshop-be/internal/db"
+)
+
+// TestVerifyTampered checks that a card payload verifies only as issued:
+// any change to the member code, expiry or signature is rejected.
+func TestVerifyTampered(t *testing.T) {
+	ctx := context.Background()
+	db.Init(filepath.Join(t.TempDir(), "app.db"), &auth.User{}, &audit.Event{})
+	d := db.MustGet()
+	for _, email := range []string{"member@example.com", "other@example.com"} {
+		if err := d.Create(&auth.User{Email: email, PasswordHash: "x"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := card.NewService([]byte("card key"))
+	c, err := s.Issue(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Issue(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := s.Verify(ctx, 99, c.Payload); err != nil || out.MembershipCode != card.MembershipCode(1) {
+		t.Fatalf("Verify(issued) = %+v, %v", out, err)
+	}
+
+	parts := strings.Split(c.Payload, ".")
+	with := func(i int, v string) string {
+		p := append([]string(nil), parts...)
+		p[i] = v
+		return strings.Join(p, ".")
+	}
+	// Flipping an unused low bit of the last character decodes to the same
+	// bytes; only the canonical spelling may verify.
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	sig := []byte(parts[3])
+	sig[len(sig)-1] = alphabet[strings.IndexByte(alphabet, sig[len(sig)-1])^1]
+	exp, _ := strconv.ParseInt(parts[2], 10, 64)
+	other, err := card.NewService([]byte("another key")).Issue(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, payload := range map[string]string{
+		"other member":        with(1, card.MembershipCode(2)),
+		"later expiry":        with(2, strconv.FormatInt(exp+3600, 10)),
+		"changed signature":   with(3, strings.Repeat("A", len(parts[3]))),
+		"signature spelling":  with(3, string(sig)),
+		"no signature":        strings.Join(parts[:3], "."),
+		"version":             with(0, "MC2"),
+		"signed with another": other.Payload,
+	} {
+		if _, err := s.Verify(ctx, 99, payload); err != card.ErrInvalidPayload {
+			t.Errorf("%s: Verify(%q) err = %v, want ErrInvalidPayload", name, payload, err)
+		}
+	}
+
+	s.TTL = -time.Second
+	expired, err := s.Issue(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(ctx, 99, expired.Payload); err != card.ErrExpired {
+		t.Errorf("Verify(expired) err = %v, want ErrExpired", err)
+	}
+}
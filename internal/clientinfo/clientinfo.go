@@ -0,0 +1,67 @@
+package clientinfo
+
+import (
+	"context"
+	"strings"
+
+	"workshop-be/internal/logging"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Info describes the caller of the current request, for records that must say
+// where an action came from (audit log, consents, login history).
+type Info struct {
+	IP        string
+	UserAgent string
+	RequestID string
+	// DeviceID is the client-generated install id from the X-Device-ID
+	// header; empty when the client doesn't send one.
+	DeviceID string
+}
+
+type ctxKey struct{}
+
+func With(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// From returns the Info stored by Middleware; zero value outside a request.
+func From(ctx context.Context) Info {
+	info, _ := ctx.Value(ctxKey{}).(Info)
+	if info.RequestID == "" {
+		info.RequestID = logging.RequestID(ctx)
+	}
+	return info
+}
+
+// maxUserAgent matches the column size used by tables that store it.
+const maxUserAgent = 255
+
+// HeaderDeviceID carries a stable per-install id generated by the app.
+const HeaderDeviceID = "X-Device-ID"
+
+const maxDeviceID = 128
+
+// Middleware captures IP, User-Agent and device id into the user context. Register it
+// after middleware.RequestID.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ua := c.Get(fiber.HeaderUserAgent)
+		if len(ua) > maxUserAgent {
+			ua = ua[:maxUserAgent]
+		}
+		device := strings.TrimSpace(c.Get(HeaderDeviceID))
+		if len(device) > maxDeviceID {
+			device = device[:maxDeviceID]
+		}
+		ctx := c.UserContext()
+		c.SetUserContext(With(ctx, Info{
+			IP:        strings.Clone(c.IP()),
+			UserAgent: strings.Clone(ua),
+			RequestID: logging.RequestID(ctx),
+			DeviceID:  strings.Clone(device),
+		}))
+		return c.Next()
+	}
+}
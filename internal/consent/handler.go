@@ -0,0 +1,164 @@
+package consent
+
+import (
+	"net/http"
+
+	"workshop-be/internal/httpx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+type DocumentsOutput struct {
+	Documents []Document `json:"documents"`
+}
+
+// ListDocuments godoc
+// @Summary List current legal documents
+// @Description Current version of each legal document. Clients send these versions back as consents at registration, login (when re-acceptance is required) and from the profile.
+// @Tags Legal
+// @Produce json
+// @Success 200 {object} DocumentsOutput
+// @Router /api/v1/legal/documents [get]
+func (h *Handler) ListDocuments(c *fiber.Ctx) error {
+	docs, err := h.svc.Current(c.UserContext())
+	if err != nil {
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(DocumentsOutput{Documents: docs})
+}
+
+// GetConsents godoc
+// @Summary Get my consents
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} StatusOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/consents [get]
+func (h *Handler) GetConsents(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.Status(c.UserContext(), uid)
+	if err != nil {
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// UpdateConsents godoc
+// @Summary Update my consents
+// @Description Grant or withdraw optional consents (marketing), or accept a new version of a required document. Required consents cannot be withdrawn.
+// @Tags Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body UpdateInput true "consents"
+// @Success 200 {object} StatusOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/consents [put]
+func (h *Handler) UpdateConsents(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in UpdateInput
+	if err := c.BodyParser(&in); err != nil || len(in.Consents) == 0 {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.Update(c.UserContext(), uid, in)
+	if err != nil {
+		if code, msg, ok := GrantError(err); ok {
+			return httpx.WriteError(c, http.StatusBadRequest, code, msg)
+		}
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// PublishDocument godoc
+// @Summary Publish legal document version (admin)
+// @Description Once effective, users who accepted an older terms or privacy version must re-accept at their next login.
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body PublishInput true "document"
+// @Success 201 {object} Document
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/legal-documents [post]
+func (h *Handler) PublishDocument(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in PublishInput
+	if err := c.BodyParser(&in); err != nil {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	doc, err := h.svc.Publish(c.UserContext(), uid, in)
+	if err != nil {
+		switch err {
+		case ErrUnknownPurpose:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PURPOSE", "purpose must be terms, privacy or marketing")
+		case ErrInvalidDocument:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_DOCUMENT", "version is required; title and url are limited to 255 and 500 characters")
+		case ErrDocumentExists:
+			return httpx.WriteError(c, http.StatusConflict, "DOCUMENT_EXISTS", "version already published")
+		default:
+			return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusCreated).JSON(doc)
+}
+
+// GrantError maps consent validation errors to an error code and message so
+// the auth handlers report them the same way as the profile endpoint.
+func GrantError(err error) (code, msg string, ok bool) {
+	switch err {
+	case ErrUnknownPurpose:
+		return "INVALID_PURPOSE", "purpose must be terms, privacy or marketing", true
+	case ErrDuplicatePurpose:
+		return "INVALID_CONSENT", "each purpose may be listed once", true
+	case ErrNoDocument:
+		return "INVALID_CONSENT", "no document is published for that purpose", true
+	case ErrVersionMismatch:
+		return "CONSENT_VERSION_MISMATCH", "document version is not current; see /api/v1/legal/documents", true
+	case ErrRequiredConsent:
+		return "CONSENT_REQUIRED", "accepting the current terms and privacy policy is required", true
+	case ErrCannotWithdraw:
+		return "CONSENT_REQUIRED", "terms and privacy consent cannot be withdrawn; delete the account instead", true
+	}
+	return "", "", false
+}
+
+// RegisterPublicRoutes mounts the unauthenticated document listing.
+func RegisterPublicRoutes(r fiber.Router, svc *Service) {
+	r.Get("/legal/documents", NewHandler(svc).ListDocuments)
+}
+
+// RegisterProfileRoutes mounts the authenticated endpoints on the profile group.
+func RegisterProfileRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	r.Get("/consents", h.GetConsents)
+	r.Put("/consents", h.UpdateConsents)
+}
+
+// RegisterAdminRoutes mounts document publishing on the admin group.
+func RegisterAdminRoutes(r fiber.Router, svc *Service) {
+	r.Post("/legal-documents", NewHandler(svc).PublishDocument)
+}
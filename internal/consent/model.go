@@ -0,0 +1,56 @@
+package consent
+
+import "time"
+
+// Purposes a user can consent to. Terms and privacy are required to hold an
+// account; marketing is optional.
+const (
+	PurposeTerms     = "terms"
+	PurposePrivacy   = "privacy"
+	PurposeMarketing = "marketing"
+)
+
+// Sources describe where a consent record was captured.
+const (
+	SourceRegistration = "registration"
+	SourceLogin        = "login"
+	SourceProfile      = "profile"
+)
+
+var purposes = map[string]bool{PurposeTerms: true, PurposePrivacy: true, PurposeMarketing: true}
+
+// Required reports whether holding an account depends on accepting purpose.
+func Required(purpose string) bool {
+	return purpose == PurposeTerms || purpose == PurposePrivacy
+}
+
+// Document is a versioned legal text. The current version of a purpose is the
+// one with the latest EffectiveAt not in the future.
+type Document struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Purpose     string    `json:"purpose" gorm:"size:20;not null;uniqueIndex:idx_legal_documents_purpose_version"`
+	Version     string    `json:"version" gorm:"size:50;not null;uniqueIndex:idx_legal_documents_purpose_version"`
+	Title       string    `json:"title" gorm:"size:255"`
+	URL         string    `json:"url" gorm:"size:500"`
+	EffectiveAt time.Time `json:"effective_at" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (Document) TableName() string { return "legal_documents" }
+
+// Record is one consent decision. Records are appended, never edited: the
+// latest record per (user, purpose) is the current state, older ones are proof.
+type Record struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	UserID          uint      `json:"user_id" gorm:"index:idx_consent_records_user_purpose;not null"`
+	Purpose         string    `json:"purpose" gorm:"index:idx_consent_records_user_purpose;size:20;not null"`
+	DocumentID      uint      `json:"document_id"`
+	DocumentVersion string    `json:"document_version" gorm:"size:50"`
+	Granted         bool      `json:"granted"`
+	Source          string    `json:"source" gorm:"size:20"`
+	IP              string    `json:"ip" gorm:"size:64"`
+	UserAgent       string    `json:"user_agent" gorm:"size:255"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (Record) TableName() string { return "consent_records" }
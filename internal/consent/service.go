@@ -0,0 +1,364 @@
+package consent
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/clientinfo"
+	"workshop-be/internal/db"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownPurpose   = errors.New("unknown purpose")
+	ErrVersionMismatch  = errors.New("document version is not current")
+	ErrRequiredConsent  = errors.New("required consent missing")
+	ErrCannotWithdraw   = errors.New("required consent cannot be withdrawn")
+	ErrInvalidDocument  = errors.New("invalid document")
+	ErrDocumentExists   = errors.New("document version already exists")
+	ErrNoDocument       = errors.New("no document published for purpose")
+	ErrDuplicatePurpose = errors.New("purpose listed more than once")
+)
+
+type Service struct {
+	audit *audit.Service
+}
+
+func NewService() *Service { return &Service{audit: audit.NewService()} }
+
+// Grant is a consent decision submitted by a client for the named document version.
+type Grant struct {
+	Purpose string `json:"purpose" example:"terms"`
+	Version string `json:"version" example:"2026-01"`
+	Granted bool   `json:"granted"`
+}
+
+// Current returns the document in force for each purpose that has one.
+func (s *Service) Current(ctx context.Context) ([]Document, error) {
+	var docs []Document
+	if err := db.MustGet().WithContext(ctx).
+		Where("effective_at <= ?", time.Now()).
+		Order("purpose ASC, effective_at DESC, id DESC").
+		Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]Document, 0, len(purposes))
+	seen := map[string]bool{}
+	for _, d := range docs {
+		if !seen[d.Purpose] {
+			seen[d.Purpose] = true
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (s *Service) currentByPurpose(ctx context.Context) (map[string]Document, error) {
+	docs, err := s.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]Document, len(docs))
+	for _, d := range docs {
+		m[d.Purpose] = d
+	}
+	return m, nil
+}
+
+// Outstanding lists current required documents the user has not accepted at
+// their current version. Empty when nothing is published.
+func (s *Service) Outstanding(ctx context.Context, userID uint) ([]Document, error) {
+	current, err := s.currentByPurpose(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := s.latest(ctx, db.MustGet().WithContext(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+	var missing []Document
+	for purpose, doc := range current {
+		if !Required(purpose) {
+			continue
+		}
+		r, ok := latest[purpose]
+		if !ok || !r.Granted || r.DocumentID != doc.ID {
+			missing = append(missing, doc)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Purpose < missing[j].Purpose })
+	return missing, nil
+}
+
+// Describe renders documents as "purpose@version" for error messages.
+func Describe(docs []Document) string {
+	parts := make([]string, len(docs))
+	for i, d := range docs {
+		parts[i] = d.Purpose + "@" + d.Version
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Validate checks that grants name known purposes at their current versions
+// and withdraw no required purpose.
+func (s *Service) Validate(ctx context.Context, grants []Grant) error {
+	current, err := s.currentByPurpose(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateGrants(current, grants); err != nil {
+		return err
+	}
+	return rejectWithdrawals(grants)
+}
+
+// ValidateRegistration checks grants submitted at sign-up: every current
+// required document must be granted at its current version.
+func (s *Service) ValidateRegistration(ctx context.Context, grants []Grant) error {
+	current, err := s.currentByPurpose(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateGrants(current, grants); err != nil {
+		return err
+	}
+	return requireAll(current, grants)
+}
+
+// Covers reports whether grants accept every document in missing.
+func Covers(missing []Document, grants []Grant) bool {
+	for _, doc := range missing {
+		ok := false
+		for _, g := range grants {
+			if g.Purpose == doc.Purpose && g.Version == doc.Version && g.Granted {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Save appends records for grants using tx, so callers can make them part of a
+// wider transaction (e.g. user creation). Grants must already be validated;
+// withdrawing a required purpose is refused here whatever the caller checked.
+// Save does not audit; call Audit once the transaction has committed.
+func (s *Service) Save(ctx context.Context, tx *gorm.DB, userID uint, grants []Grant, source string) error {
+	if len(grants) == 0 {
+		return nil
+	}
+	if err := rejectWithdrawals(grants); err != nil {
+		return err
+	}
+	current, err := s.currentByPurpose(ctx)
+	if err != nil {
+		return err
+	}
+	info := clientinfo.From(ctx)
+	records := make([]Record, 0, len(grants))
+	for _, g := range grants {
+		doc := current[g.Purpose]
+		records = append(records, Record{
+			UserID: userID, Purpose: g.Purpose, DocumentID: doc.ID, DocumentVersion: doc.Version,
+			Granted: g.Granted, Source: source, IP: info.IP, UserAgent: info.UserAgent,
+		})
+	}
+	return tx.WithContext(ctx).Create(&records).Error
+}
+
+// Audit records a consent.update event for grants saved by Save.
+func (s *Service) Audit(ctx context.Context, userID uint, grants []Grant, source string) {
+	if len(grants) == 0 {
+		return
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventConsentUpdate, ActorID: &userID, UserID: &userID, Details: map[string]any{"source": source, "grants": grants}})
+}
+
+// Status is the current consent state for one purpose.
+type Status struct {
+	Purpose        string     `json:"purpose"`
+	Required       bool       `json:"required"`
+	Granted        bool       `json:"granted"`
+	Version        string     `json:"version"`
+	CurrentVersion string     `json:"current_version"`
+	UpToDate       bool       `json:"up_to_date"`
+	UpdatedAt      *time.Time `json:"updated_at"`
+}
+
+type StatusOutput struct {
+	Consents []Status `json:"consents"`
+}
+
+type UpdateInput struct {
+	Consents []Grant `json:"consents"`
+}
+
+// Status returns the user's consent state for every purpose with a current document.
+func (s *Service) Status(ctx context.Context, userID uint) (*StatusOutput, error) {
+	current, err := s.currentByPurpose(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := s.latest(ctx, db.MustGet().WithContext(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+	out := &StatusOutput{Consents: []Status{}}
+	for _, purpose := range []string{PurposeTerms, PurposePrivacy, PurposeMarketing} {
+		doc, ok := current[purpose]
+		if !ok {
+			continue
+		}
+		st := Status{Purpose: purpose, Required: Required(purpose), CurrentVersion: doc.Version}
+		if r, ok := latest[purpose]; ok {
+			st.Granted = r.Granted
+			st.Version = r.DocumentVersion
+			st.UpToDate = r.DocumentID == doc.ID
+			t := r.CreatedAt
+			st.UpdatedAt = &t
+		}
+		out.Consents = append(out.Consents, st)
+	}
+	return out, nil
+}
+
+// Update records consent changes from the profile. Required purposes can be
+// (re-)accepted but not withdrawn; withdrawing means deleting the account.
+func (s *Service) Update(ctx context.Context, userID uint, in UpdateInput) (*StatusOutput, error) {
+	current, err := s.currentByPurpose(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateGrants(current, in.Consents); err != nil {
+		return nil, err
+	}
+	if err := s.Save(ctx, db.MustGet(), userID, in.Consents, SourceProfile); err != nil {
+		return nil, err
+	}
+	s.Audit(ctx, userID, in.Consents, SourceProfile)
+	return s.Status(ctx, userID)
+}
+
+// History returns every consent record of the user, oldest first.
+func (s *Service) History(ctx context.Context, userID uint) ([]Record, error) {
+	var rows []Record
+	err := db.MustGet().WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&rows).Error
+	return rows, err
+}
+
+// Anonymize clears the IP and user agent of a purged user's consent records,
+// using the purge transaction tx. The records themselves stay as evidence of
+// what was agreed and when.
+func (s *Service) Anonymize(ctx context.Context, tx *gorm.DB, userID uint) error {
+	return tx.WithContext(ctx).Model(&Record{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]any{"ip": "", "user_agent": ""}).Error
+}
+
+type PublishInput struct {
+	Purpose string `json:"purpose" example:"terms"`
+	Version string `json:"version" example:"2026-01"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	// EffectiveAt defaults to now; a future time schedules the version.
+	EffectiveAt *time.Time `json:"effective_at"`
+}
+
+// Publish adds a document version. Once effective, users who accepted an
+// older required version must re-accept at their next login.
+func (s *Service) Publish(ctx context.Context, actorID uint, in PublishInput) (*Document, error) {
+	in.Version = strings.TrimSpace(in.Version)
+	if !purposes[in.Purpose] {
+		return nil, ErrUnknownPurpose
+	}
+	if in.Version == "" || len(in.Version) > 50 || len(in.Title) > 255 || len(in.URL) > 500 {
+		return nil, ErrInvalidDocument
+	}
+	doc := Document{Purpose: in.Purpose, Version: in.Version, Title: in.Title, URL: in.URL, EffectiveAt: time.Now()}
+	if in.EffectiveAt != nil {
+		doc.EffectiveAt = *in.EffectiveAt
+	}
+	d := db.MustGet().WithContext(ctx)
+	var n int64
+	if err := d.Model(&Document{}).Where("purpose = ? AND version = ?", doc.Purpose, doc.Version).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, ErrDocumentExists
+	}
+	if err := d.Create(&doc).Error; err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, ActorID: &actorID, Details: map[string]any{
+		"action": "legal_document.publish", "purpose": doc.Purpose, "version": doc.Version, "effective_at": doc.EffectiveAt,
+	}})
+	return &doc, nil
+}
+
+// latest returns the newest record per purpose for the user.
+func (s *Service) latest(ctx context.Context, d *gorm.DB, userID uint) (map[string]Record, error) {
+	var rows []Record
+	if err := d.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	m := map[string]Record{}
+	for _, r := range rows {
+		if _, ok := m[r.Purpose]; !ok {
+			m[r.Purpose] = r
+		}
+	}
+	return m, nil
+}
+
+func validateGrants(current map[string]Document, grants []Grant) error {
+	seen := map[string]bool{}
+	for _, g := range grants {
+		if !purposes[g.Purpose] {
+			return ErrUnknownPurpose
+		}
+		if seen[g.Purpose] {
+			return ErrDuplicatePurpose
+		}
+		seen[g.Purpose] = true
+		doc, ok := current[g.Purpose]
+		if !ok {
+			return ErrNoDocument
+		}
+		if g.Version != doc.Version {
+			return ErrVersionMismatch
+		}
+	}
+	return nil
+}
+
+// rejectWithdrawals refuses grants that withdraw a required purpose; only
+// deleting the account ends those.
+func rejectWithdrawals(grants []Grant) error {
+	for _, g := range grants {
+		if Required(g.Purpose) && !g.Granted {
+			return ErrCannotWithdraw
+		}
+	}
+	return nil
+}
+
+func requireAll(current map[string]Document, grants []Grant) error {
+	var missing []Document
+	for purpose, doc := range current {
+		if Required(purpose) {
+			missing = append(missing, doc)
+		}
+	}
+	if !Covers(missing, grants) {
+		return ErrRequiredConsent
+	}
+	return nil
+}
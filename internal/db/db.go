@@ -1,17 +1,25 @@
 package db
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"workshop-be/internal/logging"
 )
 
 var DB *gorm.DB
 
+// registered keeps the models passed to Init so readiness checks can detect schema drift.
+var registered []interface{}
+
 func Init(dbPath string, models ...interface{}) {
 	if dbPath == "" {
 		dbPath = "data/app.db"
@@ -19,18 +27,30 @@ func Init(dbPath string, models ...interface{}) {
 	dir := filepath.Dir(dbPath)
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			log.Fatalf("cannot create data dir: %v", err)
+			logging.Fatal("cannot create data dir", "err", err)
 		}
 	}
-	database, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	// GORM's default logger prints SQL with bound values; keep it to slow-query
+	// warnings and errors only, routed through slog.
+	database, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+		Logger: gormlogger.New(slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn), gormlogger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  gormlogger.Warn,
+			IgnoreRecordNotFoundError: true,
+			ParameterizedQueries:      true,
+		}),
+		// Map driver constraint errors to gorm.ErrDuplicatedKey and friends.
+		TranslateError: true,
+	})
 	if err != nil {
-		log.Fatalf("failed to connect database: %v", err)
+		logging.Fatal("failed to connect database", "err", err)
 	}
 	if err := database.AutoMigrate(models...); err != nil {
-		log.Fatalf("auto migrate failed: %v", err)
+		logging.Fatal("auto migrate failed", "err", err)
 	}
 	DB = database
-	log.Printf("database initialized at %s", dbPath)
+	registered = models
+	slog.Info("database initialized", "path", dbPath)
 }
 
 func MustGet() *gorm.DB {
@@ -40,6 +60,58 @@ func MustGet() *gorm.DB {
 	return DB
 }
 
+// Ping verifies the underlying connection is usable.
+func Ping(ctx context.Context) error {
+	if DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("get raw db: %w", err)
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// PendingMigrations lists tables or columns of the registered models that are
+// missing from the database (e.g. file replaced or migrated by an older build).
+// It reads each table's columns in one query, so it is cheap enough per probe.
+func PendingMigrations(ctx context.Context) ([]string, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var pending []string
+	d := DB.WithContext(ctx)
+	m := d.Migrator()
+	for _, model := range registered {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		stmt := &gorm.Statement{DB: d}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("parse model: %w", err)
+		}
+		table := stmt.Schema.Table
+		if !m.HasTable(model) {
+			pending = append(pending, table)
+			continue
+		}
+		cols, err := m.ColumnTypes(model)
+		if err != nil {
+			return nil, fmt.Errorf("columns of %s: %w", table, err)
+		}
+		have := make(map[string]bool, len(cols))
+		for _, c := range cols {
+			have[c.Name()] = true
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !have[field.DBName] {
+				pending = append(pending, table+"."+field.DBName)
+			}
+		}
+	}
+	return pending, nil
+}
+
 func Close() error {
 	if DB == nil {
 		return nil
@@ -50,3 +122,32 @@ func Close() error {
 	}
 	return sqlDB.Close()
 }
+
+// RegisterHooks wraps every kind of GORM statement (create, query, update,
+// delete, row, raw) with before and after callbacks built per operation,
+// registered as "<name>:before_<op>" and "<name>:after_<op>". Metrics and
+// tracing plugins use it to time statements.
+func RegisterHooks(g *gorm.DB, name string, before, after func(op string) func(*gorm.DB)) error {
+	cb := g.Callback()
+	hooks := []struct {
+		op     string
+		before func(name string, fn func(*gorm.DB)) error
+		after  func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before(name+":before_"+h.op, before(h.op)); err != nil {
+			return err
+		}
+		if err := h.after(name+":after_"+h.op, after(h.op)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -15,8 +15,253 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/auth/login": {
+        "/.well-known/openid-configuration": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAuth"
+                ],
+                "summary": "OpenID Connect discovery document",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List API keys of all users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "only keys of this user",
+                        "name": "owner_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.APIKeysOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/api-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Revoke any API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "api key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/audit-events": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Newest first. user_id matches events where the user is actor or subject. from/to are RFC3339 (to is exclusive). Page with before_id = next_before_id.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Query audit events (admin)",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "actor or subject user id",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "event type, e.g. login.failure",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 start (inclusive)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 end (exclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "cursor from next_before_id",
+                        "name": "before_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/audit.QueryResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/campaigns": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List points campaigns (admin)",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/points.CampaignList"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Triggers: user.registered, profile.completed, birthday_month (first login in the birth month), tier.upgraded (optionally only into tier_to). cap_period is lifetime, year or month; max_per_user 0 is unlimited.",
                 "consumes": [
                     "application/json"
                 ],
@@ -24,74 +269,132 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
                 ],
-                "summary": "Login user",
+                "summary": "Create points campaign (admin)",
                 "parameters": [
                     {
-                        "description": "login",
+                        "description": "campaign",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/auth.LoginInput"
+                            "$ref": "#/definitions/points.CampaignInput"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/auth.LoginOutput"
+                            "$ref": "#/definitions/points.Campaign"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/auth.ErrorResponse"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/auth.ErrorResponse"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/v1/auth/me": {
-            "get": {
+        "/api/v1/admin/campaigns/{id}": {
+            "put": {
                 "security": [
                     {
                         "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
                     }
                 ],
+                "description": "Replaces the campaign rule. Set active to false to stop it; points already awarded are kept.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
+                ],
+                "summary": "Update points campaign (admin)",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "campaign id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "campaign",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/points.CampaignInput"
+                        }
+                    }
                 ],
-                "summary": "Get current user",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/auth.MeOutput"
+                            "$ref": "#/definitions/points.Campaign"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/auth.ErrorResponse"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/v1/auth/register": {
+        "/api/v1/admin/legal-documents": {
             "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Once effective, users who accepted an older terms or privacy version must re-accept at their next login.",
                 "consumes": [
                     "application/json"
                 ],
@@ -99,17 +402,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
                 ],
-                "summary": "Register user",
+                "summary": "Publish legal document version (admin)",
                 "parameters": [
                     {
-                        "description": "register",
+                        "description": "document",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/auth.RegisterInput"
+                            "$ref": "#/definitions/consent.PublishInput"
                         }
                     }
                 ],
@@ -117,59 +420,87 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/auth.RegisterOutput"
+                            "$ref": "#/definitions/consent.Document"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/auth.ErrorResponse"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     },
                     "409": {
                         "description": "Conflict",
                         "schema": {
-                            "$ref": "#/definitions/auth.ErrorResponse"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/v1/profile": {
+        "/api/v1/admin/oauth-clients": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
                     }
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Profile"
+                    "Admin"
                 ],
-                "summary": "Get profile",
+                "summary": "List OAuth clients (admin)",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/auth.ProfileResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/oauth.Client"
+                            }
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/auth.ErrorResponse"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     }
                 }
             },
-            "put": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
                     }
                 ],
+                "description": "The client_secret is returned only in this response.",
                 "consumes": [
                     "application/json"
                 ],
@@ -177,72 +508,3673 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Profile"
+                    "Admin"
                 ],
-                "summary": "Update profile",
+                "summary": "Register an OAuth client (admin)",
                 "parameters": [
                     {
-                        "description": "profile update",
+                        "description": "client",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/auth.ProfileUpdateRequest"
+                            "$ref": "#/definitions/oauth.ClientInput"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/auth.ProfileResponse"
+                            "$ref": "#/definitions/oauth.ClientCreated"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/auth.ErrorResponse"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/auth.ErrorResponse"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     }
                 }
             }
-        }
+        },
+        "/api/v1/admin/oauth-clients/{client_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Disable an OAuth client (admin)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "client id",
+                        "name": "client_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/email-change/confirm": {
+            "post": {
+                "description": "Token from the link sent to the new address. No bearer token needed. Existing access tokens keep working until they expire.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Confirm email change",
+                "parameters": [
+                    {
+                        "description": "token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.EmailChangeConfirmRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/login": {
+            "post": {
+                "description": "With LOGIN_STEP_UP on, a login from a new device or an impossible location answers 202 and emails a code; finish it at /api/v1/auth/login/verify.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Login user",
+                "parameters": [
+                    {
+                        "description": "login",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginOutput"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/auth.StepUpOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "CONSENT_REQUIRED",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "RATE_LIMITED",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "SERVER_BUSY",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/login/verify": {
+            "post": {
+                "description": "Completes a login that answered 202. The code is valid for 10 minutes and allows 5 attempts. Consents sent with the login are saved now.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Finish a login with the emailed code",
+                "parameters": [
+                    {
+                        "description": "step-up token and code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.StepUpVerifyInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "INVALID_STEP_UP",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "CONSENT_REQUIRED",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/magic-link": {
+            "post": {
+                "description": "Sends a single-use sign-in link to the account with this email. The response is the same whether or not the account exists. Each email can ask for a few links per 15 minutes.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Email a sign-in link",
+                "parameters": [
+                    {
+                        "description": "email",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.MagicLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/auth.MagicLinkOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/magic-link/verify": {
+            "post": {
+                "description": "Exchanges the token from the emailed link for an access token. A link works once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Sign in with a magic link",
+                "parameters": [
+                    {
+                        "description": "token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.MagicLinkVerifyInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "CONSENT_REQUIRED",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/me": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Get current user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.MeOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/passkey/login": {
+            "post": {
+                "description": "Verifies the assertion from navigator.credentials.get() and returns an access token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Sign in with a passkey",
+                "parameters": [
+                    {
+                        "description": "credential",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.PasskeyLoginInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "CONSENT_REQUIRED",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/passkey/options": {
+            "post": {
+                "description": "Returns WebAuthn request options for navigator.credentials.get(). No account is named; the device offers its passkeys for this site. The challenge is valid for 5 minutes and works once.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Start a passkey login",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webauthn.RequestOptions"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/register": {
+            "post": {
+                "description": "With REGISTER_CONCEAL_EXISTING=true, new and taken emails both get 202 and the result is emailed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Register user",
+                "parameters": [
+                    {
+                        "description": "register",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.RegisterInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/auth.RegisterOutput"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/auth.RegisterAcceptedOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "SERVER_BUSY",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/social/providers": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "List social login providers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.ProvidersOutput"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/social/{provider}/callback": {
+            "get": {
+                "description": "Redirect target registered with the provider. Signs in the linked account, links the identity to the account with the same verified email, or creates a new account.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Complete social login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "state from start",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "INVALID_CREDENTIALS",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "CONSENT_REQUIRED",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "ACCOUNT_EXISTS",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/social/{provider}/start": {
+            "post": {
+                "description": "Returns the provider's authorization URL (authorization code with PKCE) and sets a short-lived cookie binding the login to this browser. Send the consents a new account would need; they are applied at the callback.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Start social login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "provider name, e.g. google, line, facebook",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "consents",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/auth.SocialStartInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.SocialStartOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/cards/verify": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Validates the signature and expiry of a scanned card payload and returns the member it belongs to.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff"
+                ],
+                "summary": "Verify a scanned membership card (staff)",
+                "parameters": [
+                    {
+                        "description": "scanned payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/card.VerifyInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/card.VerifyOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/exports/{id}/download": {
+            "get": {
+                "description": "Signed link from download_url; no bearer token needed. Fails once expired.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Download data export",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "export id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "expiry (unix seconds)",
+                        "name": "expires",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "signature",
+                        "name": "sig",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/legal/documents": {
+            "get": {
+                "description": "Current version of each legal document. Clients send these versions back as consents at registration, login (when re-acceptance is required) and from the profile.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Legal"
+                ],
+                "summary": "List current legal documents",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/consent.DocumentsOutput"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/oauth/authorize": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Called by the frontend consent page with the authorization request it received. Granted means the user already approved these scopes for this app.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAuth"
+                ],
+                "summary": "Consent screen data",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "client id",
+                        "name": "client_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "requested scopes",
+                        "name": "scope",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.ConsentScreen"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Send the authorization request fields plus approve. The response says where to send the browser: the client's redirect URI with a code, or with error=access_denied.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAuth"
+                ],
+                "summary": "Approve or deny an app",
+                "parameters": [
+                    {
+                        "description": "authorization request and decision",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/oauth.DecisionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.DecisionOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Get profile",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.ProfileResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Update profile",
+                "parameters": [
+                    {
+                        "description": "profile update",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.ProfileUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.ProfileResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft-deletes the account after confirmation with the current password, or with the step_up_token and code from POST /api/v1/profile/reauth for accounts without a password. Logging in before restorable_until restores it; afterwards personal data is anonymized.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Delete account",
+                "parameters": [
+                    {
+                        "description": "password or emailed code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.DeleteAccountRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.DeleteAccountOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "INVALID_STEP_UP",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "REAUTH_REQUIRED",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "SERVER_BUSY",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Includes expired and revoked keys. The secret part is never shown again.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "List your API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.APIKeysOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issues a key for machine clients acting as the caller. The key is returned only in this response; send it in the X-API-Key header. Scopes must be allowed for the caller's role: cards:verify and points:earn for staff and admins, admin for admins.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "description": "key",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.APIKeyInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/auth.APIKeyCreated"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/api-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Revoke one of your API keys",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "api key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/avatar": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Multipart upload (field \"avatar\"). JPEG, PNG or WebP detected from content, at most 2 MiB and 32-8000 px per side. The image is center-cropped, resized to 512/256/64 px JPEGs and stripped of metadata (EXIF).",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Upload avatar",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "image",
+                        "name": "avatar",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.ProfileResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/card": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns a signed payload valid for a few minutes and its QR code. With format=png the QR code image is returned directly. Refresh before expires_at.",
+                "produces": [
+                    "application/json",
+                    "image/png"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Get my membership card",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "json (default) or png",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/card.Card"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/consents": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Get my consents",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/consent.StatusOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Grant or withdraw optional consents (marketing), or accept a new version of a required document. Required consents cannot be withdrawn.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Update my consents",
+                "parameters": [
+                    {
+                        "description": "consents",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/consent.UpdateInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/consent.StatusOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/email": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Requires the current password, or the step_up_token and code from POST /api/v1/profile/reauth for accounts without a password. Sends a confirmation link to the new address and a notice to the current one; the email changes only once the link is confirmed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Request email change",
+                "parameters": [
+                    {
+                        "description": "new email",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.EmailChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/auth.EmailChangeOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "INVALID_STEP_UP",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "REAUTH_REQUIRED",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "SERVER_BUSY",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/exports": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Queues an export of everything tied to the account. Poll the status endpoint until status is ready, then follow download_url (signed, expiring).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Request personal data export",
+                "parameters": [
+                    {
+                        "description": "format: json (default) or zip",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/export.RequestInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/export.StatusOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/exports/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Get data export status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "export id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/export.StatusOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/identities": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "List linked social accounts",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.IdentitiesOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/identities/{provider}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Refused with LAST_LOGIN_METHOD when the account has no password, other linked provider or passkey.",
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Unlink a social account",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/logins": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "The latest 50 sign-in attempts on your account, newest first, with device, approximate location and whether the login was flagged.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "List recent sign-ins",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginHistoryOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/oauth-grants": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "List connected apps",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/oauth.GrantView"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/oauth-grants/{client_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "The app must ask for consent again. Access tokens it already holds expire on their own.",
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Disconnect an app",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "client id",
+                        "name": "client_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/passkeys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "List registered passkeys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.PasskeysOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Verifies the attestation from navigator.credentials.create() and stores the passkey.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Register a passkey",
+                "parameters": [
+                    {
+                        "description": "credential",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.PasskeyRegisterInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/auth.Passkey"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/passkeys/options": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns WebAuthn creation options for navigator.credentials.create(). The challenge is valid for 5 minutes and works once.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Start registering a passkey",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webauthn.CreationOptions"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/passkeys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Refused with LAST_LOGIN_METHOD when it is the only way to sign in to the account.",
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Remove a passkey",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "passkey id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/password": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Requires the current password. Recorded in the audit log.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Change password",
+                "parameters": [
+                    {
+                        "description": "password change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.ChangePasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "SERVER_BUSY",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/points": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Current balance, membership tier and progress towards the next tier. Tiers follow lifetime earned points and never go down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Get my points balance",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/points.BalanceOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/points/transactions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Ledger entries newest first. Pass next_before_id from the previous page as before_id to page back.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "List my points transactions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "return entries older than this id",
+                        "name": "before_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size (default 50, max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/points.HistoryOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/reauth": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Sends a one-time code that confirms the next account deletion or email change, for accounts without a password (social, magic link or passkey only). Send the returned step_up_token and the code with that request. The code is valid for 10 minutes and allows 5 attempts; a new request replaces the outstanding code.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Email a confirmation code",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/auth.StepUpOutput"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "SERVER_BUSY",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/profile/referrals": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Friends register with referral_code. Both sides get points once the friend completes their profile.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Get my referral code and invited friends",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/referral.Summary"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/staff/earn": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Identify the member by scanned card payload or membership code. Points = amount / 100 x the tier's earn rate, rounded down. A (store_id, reference) pair can be credited once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Staff"
+                ],
+                "summary": "Credit points for a purchase (staff)",
+                "parameters": [
+                    {
+                        "description": "purchase",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/staff.EarnInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/staff.EarnOutput"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Always 200 while the process is serving; does not touch dependencies.",
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/oauth/authorize": {
+            "get": {
+                "description": "Client apps send the browser here. A valid request is forwarded to the frontend consent page with the same query; otherwise the browser returns to the client with an error. Unknown clients and unregistered redirect URIs are answered directly.",
+                "tags": [
+                    "OAuth"
+                ],
+                "summary": "Authorization endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "code",
+                        "name": "response_type",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "client id",
+                        "name": "client_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "registered redirect URI",
+                        "name": "redirect_uri",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "space-separated, e.g. openid profile email",
+                        "name": "scope",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "opaque client state",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "echoed in the ID token",
+                        "name": "nonce",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE challenge",
+                        "name": "code_challenge",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "S256",
+                        "name": "code_challenge_method",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/jwks": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAuth"
+                ],
+                "summary": "Token signing keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/token": {
+            "post": {
+                "description": "grant_type=authorization_code (with code, redirect_uri, code_verifier) or client_credentials (optional scope). Clients authenticate with HTTP Basic or client_id/client_secret; public clients send client_id only. Errors use the RFC 6749 format.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAuth"
+                ],
+                "summary": "Token endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "authorization_code or client_credentials",
+                        "name": "grant_type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "authorization code",
+                        "name": "code",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "same redirect_uri as the authorization request; required when that request had one",
+                        "name": "redirect_uri",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE verifier",
+                        "name": "code_verifier",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "client_credentials scopes",
+                        "name": "scope",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "client id (without Basic auth)",
+                        "name": "client_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "client secret (without Basic auth)",
+                        "name": "client_secret",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/userinfo": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Claims about the user allowed by the access token's scopes (openid required).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OAuth"
+                ],
+                "summary": "UserInfo endpoint",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Runs dependency checks (database, migrations, key material). Returns 503 when any check fails or the server is draining for shutdown.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        }
     },
     "definitions": {
-        "auth.ErrorResponse": {
+        "audit.EventView": {
+            "type": "object",
+            "properties": {
+                "actor_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "object"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "audit.QueryResult": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/audit.EventView"
+                    }
+                },
+                "next_before_id": {
+                    "description": "NextBeforeID is passed as before_id to fetch the next (older) page; 0 when done.",
+                    "type": "integer"
+                }
+            }
+        },
+        "auth.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "last_used_ip": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner_id": {
+                    "type": "integer"
+                },
+                "prefix": {
+                    "description": "Prefix is the non-secret start of the key, e.g. wsk_3f9a1c7e20b4.",
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "auth.APIKeyCreated": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "wsk_3f9a1c7e20b4_Zx8..."
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "last_used_ip": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner_id": {
+                    "type": "integer"
+                },
+                "prefix": {
+                    "description": "Prefix is the non-secret start of the key, e.g. wsk_3f9a1c7e20b4.",
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "auth.APIKeyInput": {
+            "type": "object",
+            "properties": {
+                "expires_in_days": {
+                    "description": "ExpiresInDays defaults to 90; at most 365.",
+                    "type": "integer",
+                    "example": 90
+                },
+                "name": {
+                    "type": "string",
+                    "example": "POS terminal 12"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "cards:verify",
+                        "points:earn"
+                    ]
+                }
+            }
+        },
+        "auth.APIKeysOutput": {
+            "type": "object",
+            "properties": {
+                "api_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/auth.APIKey"
+                    }
+                }
+            }
+        },
+        "auth.Address": {
+            "type": "object",
+            "properties": {
+                "district": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "string"
+                },
+                "postcode": {
+                    "type": "string"
+                },
+                "province": {
+                    "type": "string"
+                },
+                "subdistrict": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.AddressInput": {
+            "type": "object",
+            "properties": {
+                "district": {
+                    "type": "string",
+                    "example": "วัฒนา"
+                },
+                "line": {
+                    "description": "Line is house number, building, road (optional).",
+                    "type": "string",
+                    "example": "99/1 ถ.สุขุมวิท"
+                },
+                "postcode": {
+                    "type": "string",
+                    "example": "10110"
+                },
+                "province": {
+                    "description": "Province accepts the Thai or English name; stored as the Thai name.",
+                    "type": "string",
+                    "example": "กรุงเทพมหานคร"
+                },
+                "subdistrict": {
+                    "type": "string",
+                    "example": "คลองเตยเหนือ"
+                }
+            }
+        },
+        "auth.ChangePasswordRequest": {
+            "type": "object",
+            "properties": {
+                "current_password": {
+                    "type": "string"
+                },
+                "new_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.ContactPrefs": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "boolean"
+                },
+                "push": {
+                    "type": "boolean"
+                },
+                "sms": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "auth.ContactPrefsInput": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "boolean"
+                },
+                "push": {
+                    "type": "boolean"
+                },
+                "sms": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "auth.DeleteAccountOutput": {
+            "type": "object",
+            "properties": {
+                "restorable_until": {
+                    "description": "RestorableUntil is the last moment logging in restores the account.",
+                    "type": "string"
+                }
+            }
+        },
+        "auth.DeleteAccountRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "042519"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "step_up_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.EmailChangeConfirmRequest": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.EmailChangeOutput": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "pending_email": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.EmailChangeRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "042519"
+                },
+                "new_email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "step_up_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.IdentitiesOutput": {
+            "type": "object",
+            "properties": {
+                "has_password": {
+                    "description": "HasPassword is false for accounts created through social login.",
+                    "type": "boolean"
+                },
+                "identities": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/auth.Identity"
+                    }
+                }
+            }
+        },
+        "auth.Identity": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "last_login_at": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.LoginEvent": {
+            "type": "object",
+            "properties": {
+                "city": {
+                    "type": "string",
+                    "example": "Bangkok"
+                },
+                "country": {
+                    "type": "string",
+                    "example": "TH"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "impossible_travel": {
+                    "description": "ImpossibleTravel means the previous login was too far away to reach\nin the time since.",
+                    "type": "boolean"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "latitude": {
+                    "type": "number",
+                    "example": 13.8
+                },
+                "longitude": {
+                    "type": "number",
+                    "example": 100.5
+                },
+                "method": {
+                    "description": "Method is password, magic_link, passkey or a social provider name.",
+                    "type": "string",
+                    "example": "password"
+                },
+                "new_device": {
+                    "type": "boolean"
+                },
+                "outcome": {
+                    "type": "string",
+                    "example": "success"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "wrong_password"
+                },
+                "step_up": {
+                    "description": "StepUp means the login was confirmed with an emailed code.",
+                    "type": "boolean"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.LoginHistoryOutput": {
+            "type": "object",
+            "properties": {
+                "logins": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/auth.LoginEvent"
+                    }
+                }
+            }
+        },
+        "auth.LoginInput": {
+            "type": "object",
+            "properties": {
+                "consents": {
+                    "description": "Consents re-accepts documents published since the last login; only\nneeded after a CONSENT_REQUIRED response.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/consent.Grant"
+                    }
+                },
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.LoginOutput": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "expires_in": {
+                    "type": "integer"
+                },
+                "token_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.MagicLinkOutput": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "if an account exists for this email, a sign-in link has been sent"
+                }
+            }
+        },
+        "auth.MagicLinkRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.MagicLinkVerifyInput": {
+            "type": "object",
+            "properties": {
+                "consents": {
+                    "description": "Consents re-accepts documents published since the last login; only\nneeded after a CONSENT_REQUIRED response.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/consent.Grant"
+                    }
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.MeOutput": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_login_at": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.Passkey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "synced": {
+                    "description": "Synced is true for passkeys backed up to a cloud keychain.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "auth.PasskeyLoginInput": {
+            "type": "object",
+            "properties": {
+                "consents": {
+                    "description": "Consents re-accepts documents published since the last login; only\nneeded after a CONSENT_REQUIRED response.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/consent.Grant"
+                    }
+                },
+                "credential": {
+                    "$ref": "#/definitions/webauthn.AssertionCredential"
+                }
+            }
+        },
+        "auth.PasskeyRegisterInput": {
+            "type": "object",
+            "properties": {
+                "credential": {
+                    "$ref": "#/definitions/webauthn.AttestationCredential"
+                },
+                "name": {
+                    "description": "Name labels the passkey in the list, e.g. the device; default \"Passkey\".",
+                    "type": "string",
+                    "example": "iPhone"
+                }
+            }
+        },
+        "auth.PasskeysOutput": {
+            "type": "object",
+            "properties": {
+                "passkeys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/auth.Passkey"
+                    }
+                }
+            }
+        },
+        "auth.ProfileResponse": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address is null until one is set.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/auth.Address"
+                        }
+                    ]
+                },
+                "avatar_urls": {
+                    "description": "AvatarURLs maps size (large 512px, medium 256px, small 64px) to URL; null without an avatar.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "contact_preferences": {
+                    "$ref": "#/definitions/auth.ContactPrefs"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "date_of_birth": {
+                    "type": "string",
+                    "example": "1990-04-21"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "gender": {
+                    "type": "string",
+                    "example": "female"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "joined_at": {
+                    "type": "string"
+                },
+                "language": {
+                    "type": "string",
+                    "example": "th"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "membership_code": {
+                    "type": "string"
+                },
+                "membership_level": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "integer"
+                }
+            }
+        },
+        "auth.ProfileUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "$ref": "#/definitions/auth.AddressInput"
+                },
+                "contact_preferences": {
+                    "description": "ContactPreferences updates only the channels present.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/auth.ContactPrefsInput"
+                        }
+                    ]
+                },
+                "date_of_birth": {
+                    "description": "DateOfBirth is YYYY-MM-DD.",
+                    "type": "string",
+                    "example": "1990-04-21"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "gender": {
+                    "description": "Gender is male, female, other or prefer_not_to_say.",
+                    "type": "string",
+                    "example": "female"
+                },
+                "language": {
+                    "description": "Language is th or en.",
+                    "type": "string",
+                    "example": "th"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.ProvidersOutput": {
+            "type": "object",
+            "properties": {
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "line",
+                        "google"
+                    ]
+                }
+            }
+        },
+        "auth.RegisterAcceptedOutput": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "registration received; check your email to continue"
+                }
+            }
+        },
+        "auth.RegisterInput": {
+            "type": "object",
+            "properties": {
+                "consents": {
+                    "description": "Consents must accept the current terms and privacy documents, if published.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/consent.Grant"
+                    }
+                },
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "referral_code": {
+                    "description": "ReferralCode is an optional invite code from an existing member.",
+                    "type": "string",
+                    "example": "K7QM3XPA"
+                }
+            }
+        },
+        "auth.RegisterOutput": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "auth.SocialStartInput": {
+            "type": "object",
+            "properties": {
+                "consents": {
+                    "description": "Consents are applied if the callback creates an account or the user\nmust re-accept updated documents, as for register and login.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/consent.Grant"
+                    }
+                }
+            }
+        },
+        "auth.SocialStartOutput": {
+            "type": "object",
+            "properties": {
+                "authorization_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.StepUpOutput": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "a verification code was sent to your email"
+                },
+                "step_up_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.StepUpVerifyInput": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "042519"
+                },
+                "step_up_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "card.Card": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "membership_code": {
+                    "type": "string",
+                    "example": "LBK001234"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Payload is the signed text encoded in the QR code; clients may also\nrender it as a Code 128 barcode.",
+                    "type": "string",
+                    "example": "MC1.LBK001234.1767225600.Ab3dEf..."
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "qr_png": {
+                    "description": "QRPNG is the QR code as a data URL (image/png, 256x256).",
+                    "type": "string"
+                },
+                "tier": {
+                    "type": "string",
+                    "example": "Gold"
+                }
+            }
+        },
+        "card.VerifyInput": {
+            "type": "object",
+            "properties": {
+                "payload": {
+                    "type": "string",
+                    "example": "MC1.LBK001234.1767225600.Ab3dEf..."
+                }
+            }
+        },
+        "card.VerifyOutput": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "membership_code": {
+                    "type": "string",
+                    "example": "LBK001234"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Somchai J."
+                },
+                "tier": {
+                    "type": "string",
+                    "example": "Gold"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "consent.Document": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "effective_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "purpose": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "consent.DocumentsOutput": {
+            "type": "object",
+            "properties": {
+                "documents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/consent.Document"
+                    }
+                }
+            }
+        },
+        "consent.Grant": {
+            "type": "object",
+            "properties": {
+                "granted": {
+                    "type": "boolean"
+                },
+                "purpose": {
+                    "type": "string",
+                    "example": "terms"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "2026-01"
+                }
+            }
+        },
+        "consent.PublishInput": {
+            "type": "object",
+            "properties": {
+                "effective_at": {
+                    "description": "EffectiveAt defaults to now; a future time schedules the version.",
+                    "type": "string"
+                },
+                "purpose": {
+                    "type": "string",
+                    "example": "terms"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "2026-01"
+                }
+            }
+        },
+        "consent.Status": {
+            "type": "object",
+            "properties": {
+                "current_version": {
+                    "type": "string"
+                },
+                "granted": {
+                    "type": "boolean"
+                },
+                "purpose": {
+                    "type": "string"
+                },
+                "required": {
+                    "type": "boolean"
+                },
+                "up_to_date": {
+                    "type": "boolean"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "consent.StatusOutput": {
+            "type": "object",
+            "properties": {
+                "consents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/consent.Status"
+                    }
+                }
+            }
+        },
+        "consent.UpdateInput": {
+            "type": "object",
+            "properties": {
+                "consents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/consent.Grant"
+                    }
+                }
+            }
+        },
+        "export.RequestInput": {
+            "type": "object",
+            "properties": {
+                "format": {
+                    "type": "string",
+                    "example": "json"
+                }
+            }
+        },
+        "export.StatusOutput": {
+            "type": "object",
+            "properties": {
+                "completed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "download_url": {
+                    "description": "DownloadURL is a signed link valid until ExpiresAt; set only when ready.",
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.CheckResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.CheckResult"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "object",
+                    "properties": {
+                        "code": {
+                            "type": "string"
+                        },
+                        "details": {
+                            "description": "Details lists the individual problems when there are several, e.g.\neach password rule broken.",
+                            "type": "array",
+                            "items": {
+                                "type": "object"
+                            }
+                        },
+                        "message": {
+                            "type": "string"
+                        },
+                        "request_id": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "oauth.Client": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "integer"
+                },
+                "disabled_at": {
+                    "type": "string"
+                },
+                "grant_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "public": {
+                    "type": "boolean"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "oauth.ClientCreated": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string"
+                },
+                "client_secret": {
+                    "description": "ClientSecret is shown only once; it is stored hashed.",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "integer"
+                },
+                "disabled_at": {
+                    "type": "string"
+                },
+                "grant_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "public": {
+                    "type": "boolean"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "oauth.ClientInput": {
+            "type": "object",
+            "properties": {
+                "grant_types": {
+                    "description": "GrantTypes: authorization_code and/or client_credentials; defaults to authorization_code.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "authorization_code"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Workshop Booking"
+                },
+                "public": {
+                    "description": "Public clients (SPAs, mobile apps) get no secret and must use PKCE.",
+                    "type": "boolean"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "https://booking.example.com/callback"
+                    ]
+                },
+                "scopes": {
+                    "description": "Scopes the client may request; defaults to openid, profile and email.\nCustom scopes (e.g. members.read) are for client_credentials tokens.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "openid",
+                        "profile",
+                        "email"
+                    ]
+                }
+            }
+        },
+        "oauth.ConsentScope": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Your email address"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "email"
+                }
+            }
+        },
+        "oauth.ConsentScreen": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string"
+                },
+                "client_name": {
+                    "type": "string"
+                },
+                "granted": {
+                    "description": "Granted is true when the user already approved these scopes for this\nclient; the frontend may approve without asking.",
+                    "type": "boolean"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/oauth.ConsentScope"
+                    }
+                }
+            }
+        },
+        "oauth.DecisionInput": {
+            "type": "object",
+            "properties": {
+                "approve": {
+                    "type": "boolean"
+                },
+                "client_id": {
+                    "type": "string"
+                },
+                "code_challenge": {
+                    "type": "string"
+                },
+                "code_challenge_method": {
+                    "type": "string"
+                },
+                "nonce": {
+                    "type": "string"
+                },
+                "prompt": {
+                    "description": "Prompt=consent shows the consent screen even if already granted.",
+                    "type": "string"
+                },
+                "redirect_uri": {
+                    "type": "string"
+                },
+                "response_type": {
+                    "type": "string"
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "oauth.DecisionOutput": {
+            "type": "object",
+            "properties": {
+                "redirect_to": {
+                    "description": "RedirectTo is where the frontend sends the browser next: the client's\nredirect URI with either code or error.",
+                    "type": "string"
+                }
+            }
+        },
+        "oauth.Error": {
             "type": "object",
             "properties": {
                 "error": {
-                    "type": "object",
-                    "properties": {
-                        "code": {
-                            "type": "string"
-                        },
-                        "message": {
-                            "type": "string"
-                        }
-                    }
+                    "type": "string"
+                },
+                "error_description": {
+                    "type": "string"
                 }
             }
         },
-        "auth.LoginInput": {
+        "oauth.GrantView": {
             "type": "object",
             "properties": {
-                "email": {
+                "client_id": {
                     "type": "string"
                 },
-                "password": {
+                "client_name": {
+                    "type": "string"
+                },
+                "granted_at": {
                     "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "auth.LoginOutput": {
+        "oauth.TokenResponse": {
             "type": "object",
             "properties": {
                 "access_token": {
@@ -251,101 +4183,484 @@ const docTemplate = `{
                 "expires_in": {
                     "type": "integer"
                 },
+                "id_token": {
+                    "type": "string"
+                },
+                "scope": {
+                    "type": "string"
+                },
                 "token_type": {
                     "type": "string"
                 }
             }
         },
-        "auth.MeOutput": {
+        "points.BalanceOutput": {
             "type": "object",
             "properties": {
-                "email": {
+                "lifetime_points": {
+                    "type": "integer"
+                },
+                "next_tier": {
                     "type": "string"
                 },
-                "id": {
+                "points": {
                     "type": "integer"
                 },
-                "last_login_at": {
+                "points_to_next_tier": {
+                    "type": "integer"
+                },
+                "tier": {
                     "type": "string"
                 }
             }
         },
-        "auth.ProfileResponse": {
+        "points.Campaign": {
             "type": "object",
             "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "cap_period": {
+                    "type": "string"
+                },
                 "created_at": {
                     "type": "string"
                 },
-                "email": {
+                "ends_at": {
                     "type": "string"
                 },
-                "first_name": {
+                "id": {
+                    "type": "integer"
+                },
+                "max_per_user": {
+                    "description": "MaxPerUser limits awards per member per CapPeriod; 0 means unlimited.",
+                    "type": "integer"
+                },
+                "name": {
                     "type": "string"
                 },
+                "points": {
+                    "type": "integer"
+                },
+                "starts_at": {
+                    "description": "StartsAt/EndsAt bound the campaign window; nil means open-ended.",
+                    "type": "string"
+                },
+                "tier_multipliers": {
+                    "description": "TierMultipliers scales Points by membership level, e.g. {\"Gold\": 2}. Missing tiers use 1.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number",
+                        "format": "float64"
+                    }
+                },
+                "tier_to": {
+                    "description": "TierTo restricts tier.upgraded campaigns to upgrades into this tier.",
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "points.CampaignInput": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Active defaults to true.",
+                    "type": "boolean"
+                },
+                "cap_period": {
+                    "type": "string",
+                    "example": "year"
+                },
+                "ends_at": {
+                    "type": "string"
+                },
+                "max_per_user": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Birthday bonus"
+                },
+                "points": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "starts_at": {
+                    "type": "string"
+                },
+                "tier_multipliers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number",
+                        "format": "float64"
+                    }
+                },
+                "tier_to": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string",
+                    "example": "birthday_month"
+                }
+            }
+        },
+        "points.CampaignList": {
+            "type": "object",
+            "properties": {
+                "campaigns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/points.Campaign"
+                    }
+                }
+            }
+        },
+        "points.HistoryOutput": {
+            "type": "object",
+            "properties": {
+                "next_before_id": {
+                    "description": "NextBeforeID is the cursor for the next (older) page; 0 when there is none.",
+                    "type": "integer"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/points.Transaction"
+                    }
+                }
+            }
+        },
+        "points.Transaction": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "description": "Balance is users.points right after this entry.",
+                    "type": "integer"
+                },
+                "campaign_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delta": {
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "kind": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                }
+            }
+        },
+        "referral.Item": {
+            "type": "object",
+            "properties": {
                 "joined_at": {
                     "type": "string"
                 },
-                "last_name": {
+                "rewarded_at": {
                     "type": "string"
                 },
+                "status": {
+                    "type": "string",
+                    "example": "rewarded"
+                }
+            }
+        },
+        "referral.Summary": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "K7QM3XPA"
+                },
+                "invited": {
+                    "type": "integer"
+                },
+                "points_earned": {
+                    "type": "integer"
+                },
+                "referrals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/referral.Item"
+                    }
+                },
+                "rewarded": {
+                    "type": "integer"
+                },
+                "share_url": {
+                    "description": "ShareURL opens registration with the code filled in.",
+                    "type": "string",
+                    "example": "http://localhost:3000/register?ref=K7QM3XPA"
+                }
+            }
+        },
+        "staff.EarnInput": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount is the purchase total in THB.",
+                    "type": "number",
+                    "example": 1250.5
+                },
+                "member": {
+                    "description": "Member is the scanned card payload or a typed-in membership code.",
+                    "type": "string",
+                    "example": "MC1.LBK001234.1767225600.Ab3dEf..."
+                },
+                "reference": {
+                    "type": "string",
+                    "example": "POS1-000123"
+                },
+                "store_id": {
+                    "type": "string",
+                    "example": "BKK-01"
+                }
+            }
+        },
+        "staff.EarnOutput": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer",
+                    "example": 1650
+                },
                 "membership_code": {
+                    "type": "string",
+                    "example": "LBK001234"
+                },
+                "points_earned": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "purchase_id": {
+                    "type": "integer"
+                },
+                "tier": {
+                    "type": "string",
+                    "example": "Silver"
+                }
+            }
+        },
+        "webauthn.AssertionCredential": {
+            "type": "object",
+            "properties": {
+                "id": {
                     "type": "string"
                 },
-                "membership_level": {
+                "response": {
+                    "$ref": "#/definitions/webauthn.AssertionResponse"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
+                }
+            }
+        },
+        "webauthn.AssertionResponse": {
+            "type": "object",
+            "properties": {
+                "authenticatorData": {
                     "type": "string"
                 },
-                "phone": {
+                "clientDataJSON": {
                     "type": "string"
                 },
-                "points": {
-                    "type": "integer"
+                "signature": {
+                    "type": "string"
+                },
+                "userHandle": {
+                    "type": "string"
                 }
             }
         },
-        "auth.ProfileUpdateRequest": {
+        "webauthn.AttestationCredential": {
             "type": "object",
             "properties": {
-                "first_name": {
+                "id": {
                     "type": "string"
                 },
-                "last_name": {
+                "response": {
+                    "$ref": "#/definitions/webauthn.AttestationResponse"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
+                }
+            }
+        },
+        "webauthn.AttestationResponse": {
+            "type": "object",
+            "properties": {
+                "attestationObject": {
                     "type": "string"
                 },
-                "phone": {
+                "clientDataJSON": {
                     "type": "string"
+                },
+                "transports": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "auth.RegisterInput": {
+        "webauthn.AuthenticatorSelection": {
             "type": "object",
             "properties": {
-                "email": {
+                "residentKey": {
+                    "type": "string",
+                    "example": "required"
+                },
+                "userVerification": {
+                    "type": "string",
+                    "example": "required"
+                }
+            }
+        },
+        "webauthn.CreationOptions": {
+            "type": "object",
+            "properties": {
+                "attestation": {
+                    "type": "string",
+                    "example": "none"
+                },
+                "authenticatorSelection": {
+                    "$ref": "#/definitions/webauthn.AuthenticatorSelection"
+                },
+                "challenge": {
                     "type": "string"
                 },
-                "password": {
+                "excludeCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.CredentialDescriptor"
+                    }
+                },
+                "pubKeyCredParams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.CredentialParameter"
+                    }
+                },
+                "rp": {
+                    "$ref": "#/definitions/webauthn.RPEntity"
+                },
+                "timeout": {
+                    "type": "integer",
+                    "example": 300000
+                },
+                "user": {
+                    "$ref": "#/definitions/webauthn.UserEntity"
+                }
+            }
+        },
+        "webauthn.CredentialDescriptor": {
+            "type": "object",
+            "properties": {
+                "id": {
                     "type": "string"
+                },
+                "transports": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
                 }
             }
         },
-        "auth.RegisterOutput": {
+        "webauthn.CredentialParameter": {
             "type": "object",
             "properties": {
-                "created_at": {
+                "alg": {
+                    "type": "integer",
+                    "example": -7
+                },
+                "type": {
+                    "type": "string",
+                    "example": "public-key"
+                }
+            }
+        },
+        "webauthn.RPEntity": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "example.com"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Workshop"
+                }
+            }
+        },
+        "webauthn.RequestOptions": {
+            "type": "object",
+            "properties": {
+                "allowCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.CredentialDescriptor"
+                    }
+                },
+                "challenge": {
                     "type": "string"
                 },
-                "email": {
+                "rpId": {
+                    "type": "string",
+                    "example": "example.com"
+                },
+                "timeout": {
+                    "type": "integer",
+                    "example": 300000
+                },
+                "userVerification": {
+                    "type": "string",
+                    "example": "required"
+                }
+            }
+        },
+        "webauthn.UserEntity": {
+            "type": "object",
+            "properties": {
+                "displayName": {
                     "type": "string"
                 },
                 "id": {
-                    "type": "integer"
+                    "description": "ID is the opaque user handle, base64url.",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
                 }
             }
         }
     },
     "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
         "BearerAuth": {
             "type": "apiKey",
             "name": "Authorization",
@@ -0,0 +1,68 @@
+// Package events is an in-process publish/subscribe bus for domain events.
+// Publishers (auth, points) don't know who listens; subscribers such as the
+// campaign engine react to what happened.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event types. Stored as campaign triggers, so keep them stable.
+const (
+	UserRegistered   = "user.registered"
+	UserLoggedIn     = "user.logged_in"
+	ProfileCompleted = "profile.completed"
+	TierUpgraded     = "tier.upgraded"
+)
+
+type Event struct {
+	Type   string
+	UserID uint
+	At     time.Time
+	// Data carries event-specific fields, e.g. "from"/"to" for TierUpgraded.
+	Data map[string]any
+}
+
+type Handler func(ctx context.Context, e Event)
+
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus { return &Bus{} }
+
+// Subscribe registers h for every event.
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish delivers e to every subscriber synchronously, after the publisher's
+// own work has committed. A panicking subscriber is logged and does not stop
+// the others or fail the publisher. Publishing on a nil Bus is a no-op.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	if b == nil {
+		return
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					slog.ErrorContext(ctx, "event handler panicked", "event", e.Type, "user_id", e.UserID, "panic", r)
+				}
+			}()
+			h(ctx, e)
+		}()
+	}
+}
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"net/http"
+
+	"workshop-be/internal/httpx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// RequestExport godoc
+// @Summary Request personal data export
+// @Description Queues an export of everything tied to the account. Poll the status endpoint until status is ready, then follow download_url (signed, expiring).
+// @Tags Profile
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body RequestInput false "format: json (default) or zip"
+// @Success 202 {object} StatusOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/exports [post]
+func (h *Handler) RequestExport(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in RequestInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&in); err != nil {
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+		}
+	}
+	out, err := h.svc.Request(c.UserContext(), uid, in)
+	if err != nil {
+		switch err {
+		case ErrInvalidFormat:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_FORMAT", "format must be json or zip")
+		case ErrInProgress:
+			return httpx.WriteError(c, http.StatusConflict, "EXPORT_IN_PROGRESS", "an export is already in progress")
+		default:
+			return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusAccepted).JSON(out)
+}
+
+// GetExport godoc
+// @Summary Get data export status
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "export id"
+// @Success 200 {object} StatusOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/exports/{id} [get]
+func (h *Handler) GetExport(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.Status(c.UserContext(), uid, c.Params("id"))
+	if err != nil {
+		if err == ErrNotFound {
+			return httpx.WriteError(c, http.StatusNotFound, "EXPORT_NOT_FOUND", "export not found")
+		}
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// Download godoc
+// @Summary Download data export
+// @Description Signed link from download_url; no bearer token needed. Fails once expired.
+// @Tags Profile
+// @Produce application/octet-stream
+// @Param id path string true "export id"
+// @Param expires query int true "expiry (unix seconds)"
+// @Param sig query string true "signature"
+// @Success 200 {file} file
+// @Failure 403 {object} httpx.ErrorResponse
+// @Router /api/v1/exports/{id}/download [get]
+func (h *Handler) Download(c *fiber.Ctx) error {
+	path, name, err := h.svc.Open(c.UserContext(), c.Params("id"), c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		return httpx.WriteError(c, http.StatusForbidden, "INVALID_LINK", "invalid or expired link")
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Download(path, name)
+}
+
+// RegisterProfileRoutes mounts the authenticated endpoints on the profile group.
+func RegisterProfileRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	r.Post("/exports", h.RequestExport)
+	r.Get("/exports/:id", h.GetExport)
+}
+
+// RegisterDownloadRoutes mounts the signed-link download endpoint (no auth).
+func RegisterDownloadRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	r.Get("/exports/:id/download", h.Download)
+}
@@ -0,0 +1,34 @@
+package export
+
+import "time"
+
+const (
+	FormatJSON = "json"
+	FormatZIP  = "zip"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusReady      = "ready"
+	StatusFailed     = "failed"
+	StatusExpired    = "expired"
+)
+
+// Export is one asynchronous personal-data export job. The table doubles as
+// the work queue: the worker picks up pending rows oldest first.
+type Export struct {
+	ID          string    `gorm:"primaryKey;size:32"`
+	UserID      uint      `gorm:"index;not null"`
+	Format      string    `gorm:"size:10;not null"`
+	Status      string    `gorm:"size:20;index;not null"`
+	FilePath    string    `gorm:"size:255"`
+	Error       string    `gorm:"size:255"`
+	CreatedAt   time.Time `gorm:"index"`
+	UpdatedAt   time.Time
+	CompletedAt *time.Time
+	// ExpiresAt is when the archive is deleted and links stop working.
+	ExpiresAt *time.Time
+}
+
+func (Export) TableName() string { return "data_exports" }
@@ -0,0 +1,341 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/db"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+)
+
+var tracer = otel.Tracer("workshop-be/internal/export")
+
+var (
+	ErrInvalidFormat = errors.New("invalid format")
+	ErrNotFound      = errors.New("export not found")
+	ErrInProgress    = errors.New("export already in progress")
+	ErrInvalidLink   = errors.New("invalid or expired link")
+)
+
+// Source returns one section of a user's data; it must be JSON-marshalable.
+type Source func(ctx context.Context, userID uint) (any, error)
+
+type namedSource struct {
+	name string
+	fn   Source
+}
+
+type Service struct {
+	dir     string
+	key     []byte
+	sources []namedSource
+	audit   *audit.Service
+	notify  chan struct{}
+	// Retention is how long a finished archive (and its links) stays available.
+	Retention time.Duration
+}
+
+// NewService stores archives under dir and signs download links with key.
+func NewService(dir string, key []byte) *Service {
+	if dir == "" {
+		dir = "data/exports"
+	}
+	return &Service{
+		dir:       dir,
+		key:       key,
+		audit:     audit.NewService(),
+		notify:    make(chan struct{}, 1),
+		Retention: 24 * time.Hour,
+	}
+}
+
+// AddSource registers a named section included in every export. Register all
+// sources before Run; sections appear in registration order.
+func (s *Service) AddSource(name string, fn Source) {
+	s.sources = append(s.sources, namedSource{name: name, fn: fn})
+}
+
+// StatusOutput is returned by the request and status endpoints.
+type StatusOutput struct {
+	ID          string     `json:"id"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	// DownloadURL is a signed link valid until ExpiresAt; set only when ready.
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+type RequestInput struct {
+	Format string `json:"format" example:"json"`
+}
+
+// Request queues a new export. Only one export per user may be pending or
+// processing at a time.
+func (s *Service) Request(ctx context.Context, userID uint, in RequestInput) (*StatusOutput, error) {
+	ctx, span := tracer.Start(ctx, "export.Service.Request")
+	defer span.End()
+	format := in.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatZIP {
+		return nil, ErrInvalidFormat
+	}
+	d := db.MustGet().WithContext(ctx)
+	var active int64
+	if err := d.Model(&Export{}).
+		Where("user_id = ? AND status IN ?", userID, []string{StatusPending, StatusProcessing}).
+		Count(&active).Error; err != nil {
+		return nil, err
+	}
+	if active > 0 {
+		return nil, ErrInProgress
+	}
+	e := Export{ID: newID(), UserID: userID, Format: format, Status: StatusPending}
+	if err := d.Create(&e).Error; err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventDataExport, ActorID: &userID, UserID: &userID, Details: map[string]string{"export_id": e.ID, "format": format}})
+	s.wake()
+	return s.view(&e), nil
+}
+
+// Status returns an export owned by userID.
+func (s *Service) Status(ctx context.Context, userID uint, id string) (*StatusOutput, error) {
+	var e Export
+	err := db.MustGet().WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.view(&e), nil
+}
+
+// Open validates a signed download link and returns the archive path and a
+// download file name.
+func (s *Service) Open(ctx context.Context, id, expires, sig string) (string, string, error) {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return "", "", ErrInvalidLink
+	}
+	want, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(want, s.sign(id, exp)) {
+		return "", "", ErrInvalidLink
+	}
+	var e Export
+	if err := db.MustGet().WithContext(ctx).Where("id = ? AND status = ?", id, StatusReady).First(&e).Error; err != nil {
+		return "", "", ErrInvalidLink
+	}
+	return e.FilePath, "data-export-" + e.ID + "." + e.Format, nil
+}
+
+func (s *Service) view(e *Export) *StatusOutput {
+	out := &StatusOutput{
+		ID: e.ID, Format: e.Format, Status: e.Status, Error: e.Error,
+		CreatedAt: e.CreatedAt, CompletedAt: e.CompletedAt, ExpiresAt: e.ExpiresAt,
+	}
+	if e.Status == StatusReady && e.ExpiresAt != nil {
+		exp := e.ExpiresAt.Unix()
+		out.DownloadURL = fmt.Sprintf("/api/v1/exports/%s/download?expires=%d&sig=%s", e.ID, exp, hex.EncodeToString(s.sign(e.ID, exp)))
+	}
+	return out
+}
+
+func (s *Service) sign(id string, exp int64) []byte {
+	m := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(m, "%s|%d", id, exp)
+	return m.Sum(nil)
+}
+
+func (s *Service) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes pending exports and expires old archives until ctx is
+// cancelled. Exports interrupted by a restart are picked up again.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	d := db.MustGet().WithContext(ctx)
+	// Anything left "processing" was interrupted by a restart.
+	d.Model(&Export{}).Where("status = ?", StatusProcessing).Update("status", StatusPending)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.processPending(ctx)
+		s.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) processPending(ctx context.Context) {
+	d := db.MustGet().WithContext(ctx)
+	for ctx.Err() == nil {
+		var e Export
+		err := d.Where("status = ?", StatusPending).Order("created_at ASC").First(&e).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "export queue read failed", "err", err)
+			return
+		}
+		d.Model(&e).Update("status", StatusProcessing)
+		path, err := s.build(ctx, &e)
+		now := time.Now()
+		if err != nil {
+			slog.ErrorContext(ctx, "export failed", "export_id", e.ID, "err", err)
+			d.Model(&e).Updates(map[string]any{"status": StatusFailed, "error": "generation failed", "completed_at": &now})
+			continue
+		}
+		expires := now.Add(s.Retention)
+		res := d.Model(&e).Updates(map[string]any{"status": StatusReady, "file_path": path, "completed_at": &now, "expires_at": &expires})
+		if res.Error == nil && res.RowsAffected == 0 {
+			// The account was purged while the archive was built.
+			os.Remove(path)
+			continue
+		}
+		slog.InfoContext(ctx, "export ready", "export_id", e.ID, "user_id", e.UserID)
+	}
+}
+
+// expire deletes archives past ExpiresAt.
+func (s *Service) expire(ctx context.Context) {
+	d := db.MustGet().WithContext(ctx)
+	var rows []Export
+	if err := d.Where("status = ? AND expires_at < ?", StatusReady, time.Now()).Find(&rows).Error; err != nil {
+		slog.ErrorContext(ctx, "export expiry scan failed", "err", err)
+		return
+	}
+	for i := range rows {
+		if err := os.Remove(rows[i].FilePath); err != nil && !os.IsNotExist(err) {
+			slog.ErrorContext(ctx, "export file removal failed", "export_id", rows[i].ID, "err", err)
+			continue
+		}
+		d.Model(&rows[i]).Updates(map[string]any{"status": StatusExpired, "file_path": ""})
+	}
+}
+
+// Forget deletes a purged user's export jobs using the purge transaction tx,
+// and their archives. Archives go first: if the transaction then fails, the
+// rows are deleted by the next purge, and a download meanwhile finds no file.
+func (s *Service) Forget(ctx context.Context, tx *gorm.DB, userID uint) error {
+	d := tx.WithContext(ctx)
+	var rows []Export
+	if err := d.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, e := range rows {
+		if e.FilePath == "" {
+			continue
+		}
+		if err := os.Remove(e.FilePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove export %s: %w", e.ID, err)
+		}
+	}
+	return d.Where("user_id = ?", userID).Delete(&Export{}).Error
+}
+
+// archive is the JSON layout; ZIP exports hold the same data split per section.
+type archive struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	UserID      uint           `json:"user_id"`
+	Sections    map[string]any `json:"sections"`
+}
+
+func (s *Service) build(ctx context.Context, e *Export) (string, error) {
+	ctx, span := tracer.Start(ctx, "export.Service.build")
+	defer span.End()
+	a := archive{GeneratedAt: time.Now().UTC(), UserID: e.UserID, Sections: make(map[string]any, len(s.sources))}
+	for _, src := range s.sources {
+		data, err := src.fn(ctx, e.UserID)
+		if err != nil {
+			return "", fmt.Errorf("source %s: %w", src.name, err)
+		}
+		a.Sections[src.name] = data
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.dir, e.ID+"."+e.Format)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if e.Format == FormatZIP {
+		err = s.writeZIP(f, a)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(a)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *Service) writeZIP(f *os.File, a archive) error {
+	zw := zip.NewWriter(f)
+	manifest := map[string]any{"generated_at": a.GeneratedAt, "user_id": a.UserID}
+	files := []string{}
+	for _, src := range s.sources {
+		files = append(files, src.name+".json")
+	}
+	manifest["files"] = files
+	if err := writeZIPEntry(zw, "manifest.json", manifest); err != nil {
+		return err
+	}
+	for _, src := range s.sources {
+		if err := writeZIPEntry(zw, src.name+".json", a.Sections[src.name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeZIPEntry(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,83 @@
+// Package geoip resolves client IPs to a coarse location from a local
+// MaxMind DB file (GeoLite2-City or DB-IP City Lite, .mmdb), so no request
+// leaves the server.
+package geoip
+
+import (
+	"math"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is where an IP is registered, at city level at best. Latitude
+// and Longitude are rounded to 0.1 degree (about 10 km) and nil when the
+// database has no coordinates for the IP.
+type Location struct {
+	Country   string   `json:"country,omitempty" example:"TH"`
+	City      string   `json:"city,omitempty" example:"Bangkok"`
+	Latitude  *float64 `json:"latitude,omitempty" example:"13.8"`
+	Longitude *float64 `json:"longitude,omitempty" example:"100.5"`
+}
+
+// DB is an open database file. It is safe for concurrent use.
+type DB struct {
+	r *maxminddb.Reader
+}
+
+func Open(path string) (*DB, error) {
+	r, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{r: r}, nil
+}
+
+func (db *DB) Close() error { return db.r.Close() }
+
+// Lookup returns the location of ip. ok is false for unparsable, private
+// and unknown addresses, and always on a nil DB.
+func (db *DB) Lookup(ip string) (loc Location, ok bool) {
+	addr := net.ParseIP(ip)
+	if db == nil || addr == nil {
+		return Location{}, false
+	}
+	var rec struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		City struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"city"`
+		Location struct {
+			Latitude  *float64 `maxminddb:"latitude"`
+			Longitude *float64 `maxminddb:"longitude"`
+		} `maxminddb:"location"`
+	}
+	_, found, err := db.r.LookupNetwork(addr, &rec)
+	if err != nil || !found {
+		return Location{}, false
+	}
+	loc = Location{Country: rec.Country.ISOCode, City: rec.City.Names["en"]}
+	if rec.Location.Latitude != nil && rec.Location.Longitude != nil {
+		lat, lon := coarse(*rec.Location.Latitude), coarse(*rec.Location.Longitude)
+		loc.Latitude, loc.Longitude = &lat, &lon
+	}
+	return loc, loc.Country != "" || loc.Latitude != nil
+}
+
+// Distance is the great-circle distance between two locations in km, and
+// false when either has no coordinates.
+func Distance(a, b Location) (float64, bool) {
+	if a.Latitude == nil || a.Longitude == nil || b.Latitude == nil || b.Longitude == nil {
+		return 0, false
+	}
+	const earthRadiusKm = 6371
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	lat1, lat2 := rad(*a.Latitude), rad(*b.Latitude)
+	dLat, dLon := lat2-lat1, rad(*b.Longitude-*a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h))), true
+}
+
+func coarse(deg float64) float64 { return math.Round(deg*10) / 10 }
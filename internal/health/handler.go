@@ -0,0 +1,48 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	checker *Checker
+}
+
+func NewHandler(checker *Checker) *Handler {
+	return &Handler{checker: checker}
+}
+
+// Live godoc
+// @Summary Liveness probe
+// @Description Always 200 while the process is serving; does not touch dependencies.
+// @Tags Health
+// @Success 200
+// @Router /healthz [get]
+func (h *Handler) Live(c *fiber.Ctx) error {
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// Ready godoc
+// @Summary Readiness probe
+// @Description Runs dependency checks (database, migrations, key material). Returns 503 when any check fails or the server is draining for shutdown.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} Report
+// @Failure 503 {object} Report
+// @Router /readyz [get]
+func (h *Handler) Ready(c *fiber.Ctx) error {
+	rep := h.checker.Run(c.Context())
+	status := http.StatusOK
+	if rep.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(rep)
+}
+
+func RegisterRoutes(r fiber.Router, checker *Checker) {
+	h := NewHandler(checker)
+	r.Get("/healthz", h.Live)
+	r.Get("/readyz", h.Ready)
+}
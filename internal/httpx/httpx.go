@@ -0,0 +1,72 @@
+package httpx
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorResponse is the error schema shared by every endpoint.
+type ErrorResponse struct {
+	Error struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
+		// Details lists the individual problems when there are several, e.g.
+		// each password rule broken.
+		Details any `json:"details,omitempty" swaggertype:"array,object"`
+	} `json:"error"`
+}
+
+func WriteError(c *fiber.Ctx, status int, code, msg string) error {
+	return WriteErrorDetails(c, status, code, msg, nil)
+}
+
+// WriteErrorDetails is WriteError with a details list.
+func WriteErrorDetails(c *fiber.Ctx, status int, code, msg string, details any) error {
+	resp := ErrorResponse{}
+	resp.Error.Code = code
+	resp.Error.Message = msg
+	resp.Error.Details = details
+	if id, ok := c.Locals("request_id").(string); ok {
+		resp.Error.RequestID = id
+	}
+	return c.Status(status).JSON(resp)
+}
+
+// ErrorHandler is the Fiber fallback for errors returned by handlers (unknown
+// routes, panics turned into errors) so they share the ErrorResponse schema.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	status := http.StatusInternalServerError
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		status = fe.Code
+	}
+	switch status {
+	case http.StatusNotFound:
+		return WriteError(c, status, "NOT_FOUND", "not found")
+	case http.StatusMethodNotAllowed:
+		return WriteError(c, status, "METHOD_NOT_ALLOWED", "method not allowed")
+	}
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.UserContext(), "unhandled error", "err", err, "path", c.Path())
+		return WriteError(c, status, "INTERNAL_ERROR", "internal error")
+	}
+	return WriteError(c, status, "REQUEST_ERROR", http.StatusText(status))
+}
+
+// CurrentUserID returns the authenticated user id set by middleware.AuthRequired.
+func CurrentUserID(c *fiber.Ctx) (uint, bool) {
+	idStr, ok := c.Locals("user_sub").(string)
+	if !ok {
+		return 0, false
+	}
+	uid, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(uid), true
+}
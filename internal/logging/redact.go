@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys are attribute / header names whose values are never logged.
+var secretKeys = map[string]bool{
+	"authorization":    true,
+	"password":         true,
+	"current_password": true,
+	"new_password":     true,
+	"password_hash":    true,
+	"token":            true,
+	"access_token":     true,
+	"refresh_token":    true,
+	"secret":           true,
+	"jwt_secret":       true,
+	"cookie":           true,
+	"set-cookie":       true,
+	"api_key":          true,
+	"x-api-key":        true,
+}
+
+// phoneKeys are masked down to their last four digits.
+var phoneKeys = map[string]bool{
+	"phone":        true,
+	"phone_number": true,
+	"mobile":       true,
+}
+
+var (
+	bearerRegex = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	// API keys (auth.APIKey): the wsk_<id> prefix is kept, the secret is masked.
+	apiKeyRegex = regexp.MustCompile(`\b(wsk_[0-9a-f]{12})_[A-Za-z0-9_-]+`)
+	// Thai-style phone numbers inside free text: 0812345678, 081-234-5678, +66 81 234 5678.
+	phoneTextRegex = regexp.MustCompile(`(?:\+66[\s-]?|\b0)\d{1,2}[\s-]?\d{3}[\s-]?\d{4}\b`)
+)
+
+// redactAttr is the slog ReplaceAttr hook applied to every attribute.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case secretKeys[key]:
+		return slog.String(a.Key, redacted)
+	case phoneKeys[key]:
+		return slog.String(a.Key, MaskPhone(a.Value.String()))
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, ScrubString(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case http.Header:
+			return slog.Any(a.Key, redactMulti(v))
+		case map[string][]string:
+			return slog.Any(a.Key, redactMulti(v))
+		case map[string]string:
+			out := make(map[string]string, len(v))
+			for k, val := range v {
+				out[k] = redactValue(k, val)
+			}
+			return slog.Any(a.Key, out)
+		case error:
+			return slog.String(a.Key, ScrubString(v.Error()))
+		}
+	}
+	return a
+}
+
+func redactMulti(m map[string][]string) map[string][]string {
+	out := make(map[string][]string, len(m))
+	for k, vals := range m {
+		cp := make([]string, len(vals))
+		for i, val := range vals {
+			cp[i] = redactValue(k, val)
+		}
+		out[k] = cp
+	}
+	return out
+}
+
+func redactValue(key, val string) string {
+	k := strings.ToLower(key)
+	switch {
+	case secretKeys[k]:
+		return redacted
+	case phoneKeys[k]:
+		return MaskPhone(val)
+	}
+	return ScrubString(val)
+}
+
+// ScrubString masks bearer tokens, API keys and phone numbers embedded in free text.
+func ScrubString(s string) string {
+	s = bearerRegex.ReplaceAllString(s, "Bearer "+redacted)
+	s = apiKeyRegex.ReplaceAllString(s, "${1}_"+redacted)
+	return phoneTextRegex.ReplaceAllStringFunc(s, MaskPhone)
+}
+
+// MaskPhone keeps only the last four digits, e.g. 0812345678 -> ******5678.
+func MaskPhone(p string) string {
+	var digits []byte
+	for i := 0; i < len(p); i++ {
+		if p[i] >= '0' && p[i] <= '9' {
+			digits = append(digits, p[i])
+		}
+	}
+	if len(digits) <= 4 {
+		return strings.Repeat("*", len(digits))
+	}
+	return strings.Repeat("*", len(digits)-4) + string(digits[len(digits)-4:])
+}
@@ -0,0 +1,73 @@
+// Package mailer sends transactional email. Services depend on the Mailer
+// interface; main picks SMTP when SMTP_ADDR is set and the log mailer otherwise.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Log writes messages to the application log instead of sending them. For
+// development only: bodies contain confirmation links.
+type Log struct{}
+
+func NewLog() Log { return Log{} }
+
+func (Log) Send(ctx context.Context, m Message) error {
+	slog.InfoContext(ctx, "email (not sent: log mailer)", "to", m.To, "subject", m.Subject, "body", m.Body)
+	return nil
+}
+
+// SMTP delivers through an SMTP relay using STARTTLS when offered.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP sends from the given address through addr (host:port). Username and
+// password enable PLAIN auth; leave both empty for an unauthenticated relay.
+func NewSMTP(addr, from, username, password string) *SMTP {
+	s := &SMTP{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return fmt.Errorf("mailer: header injection in recipient or subject")
+	}
+	msg := strings.Join([]string{
+		"From: " + s.from,
+		"To: " + m.To,
+		"Subject: " + m.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		m.Body,
+	}, "\r\n")
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{m.To}, []byte(msg)); err != nil {
+		return fmt.Errorf("mailer: send to smtp relay: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"workshop-be/internal/db"
+)
+
+const startKey = "metrics:start"
+
+// GormPlugin times every GORM statement into db_query_duration_seconds.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string { return "metrics" }
+
+func (GormPlugin) Initialize(g *gorm.DB) error {
+	return db.RegisterHooks(g, "metrics", func(string) func(*gorm.DB) { return before }, after)
+}
+
+func before(g *gorm.DB) {
+	g.InstanceSet(startKey, time.Now())
+}
+
+func after(op string) func(*gorm.DB) {
+	return func(g *gorm.DB) {
+		v, ok := g.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		table := g.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		dbQueryDuration.WithLabelValues(op, table).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"time"
+
+	"workshop-be/pkg/password"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "workshop"
+
+// Registry holds every collector exposed on /metrics. A dedicated registry keeps
+// third-party default collectors from leaking in unnoticed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_logins_total",
+		Help:      "Login attempts by result and error code (empty on success).",
+	}, []string{"result", "code"})
+
+	registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_registrations_total",
+		Help:      "Registration attempts by result and error code (empty on success).",
+	}, []string{"result", "code"})
+
+	passwordDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "password_hash_duration_seconds",
+		Help:      "Time spent in password hashing and verification.",
+		// Default argon2id and legacy bcrypt-12 hashes take roughly 50-300ms,
+		// plus any time queued for a worker; buckets focus on that range.
+		Buckets: []float64{.025, .05, .1, .2, .3, .4, .5, .75, 1, 2},
+	}, []string{"op"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "GORM query latency by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		logins,
+		registrations,
+		passwordDuration,
+		dbQueryDuration,
+	)
+}
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+func LoginSucceeded() { logins.WithLabelValues(resultSuccess, "").Inc() }
+
+func LoginFailed(code string) { logins.WithLabelValues(resultFailure, code).Inc() }
+
+func RegistrationSucceeded() { registrations.WithLabelValues(resultSuccess, "").Inc() }
+
+func RegistrationFailed(code string) { registrations.WithLabelValues(resultFailure, code).Inc() }
+
+// ObservePassword records the duration of a password operation ("hash" or "verify").
+func ObservePassword(op string, d time.Duration) {
+	passwordDuration.WithLabelValues(op).Observe(d.Seconds())
+}
+
+// WatchPasswordPool exposes the load of the password hashing pool, read
+// from stats at scrape time.
+func WatchPasswordPool(stats func() password.PoolStats) {
+	gauge := func(name, help string, v func(password.PoolStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help},
+			func() float64 { return v(stats()) })
+	}
+	counter := func(name, help string, labels prometheus.Labels, v func(password.PoolStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help, ConstLabels: labels},
+			func() float64 { return v(stats()) })
+	}
+	const rejectedHelp = "Password hashes refused with 503 because the pool was saturated, by reason."
+	Registry.MustRegister(
+		gauge("password_pool_workers", "Password hashes allowed to run at once.",
+			func(s password.PoolStats) float64 { return float64(s.Workers) }),
+		gauge("password_pool_in_use", "Password hashes running now.",
+			func(s password.PoolStats) float64 { return float64(s.InUse) }),
+		gauge("password_pool_queued", "Password hashes waiting for a worker.",
+			func(s password.PoolStats) float64 { return float64(s.Queued) }),
+		counter("password_pool_acquired_total", "Password hashes that got a worker.", nil,
+			func(s password.PoolStats) float64 { return float64(s.Acquired) }),
+		counter("password_pool_wait_seconds_total", "Time password hashes spent queueing before getting a worker.", nil,
+			func(s password.PoolStats) float64 { return s.WaitTotal.Seconds() }),
+		counter("password_pool_rejected_total", rejectedHelp, prometheus.Labels{"reason": "queue_full"},
+			func(s password.PoolStats) float64 { return float64(s.QueueFull) }),
+		counter("password_pool_rejected_total", rejectedHelp, prometheus.Labels{"reason": "timeout"},
+			func(s password.PoolStats) float64 { return float64(s.TimedOut) }),
+	)
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"workshop-be/internal/httpx"
+)
+
+// unmatchedRoute labels requests that hit no route, so scanners probing random
+// paths cannot blow up label cardinality.
+const unmatchedRoute = "unmatched"
+
+// Middleware records request count and latency labelled by route template
+// (e.g. /api/v1/profile/) rather than the raw request path.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+		route := c.Route().Path
+		if route == "/" && c.Path() != "/" {
+			route = unmatchedRoute
+		}
+		// c.Method() aliases fasthttp's buffer; labels outlive the request.
+		labels := []string{strings.Clone(c.Method()), route, strconv.Itoa(status)}
+		httpRequests.WithLabelValues(labels...).Inc()
+		httpDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Handler serves the Prometheus exposition format for Registry. With a
+// non-empty token, scrapers must send it as "Authorization: Bearer <token>".
+func Handler(token string) fiber.Handler {
+	serve := adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	if token == "" {
+		return serve
+	}
+	want := []byte("Bearer " + token)
+	return func(c *fiber.Ctx) error {
+		if subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), want) != 1 {
+			return httpx.WriteError(c, fiber.StatusUnauthorized, "UNAUTHORIZED", "missing or invalid token")
+		}
+		return serve(c)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccessLog writes one structured line per request. Only the path is logged:
+// query strings and bodies may carry credentials.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(c.UserContext(), level, "http request",
+			"method", c.Method(),
+			"path", c.Path(),
+			"route", c.Route().Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"ip", c.IP(),
+			"user_agent", c.Get(fiber.HeaderUserAgent),
+		)
+		return err
+	}
+}
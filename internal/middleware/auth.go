@@ -1,26 +1,78 @@
 package middleware
 
 import (
+	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
 
 	"workshop-be/internal/auth"
+	"workshop-be/internal/httpx"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func AuthRequired() fiber.Handler {
+// APIKeyHeader carries an API key for machine clients.
+const APIKeyHeader = "X-API-Key"
+
+// AuthRequired accepts a Bearer JWT, or an API key in the X-API-Key header
+// when the route lists a scope the key holds. Without scopes the route is for
+// signed-in users only and API keys are refused.
+func AuthRequired(scopes ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		h := c.Get("Authorization")
+		if h == "" {
+			if key := c.Get(APIKeyHeader); key != "" {
+				return apiKey(c, key, scopes)
+			}
+		}
 		if h == "" || !strings.HasPrefix(h, "Bearer ") {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": fiber.Map{"code": "UNAUTHORIZED", "message": "missing or invalid token"}})
+			return unauthorized(c, "missing or invalid token")
 		}
 		token := strings.TrimPrefix(h, "Bearer ")
 		claims, err := auth.ParseToken(token)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": fiber.Map{"code": "UNAUTHORIZED", "message": "invalid token"}})
+			return unauthorized(c, "invalid token")
 		}
 		c.Locals("user_email", claims.Email)
 		c.Locals("user_sub", claims.Subject)
+		c.Locals("user_role", claims.Role)
 		return c.Next()
 	}
 }
+
+func apiKey(c *fiber.Ctx, key string, scopes []string) error {
+	p, err := auth.AuthenticateAPIKey(c.UserContext(), key, c.IP())
+	if err != nil {
+		if err != auth.ErrInvalidAPIKey {
+			slog.ErrorContext(c.UserContext(), "api key lookup failed", "err", err)
+			return httpx.WriteError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+		return unauthorized(c, "invalid api key")
+	}
+	if !slices.ContainsFunc(scopes, func(s string) bool { return slices.Contains(p.Scopes, s) }) {
+		return httpx.WriteError(c, fiber.StatusForbidden, "INSUFFICIENT_SCOPE", "api key is not allowed to use this endpoint")
+	}
+	c.Locals("user_email", p.Email)
+	c.Locals("user_sub", strconv.FormatUint(uint64(p.UserID), 10))
+	c.Locals("user_role", p.Role)
+	c.Locals("api_key_id", p.KeyID)
+	return c.Next()
+}
+
+// RequireRole must run after AuthRequired; it rejects tokens whose role is not listed.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("user_role").(string)
+		for _, r := range roles {
+			if role == r {
+				return c.Next()
+			}
+		}
+		return httpx.WriteError(c, fiber.StatusForbidden, "FORBIDDEN", "insufficient role")
+	}
+}
+
+func unauthorized(c *fiber.Ctx, msg string) error {
+	return httpx.WriteError(c, fiber.StatusUnauthorized, "UNAUTHORIZED", msg)
+}
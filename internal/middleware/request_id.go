@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"workshop-be/internal/logging"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// incoming ids are accepted only if short and free of characters that could
+// forge log lines or headers.
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID reuses a well-formed X-Request-ID from the caller or generates one,
+// echoes it on the response and stores it in Locals("request_id") and the user
+// context so logs and error bodies carry it.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(RequestIDHeader)
+		if requestIDRegex.MatchString(id) {
+			id = strings.Clone(id)
+		} else {
+			id = newRequestID()
+		}
+		c.Locals("request_id", id)
+		c.Set(RequestIDHeader, id)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,395 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"workshop-be/internal/httpx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Discovery godoc
+// @Summary OpenID Connect discovery document
+// @Tags OAuth
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Router /.well-known/openid-configuration [get]
+func (h *Handler) Discovery(c *fiber.Ctx) error {
+	return c.JSON(h.svc.Discovery())
+}
+
+// JWKS godoc
+// @Summary Token signing keys
+// @Tags OAuth
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Router /oauth/jwks [get]
+func (h *Handler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	return c.JSON(h.svc.JWKS())
+}
+
+// Authorize godoc
+// @Summary Authorization endpoint
+// @Description Client apps send the browser here. A valid request is forwarded to the frontend consent page with the same query; otherwise the browser returns to the client with an error. Unknown clients and unregistered redirect URIs are answered directly.
+// @Tags OAuth
+// @Param response_type query string true "code"
+// @Param client_id query string true "client id"
+// @Param redirect_uri query string false "registered redirect URI"
+// @Param scope query string true "space-separated, e.g. openid profile email"
+// @Param state query string false "opaque client state"
+// @Param nonce query string false "echoed in the ID token"
+// @Param code_challenge query string true "PKCE challenge"
+// @Param code_challenge_method query string true "S256"
+// @Success 302
+// @Failure 400 {object} httpx.ErrorResponse
+// @Router /oauth/authorize [get]
+func (h *Handler) Authorize(c *fiber.Ctx) error {
+	var r AuthorizeRequest
+	if err := c.QueryParser(&r); err != nil {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_REQUEST", "invalid authorization request")
+	}
+	loc, err := h.svc.Authorize(c.UserContext(), r, string(c.Request().URI().QueryString()))
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+	return c.Redirect(loc, http.StatusFound)
+}
+
+// Consent godoc
+// @Summary Consent screen data
+// @Description Called by the frontend consent page with the authorization request it received. Granted means the user already approved these scopes for this app.
+// @Tags OAuth
+// @Security BearerAuth
+// @Produce json
+// @Param client_id query string true "client id"
+// @Param scope query string true "requested scopes"
+// @Success 200 {object} ConsentScreen
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/oauth/authorize [get]
+func (h *Handler) Consent(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var r AuthorizeRequest
+	if err := c.QueryParser(&r); err != nil {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_REQUEST", "invalid authorization request")
+	}
+	out, err := h.svc.Consent(c.UserContext(), uid, r)
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+	return c.JSON(out)
+}
+
+// Decide godoc
+// @Summary Approve or deny an app
+// @Description Send the authorization request fields plus approve. The response says where to send the browser: the client's redirect URI with a code, or with error=access_denied.
+// @Tags OAuth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body DecisionInput true "authorization request and decision"
+// @Success 200 {object} DecisionOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/oauth/authorize [post]
+func (h *Handler) Decide(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in DecisionInput
+	if err := c.BodyParser(&in); err != nil {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.Decide(c.UserContext(), uid, in)
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+	return c.JSON(out)
+}
+
+func (h *Handler) authorizeError(c *fiber.Ctx, err error) error {
+	var oe *Error
+	switch {
+	case errors.Is(err, ErrUnknownClient):
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_CLIENT", "unknown or disabled client")
+	case errors.Is(err, ErrRedirectMismatch):
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_REDIRECT_URI", "redirect_uri is not registered for this client")
+	case errors.As(err, &oe):
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_AUTHORIZE_REQUEST", oe.Description)
+	}
+	slog.ErrorContext(c.UserContext(), "oauth authorize failed", "err", err)
+	return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+}
+
+// Token godoc
+// @Summary Token endpoint
+// @Description grant_type=authorization_code (with code, redirect_uri, code_verifier) or client_credentials (optional scope). Clients authenticate with HTTP Basic or client_id/client_secret; public clients send client_id only. Errors use the RFC 6749 format.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code or client_credentials"
+// @Param code formData string false "authorization code"
+// @Param redirect_uri formData string false "same redirect_uri as the authorization request; required when that request had one"
+// @Param code_verifier formData string false "PKCE verifier"
+// @Param scope formData string false "client_credentials scopes"
+// @Param client_id formData string false "client id (without Basic auth)"
+// @Param client_secret formData string false "client secret (without Basic auth)"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} Error
+// @Failure 401 {object} Error
+// @Router /oauth/token [post]
+func (h *Handler) Token(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	var r TokenRequest
+	if err := c.BodyParser(&r); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(oauthError("invalid_request", "expected a form body"))
+	}
+	basic := false
+	if id, secret, ok := basicAuth(c.Get(fiber.HeaderAuthorization)); ok {
+		r.ClientID, r.ClientSecret, basic = id, secret, true
+	}
+	out, err := h.svc.Token(c.UserContext(), r)
+	if err != nil {
+		var oe *Error
+		if !errors.As(err, &oe) {
+			slog.ErrorContext(c.UserContext(), "oauth token failed", "err", err)
+			return c.Status(http.StatusInternalServerError).JSON(oauthError("server_error", ""))
+		}
+		if oe.Code == "invalid_client" {
+			if basic {
+				c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+			}
+			return c.Status(http.StatusUnauthorized).JSON(oe)
+		}
+		return c.Status(http.StatusBadRequest).JSON(oe)
+	}
+	return c.JSON(out)
+}
+
+// UserInfo godoc
+// @Summary UserInfo endpoint
+// @Description Claims about the user allowed by the access token's scopes (openid required).
+// @Tags OAuth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /oauth/userinfo [get]
+func (h *Handler) UserInfo(c *fiber.Ctx) error {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer`)
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "missing access token")
+	}
+	out, err := h.svc.UserInfo(c.UserContext(), token)
+	if err != nil {
+		if err == ErrInvalidToken {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return httpx.WriteError(c, http.StatusUnauthorized, "INVALID_TOKEN", "access token is invalid, expired or lacks the openid scope")
+		}
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(out)
+}
+
+// CreateClient godoc
+// @Summary Register an OAuth client (admin)
+// @Description The client_secret is returned only in this response.
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body ClientInput true "client"
+// @Success 201 {object} ClientCreated
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/oauth-clients [post]
+func (h *Handler) CreateClient(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in ClientInput
+	if err := c.BodyParser(&in); err != nil {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.CreateClient(c.UserContext(), uid, in)
+	if err != nil {
+		switch err {
+		case ErrInvalidName:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_NAME", "name is required (max 100 characters)")
+		case ErrInvalidRedirectURI:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_REDIRECT_URI", "authorization_code clients need redirect URIs; each must be https (http only for localhost) without a fragment")
+		case ErrInvalidGrantTypes:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_GRANT_TYPES", "grant_types must be authorization_code and/or client_credentials; public clients can't use client_credentials")
+		case ErrInvalidScopes:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_SCOPES", "scopes must be lowercase names such as openid or members.read")
+		default:
+			return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusCreated).JSON(out)
+}
+
+// ListClients godoc
+// @Summary List OAuth clients (admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} Client
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/oauth-clients [get]
+func (h *Handler) ListClients(c *fiber.Ctx) error {
+	out, err := h.svc.Clients(c.UserContext())
+	if err != nil {
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// DisableClient godoc
+// @Summary Disable an OAuth client (admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param client_id path string true "client id"
+// @Success 204
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/oauth-clients/{client_id} [delete]
+func (h *Handler) DisableClient(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	if err := h.svc.DisableClient(c.UserContext(), uid, c.Params("client_id")); err != nil {
+		if err == ErrClientNotFound {
+			return httpx.WriteError(c, http.StatusNotFound, "CLIENT_NOT_FOUND", "client not found")
+		}
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// ListGrants godoc
+// @Summary List connected apps
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} GrantView
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/oauth-grants [get]
+func (h *Handler) ListGrants(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.Grants(c.UserContext(), uid)
+	if err != nil {
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// RevokeGrant godoc
+// @Summary Disconnect an app
+// @Description The app must ask for consent again. Access tokens it already holds expire on their own.
+// @Tags Profile
+// @Security BearerAuth
+// @Param client_id path string true "client id"
+// @Success 204
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/oauth-grants/{client_id} [delete]
+func (h *Handler) RevokeGrant(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	if err := h.svc.RevokeGrant(c.UserContext(), uid, c.Params("client_id")); err != nil {
+		if err == ErrGrantNotFound {
+			return httpx.WriteError(c, http.StatusNotFound, "GRANT_NOT_FOUND", "app is not connected")
+		}
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// basicAuth parses client credentials from an Authorization: Basic header;
+// both parts are form-encoded (RFC 6749 section 2.3.1).
+func basicAuth(header string) (string, string, bool) {
+	raw, ok := strings.CutPrefix(header, "Basic ")
+	if !ok {
+		return "", "", false
+	}
+	dec, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(string(dec), ":")
+	if !ok {
+		return "", "", false
+	}
+	id, err1 := url.QueryUnescape(id)
+	secret, err2 := url.QueryUnescape(secret)
+	return id, secret, err1 == nil && err2 == nil
+}
+
+// RegisterProtocolRoutes mounts discovery and the OAuth endpoints client
+// apps call directly, at the root of the issuer URL.
+func RegisterProtocolRoutes(app fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	app.Get("/.well-known/openid-configuration", h.Discovery)
+	app.Get("/oauth/jwks", h.JWKS)
+	app.Get("/oauth/authorize", h.Authorize)
+	app.Post("/oauth/token", h.Token)
+	app.Get("/oauth/userinfo", h.UserInfo)
+	app.Post("/oauth/userinfo", h.UserInfo)
+}
+
+// RegisterConsentRoutes mounts the consent screen API; r must require a
+// signed-in member.
+func RegisterConsentRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	r.Get("/authorize", h.Consent)
+	r.Post("/authorize", h.Decide)
+}
+
+// RegisterProfileRoutes mounts the member's connected apps on the profile group.
+func RegisterProfileRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	r.Get("/oauth-grants", h.ListGrants)
+	r.Delete("/oauth-grants/:client_id", h.RevokeGrant)
+}
+
+// RegisterAdminRoutes mounts client registration; r must restrict access to admins.
+func RegisterAdminRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc)
+	r.Post("/oauth-clients", h.CreateClient)
+	r.Get("/oauth-clients", h.ListClients)
+	r.Delete("/oauth-clients/:client_id", h.DisableClient)
+}
@@ -0,0 +1,756 @@
+// Package oauth makes this service a minimal OAuth2 / OpenID Connect
+// provider for other company apps: registered clients, the authorization
+// code flow with PKCE, client_credentials for machine clients, userinfo
+// and discovery.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/auth"
+	"workshop-be/internal/db"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+)
+
+var tracer = otel.Tracer("workshop-be/internal/oauth")
+
+var (
+	ErrInvalidName        = errors.New("invalid client name")
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+	ErrInvalidGrantTypes  = errors.New("invalid grant types")
+	ErrInvalidScopes      = errors.New("invalid scopes")
+	ErrClientNotFound     = errors.New("client not found")
+	ErrGrantNotFound      = errors.New("grant not found")
+	// ErrUnknownClient and ErrRedirectMismatch make an authorization request
+	// unsafe to redirect back; they are shown to the user instead.
+	ErrUnknownClient    = errors.New("unknown client")
+	ErrRedirectMismatch = errors.New("redirect uri not registered")
+	ErrInvalidToken     = errors.New("invalid access token")
+)
+
+// Error is an OAuth2 protocol error (RFC 6749 section 4.1.2.1 and 5.2).
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Code + ": " + e.Description }
+
+func oauthError(code, desc string) *Error { return &Error{Code: code, Description: desc} }
+
+// UserScopes are the OpenID Connect scopes a user can consent to, with the
+// text shown on the consent screen.
+var UserScopes = map[string]string{
+	"openid":  "Sign you in with your member account",
+	"profile": "Your name, picture, date of birth, gender, language and membership level",
+	"email":   "Your email address",
+	"phone":   "Your phone number",
+	"address": "Your postal address",
+}
+
+var (
+	scopeRegex    = regexp.MustCompile(`^[a-z][a-z0-9_.:-]{0,63}$`)
+	defaultScopes = []string{"openid", "profile", "email"}
+)
+
+const (
+	// codeTTL is how long an authorization code can be redeemed.
+	codeTTL          = time.Minute
+	defaultAccessTTL = 15 * time.Minute
+)
+
+// Profiles supplies userinfo claims; *auth.Service implements it.
+type Profiles interface {
+	GetProfile(ctx context.Context, userID uint) (*auth.ProfileResponse, error)
+}
+
+type Service struct {
+	key      *rsa.PrivateKey
+	kid      string
+	profiles Profiles
+	audit    *audit.Service
+	// Issuer is the public base URL of this service; it prefixes every
+	// endpoint in the discovery document and is the iss of issued tokens.
+	Issuer string
+	// ConsentURL is the frontend page that shows the consent screen; the
+	// authorization request is passed on in its query string.
+	ConsentURL string
+	// AccessTTL is the lifetime of access and ID tokens.
+	AccessTTL time.Duration
+}
+
+func NewService(issuer string, key *rsa.PrivateKey, profiles Profiles) *Service {
+	return &Service{
+		key:        key,
+		kid:        keyID(&key.PublicKey),
+		profiles:   profiles,
+		audit:      audit.NewService(),
+		Issuer:     strings.TrimSuffix(issuer, "/"),
+		ConsentURL: "http://localhost:3000/oauth/consent",
+		AccessTTL:  defaultAccessTTL,
+	}
+}
+
+// Discovery is the OpenID Provider metadata document.
+func (s *Service) Discovery() map[string]any {
+	scopes := []string{"openid", "profile", "email", "phone", "address"}
+	return map[string]any{
+		"issuer":                                s.Issuer,
+		"authorization_endpoint":                s.Issuer + "/oauth/authorize",
+		"token_endpoint":                        s.Issuer + "/oauth/token",
+		"userinfo_endpoint":                     s.Issuer + "/oauth/userinfo",
+		"jwks_uri":                              s.Issuer + "/oauth/jwks",
+		"scopes_supported":                      scopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{GrantAuthorizationCode, GrantClientCredentials},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported": []string{"sub", "email", "name", "given_name", "family_name", "picture", "birthdate",
+			"gender", "locale", "phone_number", "address", "membership_level", "membership_code"},
+		"authorization_response_iss_parameter_supported": true,
+	}
+}
+
+// Client registration
+
+type ClientInput struct {
+	Name         string   `json:"name" example:"Workshop Booking"`
+	RedirectURIs []string `json:"redirect_uris" example:"https://booking.example.com/callback"`
+	// Scopes the client may request; defaults to openid, profile and email.
+	// Custom scopes (e.g. members.read) are for client_credentials tokens.
+	Scopes []string `json:"scopes" example:"openid,profile,email"`
+	// GrantTypes: authorization_code and/or client_credentials; defaults to authorization_code.
+	GrantTypes []string `json:"grant_types" example:"authorization_code"`
+	// Public clients (SPAs, mobile apps) get no secret and must use PKCE.
+	Public bool `json:"public"`
+}
+
+type ClientCreated struct {
+	Client
+	// ClientSecret is shown only once; it is stored hashed.
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// CreateClient registers an application and generates its credentials.
+func (s *Service) CreateClient(ctx context.Context, actorID uint, in ClientInput) (*ClientCreated, error) {
+	ctx, span := tracer.Start(ctx, "oauth.Service.CreateClient")
+	defer span.End()
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > 100 {
+		return nil, ErrInvalidName
+	}
+	if len(in.GrantTypes) == 0 {
+		in.GrantTypes = []string{GrantAuthorizationCode}
+	}
+	for _, g := range in.GrantTypes {
+		if g != GrantAuthorizationCode && g != GrantClientCredentials {
+			return nil, ErrInvalidGrantTypes
+		}
+	}
+	if in.Public && contains(in.GrantTypes, GrantClientCredentials) {
+		return nil, ErrInvalidGrantTypes
+	}
+	if contains(in.GrantTypes, GrantAuthorizationCode) && len(in.RedirectURIs) == 0 {
+		return nil, ErrInvalidRedirectURI
+	}
+	for _, u := range in.RedirectURIs {
+		if !validRedirectURI(u) {
+			return nil, ErrInvalidRedirectURI
+		}
+	}
+	if len(in.Scopes) == 0 {
+		in.Scopes = defaultScopes
+	}
+	for _, sc := range in.Scopes {
+		if !scopeRegex.MatchString(sc) {
+			return nil, ErrInvalidScopes
+		}
+	}
+	out := &ClientCreated{Client: Client{
+		ClientID:     randomToken(16),
+		Name:         in.Name,
+		Public:       in.Public,
+		RedirectURIs: in.RedirectURIs,
+		Scopes:       in.Scopes,
+		GrantTypes:   in.GrantTypes,
+		CreatedBy:    actorID,
+	}}
+	if !in.Public {
+		out.ClientSecret = randomToken(32)
+		out.SecretHash = hashToken(out.ClientSecret)
+	}
+	if err := db.MustGet().WithContext(ctx).Create(&out.Client).Error; err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, ActorID: &actorID, Details: map[string]any{"action": "oauth_client.create", "client_id": out.ClientID, "client": out.Client}})
+	return out, nil
+}
+
+// Clients lists registered clients, newest first.
+func (s *Service) Clients(ctx context.Context) ([]Client, error) {
+	out := []Client{}
+	err := db.MustGet().WithContext(ctx).Order("id DESC").Find(&out).Error
+	return out, err
+}
+
+// DisableClient stops a client from starting logins or getting tokens.
+// Tokens it already holds stay valid until they expire.
+func (s *Service) DisableClient(ctx context.Context, actorID uint, clientID string) error {
+	now := time.Now()
+	res := db.MustGet().WithContext(ctx).Model(&Client{}).
+		Where("client_id = ? AND disabled_at IS NULL", clientID).Update("disabled_at", &now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrClientNotFound
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, ActorID: &actorID, Details: map[string]any{"action": "oauth_client.disable", "client_id": clientID}})
+	return nil
+}
+
+func (s *Service) client(ctx context.Context, clientID string) (*Client, error) {
+	var c Client
+	err := db.MustGet().WithContext(ctx).Where("client_id = ? AND disabled_at IS NULL", clientID).Take(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrClientNotFound
+	}
+	return &c, err
+}
+
+// Authorization endpoint
+
+// AuthorizeRequest is the authorization request as sent by the client app.
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" query:"response_type"`
+	ClientID            string `json:"client_id" query:"client_id"`
+	RedirectURI         string `json:"redirect_uri" query:"redirect_uri"`
+	Scope               string `json:"scope" query:"scope"`
+	State               string `json:"state" query:"state"`
+	Nonce               string `json:"nonce" query:"nonce"`
+	CodeChallenge       string `json:"code_challenge" query:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" query:"code_challenge_method"`
+	// Prompt=consent shows the consent screen even if already granted.
+	Prompt string `json:"prompt" query:"prompt"`
+}
+
+// checked is a validated authorization request.
+type checked struct {
+	client      *Client
+	redirectURI string
+	scopes      []string
+}
+
+// authorize validates an authorization request. ErrUnknownClient and
+// ErrRedirectMismatch must be shown to the user; an *Error is returned to the
+// client at the redirect URI (see redirectError).
+func (s *Service) authorize(ctx context.Context, r AuthorizeRequest) (*checked, error) {
+	c, err := s.client(ctx, r.ClientID)
+	if errors.Is(err, ErrClientNotFound) || (err == nil && !c.allows(GrantAuthorizationCode)) {
+		return nil, ErrUnknownClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	redirect := r.RedirectURI
+	if redirect == "" && len(c.RedirectURIs) == 1 {
+		redirect = c.RedirectURIs[0]
+	}
+	if !contains(c.RedirectURIs, redirect) {
+		return nil, ErrRedirectMismatch
+	}
+	out := &checked{client: c, redirectURI: redirect}
+	if r.ResponseType != "code" {
+		return out, oauthError("unsupported_response_type", "only response_type=code is supported")
+	}
+	if r.CodeChallenge == "" || r.CodeChallengeMethod != "S256" {
+		return out, oauthError("invalid_request", "PKCE with code_challenge_method=S256 is required")
+	}
+	out.scopes = strings.Fields(r.Scope)
+	if len(out.scopes) == 0 {
+		return out, oauthError("invalid_scope", "scope is required")
+	}
+	for _, sc := range out.scopes {
+		if _, ok := UserScopes[sc]; !ok || !contains(c.Scopes, sc) {
+			return out, oauthError("invalid_scope", "scope not allowed: "+sc)
+		}
+	}
+	return out, nil
+}
+
+// Authorize handles the browser hitting the authorization endpoint. It
+// returns where to send the browser: the consent page, with the request
+// passed on as rawQuery, or the client with an error.
+func (s *Service) Authorize(ctx context.Context, r AuthorizeRequest, rawQuery string) (string, error) {
+	ctx, span := tracer.Start(ctx, "oauth.Service.Authorize")
+	defer span.End()
+	chk, err := s.authorize(ctx, r)
+	var oe *Error
+	if errors.As(err, &oe) {
+		return s.redirectError(chk, r.State, oe), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.ConsentURL + "?" + rawQuery, nil
+}
+
+// redirectError builds the redirect carrying err back to the client.
+func (s *Service) redirectError(chk *checked, state string, err *Error) string {
+	q := url.Values{"error": {err.Code}, "iss": {s.Issuer}}
+	if err.Description != "" {
+		q.Set("error_description", err.Description)
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	return withQuery(chk.redirectURI, q)
+}
+
+// ConsentScope is one requested scope as shown on the consent screen.
+type ConsentScope struct {
+	Name        string `json:"name" example:"email"`
+	Description string `json:"description" example:"Your email address"`
+}
+
+// ConsentScreen is what the frontend needs to ask the signed-in user.
+type ConsentScreen struct {
+	ClientID   string         `json:"client_id"`
+	ClientName string         `json:"client_name"`
+	Scopes     []ConsentScope `json:"scopes"`
+	// Granted is true when the user already approved these scopes for this
+	// client; the frontend may approve without asking.
+	Granted bool `json:"granted"`
+}
+
+// Consent returns the consent screen for a validated request.
+func (s *Service) Consent(ctx context.Context, userID uint, r AuthorizeRequest) (*ConsentScreen, error) {
+	ctx, span := tracer.Start(ctx, "oauth.Service.Consent")
+	defer span.End()
+	chk, err := s.authorize(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	out := &ConsentScreen{ClientID: chk.client.ClientID, ClientName: chk.client.Name, Scopes: []ConsentScope{}}
+	for _, sc := range chk.scopes {
+		out.Scopes = append(out.Scopes, ConsentScope{Name: sc, Description: UserScopes[sc]})
+	}
+	if r.Prompt != "consent" {
+		var g Grant
+		if db.MustGet().WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, chk.client.ClientID).Take(&g).Error == nil {
+			out.Granted = covers(g.Scopes, chk.scopes)
+		}
+	}
+	return out, nil
+}
+
+type DecisionInput struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+type DecisionOutput struct {
+	// RedirectTo is where the frontend sends the browser next: the client's
+	// redirect URI with either code or error.
+	RedirectTo string `json:"redirect_to"`
+}
+
+// Decide records the user's answer on the consent screen. Approval stores
+// the grant and issues an authorization code.
+func (s *Service) Decide(ctx context.Context, userID uint, in DecisionInput) (*DecisionOutput, error) {
+	ctx, span := tracer.Start(ctx, "oauth.Service.Decide")
+	defer span.End()
+	chk, err := s.authorize(ctx, in.AuthorizeRequest)
+	var oe *Error
+	if errors.As(err, &oe) {
+		return &DecisionOutput{RedirectTo: s.redirectError(chk, in.State, oe)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !in.Approve {
+		return &DecisionOutput{RedirectTo: s.redirectError(chk, in.State, oauthError("access_denied", "the user declined"))}, nil
+	}
+	if err := s.grant(ctx, userID, chk.client.ClientID, chk.scopes); err != nil {
+		return nil, err
+	}
+	code := randomToken(32)
+	ac := AuthCode{
+		CodeHash:        hashToken(code),
+		ClientID:        chk.client.ClientID,
+		UserID:          userID,
+		RedirectURI:     chk.redirectURI,
+		RedirectURISent: in.RedirectURI != "",
+		Scope:           strings.Join(chk.scopes, " "),
+		Nonce:           in.Nonce,
+		CodeChallenge:   in.CodeChallenge,
+		ExpiresAt:       time.Now().Add(codeTTL),
+	}
+	d := db.MustGet().WithContext(ctx)
+	d.Where("expires_at < ?", time.Now()).Delete(&AuthCode{})
+	if err := d.Create(&ac).Error; err != nil {
+		return nil, err
+	}
+	q := url.Values{"code": {code}, "iss": {s.Issuer}}
+	if in.State != "" {
+		q.Set("state", in.State)
+	}
+	return &DecisionOutput{RedirectTo: withQuery(chk.redirectURI, q)}, nil
+}
+
+// grant adds scopes to the user's grant for the client, auditing new scopes.
+func (s *Service) grant(ctx context.Context, userID uint, clientID string, scopes []string) error {
+	d := db.MustGet().WithContext(ctx)
+	var g Grant
+	err := d.Where("user_id = ? AND client_id = ?", userID, clientID).Take(&g).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil && covers(g.Scopes, scopes) {
+		return nil
+	}
+	var added []string
+	for _, sc := range scopes {
+		if !contains(g.Scopes, sc) {
+			added = append(added, sc)
+		}
+	}
+	g.UserID, g.ClientID = userID, clientID
+	g.Scopes = append(g.Scopes, added...)
+	if err := d.Save(&g).Error; err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventOAuthGrant, ActorID: &userID, UserID: &userID, Details: map[string]any{"client_id": clientID, "scopes": added}})
+	return nil
+}
+
+// GrantView is a connected app as listed to its user.
+type GrantView struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// Grants lists the apps the user has approved.
+func (s *Service) Grants(ctx context.Context, userID uint) ([]GrantView, error) {
+	d := db.MustGet().WithContext(ctx)
+	var rows []Grant
+	if err := d.Where("user_id = ?", userID).Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]GrantView, 0, len(rows))
+	for _, g := range rows {
+		v := GrantView{ClientID: g.ClientID, Scopes: g.Scopes, GrantedAt: g.CreatedAt}
+		var c Client
+		if d.Where("client_id = ?", g.ClientID).Take(&c).Error == nil {
+			v.ClientName = c.Name
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// RevokeGrant forgets the user's approval; the app must ask again. Access
+// tokens already issued stay valid until they expire.
+func (s *Service) RevokeGrant(ctx context.Context, userID uint, clientID string) error {
+	res := db.MustGet().WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&Grant{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrGrantNotFound
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventOAuthRevoke, ActorID: &userID, UserID: &userID, Details: map[string]any{"client_id": clientID}})
+	return nil
+}
+
+// Forget deletes a purged user's grants and unredeemed authorization codes
+// using the purge transaction tx; see auth.Service.OnPurge.
+func (s *Service) Forget(ctx context.Context, tx *gorm.DB, userID uint) error {
+	d := tx.WithContext(ctx)
+	if err := d.Where("user_id = ?", userID).Delete(&Grant{}).Error; err != nil {
+		return err
+	}
+	return d.Where("user_id = ?", userID).Delete(&AuthCode{}).Error
+}
+
+// Token endpoint
+
+// TokenRequest is the form posted to the token endpoint. Client credentials
+// come from HTTP Basic auth or the client_id/client_secret fields.
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+// Token redeems an authorization code or issues a client_credentials token.
+// Failures are *Error values for the RFC 6749 error response.
+func (s *Service) Token(ctx context.Context, r TokenRequest) (*TokenResponse, error) {
+	ctx, span := tracer.Start(ctx, "oauth.Service.Token")
+	defer span.End()
+	c, err := s.authenticate(ctx, r.ClientID, r.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	switch r.GrantType {
+	case GrantAuthorizationCode:
+		return s.redeemCode(ctx, c, r)
+	case GrantClientCredentials:
+		return s.clientCredentials(c, r)
+	}
+	return nil, oauthError("unsupported_grant_type", "")
+}
+
+func (s *Service) authenticate(ctx context.Context, clientID, secret string) (*Client, error) {
+	invalid := oauthError("invalid_client", "client authentication failed")
+	if clientID == "" {
+		return nil, invalid
+	}
+	c, err := s.client(ctx, clientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Public {
+		if secret != "" {
+			return nil, invalid
+		}
+		return c, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(c.SecretHash)) != 1 {
+		return nil, invalid
+	}
+	return c, nil
+}
+
+func (s *Service) redeemCode(ctx context.Context, c *Client, r TokenRequest) (*TokenResponse, error) {
+	if !c.allows(GrantAuthorizationCode) {
+		return nil, oauthError("unauthorized_client", "")
+	}
+	invalid := oauthError("invalid_grant", "authorization code is invalid, expired or already used")
+	d := db.MustGet().WithContext(ctx)
+	var ac AuthCode
+	if err := d.Where("code_hash = ?", hashToken(r.Code)).Take(&ac).Error; err != nil {
+		return nil, invalid
+	}
+	// Single use, whatever the outcome.
+	if res := d.Delete(&ac); res.Error != nil || res.RowsAffected == 0 {
+		return nil, invalid
+	}
+	if ac.ClientID != c.ClientID || time.Now().After(ac.ExpiresAt) || (ac.RedirectURISent && ac.RedirectURI != r.RedirectURI) {
+		return nil, invalid
+	}
+	sum := sha256.Sum256([]byte(r.CodeVerifier))
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(ac.CodeChallenge)) != 1 {
+		return nil, oauthError("invalid_grant", "PKCE verification failed")
+	}
+	// The user may have been deleted since approving.
+	if _, err := s.profiles.GetProfile(ctx, ac.UserID); err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	now := time.Now()
+	at, err := s.accessToken(strconv.FormatUint(uint64(ac.UserID), 10), c.ClientID, ac.Scope, now)
+	if err != nil {
+		return nil, err
+	}
+	out := &TokenResponse{AccessToken: at, TokenType: "Bearer", ExpiresIn: int64(s.AccessTTL.Seconds()), Scope: ac.Scope}
+	if contains(strings.Fields(ac.Scope), "openid") {
+		if out.IDToken, err = s.idToken(ac.UserID, c.ClientID, ac.Nonce, now); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// clientCredentials issues a token for the client itself. User scopes are
+// never granted this way; without a scope parameter the client gets all of
+// its other scopes.
+func (s *Service) clientCredentials(c *Client, r TokenRequest) (*TokenResponse, error) {
+	if c.Public || !c.allows(GrantClientCredentials) {
+		return nil, oauthError("unauthorized_client", "")
+	}
+	var scopes []string
+	if r.Scope == "" {
+		for _, sc := range c.Scopes {
+			if _, user := UserScopes[sc]; !user {
+				scopes = append(scopes, sc)
+			}
+		}
+	} else {
+		scopes = strings.Fields(r.Scope)
+		for _, sc := range scopes {
+			if _, user := UserScopes[sc]; user || !contains(c.Scopes, sc) {
+				return nil, oauthError("invalid_scope", "scope not allowed: "+sc)
+			}
+		}
+	}
+	scope := strings.Join(scopes, " ")
+	at, err := s.accessToken(c.ClientID, c.ClientID, scope, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{AccessToken: at, TokenType: "Bearer", ExpiresIn: int64(s.AccessTTL.Seconds()), Scope: scope}, nil
+}
+
+// UserInfo endpoint
+
+// UserInfo returns the standard claims the access token's scopes allow.
+func (s *Service) UserInfo(ctx context.Context, token string) (map[string]any, error) {
+	ctx, span := tracer.Start(ctx, "oauth.Service.UserInfo")
+	defer span.End()
+	claims, err := s.ParseAccessToken(token)
+	if err != nil {
+		return nil, err
+	}
+	uid, ok := claims.userID()
+	scopes := strings.Fields(claims.Scope)
+	if !ok || !contains(scopes, "openid") {
+		return nil, ErrInvalidToken
+	}
+	p, err := s.profiles.GetProfile(ctx, uid)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{"sub": claims.Subject}
+	set := func(k string, v *string) {
+		if v != nil && *v != "" {
+			out[k] = *v
+		}
+	}
+	if contains(scopes, "profile") {
+		set("given_name", p.FirstName)
+		set("family_name", p.LastName)
+		if name := strings.TrimSpace(deref(p.FirstName) + " " + deref(p.LastName)); name != "" {
+			out["name"] = name
+		}
+		if u, ok := p.AvatarURLs["medium"]; ok {
+			out["picture"] = u
+		}
+		set("birthdate", p.DateOfBirth)
+		if p.Gender != nil && *p.Gender != "prefer_not_to_say" {
+			out["gender"] = *p.Gender
+		}
+		if p.Language != "" {
+			out["locale"] = p.Language
+		}
+		out["membership_level"] = p.MembershipLevel
+		set("membership_code", p.MembershipCode)
+	}
+	if contains(scopes, "email") {
+		out["email"] = p.Email
+	}
+	if contains(scopes, "phone") {
+		set("phone_number", p.Phone)
+	}
+	if contains(scopes, "address") && p.Address != nil {
+		a := p.Address
+		addr := map[string]string{"country": "TH"}
+		lines := []string{}
+		for _, v := range []*string{a.Line, a.Subdistrict} {
+			if v != nil && *v != "" {
+				lines = append(lines, *v)
+			}
+		}
+		addr["street_address"] = strings.Join(lines, "\n")
+		addr["locality"] = deref(a.District)
+		addr["region"] = deref(a.Province)
+		addr["postal_code"] = deref(a.Postcode)
+		out["address"] = addr
+	}
+	return out, nil
+}
+
+// validRedirectURI accepts absolute https URLs without a fragment; plain
+// http only for loopback development.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" || len(raw) > 500 {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		h := u.Hostname()
+		return h == "localhost" || h == "127.0.0.1" || h == "::1"
+	}
+	return false
+}
+
+func withQuery(endpoint string, q url.Values) string {
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + q.Encode()
+}
+
+func contains(list []string, v string) bool { return slices.Contains(list, v) }
+
+// covers reports whether granted includes every requested scope.
+func covers(granted, requested []string) bool {
+	for _, sc := range requested {
+		if !contains(granted, sc) {
+			return false
+		}
+	}
+	return true
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashToken(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/auth"
+	"workshop-be/internal/db"
+	"workshop-be/internal/oauth"
+)
+
+// TestRedeemCodeRedirectURI checks the token endpoint's redirect_uri rule
+// from RFC 6749 section 4.1.3: it must repeat the authorization request's
+// redirect_uri when that had one, and may be left out when it did not.
+func TestRedeemCodeRedirectURI(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test secret")
+	ctx := context.Background()
+	db.Init(filepath.Join(t.TempDir(), "app.db"), &auth.User{}, &audit.Event{}, &oauth.Client{}, &oauth.AuthCode{}, &oauth.Grant{})
+	user := auth.User{Email: "member@example.com", PasswordHash: "x"}
+	if err := db.MustGet().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	key, err := oauth.LoadKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := oauth.NewService("http://localhost", key, auth.NewService())
+	const cb = "https://app.example/callback"
+	client, err := s.CreateClient(ctx, 1, oauth.ClientInput{Name: "App", RedirectURIs: []string{cb}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const verifier = "a verifier long enough for the PKCE rules of this test"
+	sum := sha256.Sum256([]byte(verifier))
+	code := func(redirect string) string {
+		t.Helper()
+		out, err := s.Decide(ctx, user.ID, oauth.DecisionInput{Approve: true, AuthorizeRequest: oauth.AuthorizeRequest{
+			ResponseType: "code", ClientID: client.ClientID, RedirectURI: redirect, Scope: "openid",
+			CodeChallenge: base64.RawURLEncoding.EncodeToString(sum[:]), CodeChallengeMethod: "S256",
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(out.RedirectTo)
+		if err != nil || u.Query().Get("code") == "" {
+			t.Fatalf("no code in %q", out.RedirectTo)
+		}
+		return u.Query().Get("code")
+	}
+
+	tests := []struct {
+		name      string
+		authorize string
+		token     string
+		ok        bool
+	}{
+		{name: "sent and repeated", authorize: cb, token: cb, ok: true},
+		{name: "sent, not repeated", authorize: cb, token: ""},
+		{name: "sent, different", authorize: cb, token: "https://app.example/other"},
+		{name: "not sent, not repeated", authorize: "", token: "", ok: true},
+		{name: "not sent, repeated", authorize: "", token: cb, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Token(ctx, oauth.TokenRequest{
+				GrantType: oauth.GrantAuthorizationCode, Code: code(tt.authorize), RedirectURI: tt.token,
+				CodeVerifier: verifier, ClientID: client.ClientID, ClientSecret: client.ClientSecret,
+			})
+			if (err == nil) != tt.ok {
+				t.Fatalf("Token: err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// accessTokenType is the JWT typ of access tokens (RFC 9068); ID tokens
+// carry plain "JWT" so one can't be presented as the other.
+const accessTokenType = "at+jwt"
+
+// LoadKey reads an RSA private key (PKCS#1 or PKCS#8 PEM) from path. With an
+// empty path a new key is generated, so tokens stop verifying after a restart.
+func LoadKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block in signing key file")
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rk, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is %T, want RSA", k)
+	}
+	return rk, nil
+}
+
+// keyID derives a stable kid from the public key.
+func keyID(pub *rsa.PublicKey) string {
+	sum := sha256.Sum256(pub.N.Bytes())
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+// AccessClaims are the claims of an access token. Sub is the user id, or the
+// client id for client_credentials tokens.
+type AccessClaims struct {
+	Scope    string `json:"scope"`
+	ClientID string `json:"client_id"`
+	jwt.RegisteredClaims
+}
+
+// userID returns the user the token was issued for; false for machine tokens.
+func (c *AccessClaims) userID() (uint, bool) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil || c.Subject == c.ClientID {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+type idClaims struct {
+	Nonce string `json:"nonce,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (s *Service) sign(typ string, claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = s.kid
+	t.Header["typ"] = typ
+	return t.SignedString(s.key)
+}
+
+func (s *Service) accessToken(subject, clientID, scope string, now time.Time) (string, error) {
+	return s.sign(accessTokenType, AccessClaims{
+		Scope:    scope,
+		ClientID: clientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.Issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.AccessTTL)),
+			ID:        randomToken(16),
+		},
+	})
+}
+
+func (s *Service) idToken(userID uint, clientID, nonce string, now time.Time) (string, error) {
+	return s.sign("JWT", idClaims{
+		Nonce: nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.Issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.AccessTTL)),
+		},
+	})
+}
+
+// ParseAccessToken verifies an access token issued by this provider.
+func (s *Service) ParseAccessToken(raw string) (*AccessClaims, error) {
+	var c AccessClaims
+	t, err := jwt.ParseWithClaims(raw, &c, func(t *jwt.Token) (any, error) {
+		if typ, _ := t.Header["typ"].(string); typ != accessTokenType {
+			return nil, errors.New("not an access token")
+		}
+		return &s.key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(s.Issuer), jwt.WithExpirationRequired())
+	if err != nil || !t.Valid {
+		return nil, ErrInvalidToken
+	}
+	return &c, nil
+}
+
+// JWKS is the public key set clients use to verify tokens.
+func (s *Service) JWKS() map[string]any {
+	pub := s.key.PublicKey
+	return map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": s.kid,
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}}
+}
@@ -0,0 +1,269 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+)
+
+var (
+	ErrInvalidCampaign  = errors.New("invalid campaign")
+	ErrCampaignNotFound = errors.New("campaign not found")
+)
+
+var triggers = map[string]bool{
+	TriggerRegistered: true, TriggerProfileCompleted: true, TriggerBirthdayMonth: true, TriggerTierUpgraded: true,
+}
+
+// Engine evaluates campaigns against domain events and awards points.
+type Engine struct {
+	points *Service
+	audit  *audit.Service
+	// locks serialises evaluation per user so concurrent events cannot
+	// both pass a per-user cap check. Users share a fixed set of stripes, so
+	// memory stays constant however many members trigger events.
+	locks [lockStripes]sync.Mutex
+	now   func() time.Time
+}
+
+const lockStripes = 64
+
+func NewEngine(points *Service) *Engine {
+	return &Engine{points: points, audit: audit.NewService(), now: time.Now}
+}
+
+// Handle is an events.Handler; subscribe it to the bus.
+func (e *Engine) Handle(ctx context.Context, ev events.Event) {
+	var trigger string
+	switch ev.Type {
+	case events.UserRegistered, events.ProfileCompleted, events.TierUpgraded:
+		trigger = ev.Type
+	case events.UserLoggedIn:
+		trigger = TriggerBirthdayMonth
+	default:
+		return
+	}
+	ctx, span := tracer.Start(ctx, "points.Engine.Handle")
+	defer span.End()
+	// Tier upgrades caused by these awards are published after the lock is
+	// released; their own campaigns are evaluated by a nested Handle.
+	for _, up := range e.evaluate(ctx, ev, trigger) {
+		e.points.bus.Publish(ctx, up)
+	}
+}
+
+func (e *Engine) evaluate(ctx context.Context, ev events.Event, trigger string) []events.Event {
+	mu := &e.locks[ev.UserID%lockStripes]
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := e.now()
+	var campaigns []Campaign
+	err := db.MustGet().WithContext(ctx).
+		Where("active = ? AND `trigger` = ?", true, trigger).
+		Where("starts_at IS NULL OR starts_at <= ?", now).
+		Where("ends_at IS NULL OR ends_at > ?", now).
+		Order("id ASC").Find(&campaigns).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "load campaigns failed", "trigger", trigger, "err", err)
+		return nil
+	}
+	if len(campaigns) == 0 {
+		return nil
+	}
+	m, err := loadMember(db.MustGet().WithContext(ctx), ev.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "load member for campaigns failed", "user_id", ev.UserID, "err", err)
+		return nil
+	}
+	if trigger == TriggerBirthdayMonth && !birthdayMonth(m.DateOfBirth, now) {
+		return nil
+	}
+	var upgrades []events.Event
+	for i := range campaigns {
+		c := &campaigns[i]
+		if c.TierTo != nil && ev.Data["to"] != *c.TierTo {
+			continue
+		}
+		if ok, err := e.underCap(ctx, c, ev.UserID, now); err != nil || !ok {
+			if err != nil {
+				slog.ErrorContext(ctx, "campaign cap check failed", "campaign_id", c.ID, "err", err)
+			}
+			continue
+		}
+		amount := award(c, m.MembershipLevel)
+		if amount <= 0 {
+			continue
+		}
+		id := c.ID
+		_, up, err := e.points.apply(ctx, Entry{UserID: ev.UserID, Delta: amount, Kind: KindCampaign, CampaignID: &id, Reference: c.Name})
+		if err != nil {
+			slog.ErrorContext(ctx, "campaign award failed", "campaign_id", c.ID, "user_id", ev.UserID, "err", err)
+			continue
+		}
+		slog.InfoContext(ctx, "campaign points awarded", "campaign_id", c.ID, "user_id", ev.UserID, "points", amount)
+		if up != nil {
+			upgrades = append(upgrades, *up)
+			// Later campaigns in this batch see the new tier's multiplier.
+			m.MembershipLevel = up.Data["to"].(string)
+		}
+	}
+	return upgrades
+}
+
+func (e *Engine) underCap(ctx context.Context, c *Campaign, userID uint, now time.Time) (bool, error) {
+	if c.MaxPerUser <= 0 {
+		return true, nil
+	}
+	var n int64
+	q := db.MustGet().WithContext(ctx).Model(&Transaction{}).Where("user_id = ? AND campaign_id = ?", userID, c.ID)
+	if start := periodStart(c.CapPeriod, now); !start.IsZero() {
+		q = q.Where("created_at >= ?", start)
+	}
+	if err := q.Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n < int64(c.MaxPerUser), nil
+}
+
+// award is the campaign's points scaled by the member's tier multiplier.
+func award(c *Campaign, tier string) int {
+	mult, ok := c.TierMultipliers[tier]
+	if !ok {
+		mult = 1
+	}
+	return int(math.Round(float64(c.Points) * mult))
+}
+
+// birthdayMonth reports whether now (Thai time) falls in the month of dob.
+func birthdayMonth(dob *string, now time.Time) bool {
+	if dob == nil {
+		return false
+	}
+	t, err := time.Parse(time.DateOnly, *dob)
+	return err == nil && t.Month() == now.In(bangkok).Month()
+}
+
+type CampaignInput struct {
+	Name            string             `json:"name" example:"Birthday bonus"`
+	Trigger         string             `json:"trigger" example:"birthday_month"`
+	Points          int                `json:"points" example:"500"`
+	StartsAt        *time.Time         `json:"starts_at"`
+	EndsAt          *time.Time         `json:"ends_at"`
+	MaxPerUser      int                `json:"max_per_user" example:"1"`
+	CapPeriod       string             `json:"cap_period" example:"year"`
+	TierMultipliers map[string]float64 `json:"tier_multipliers"`
+	TierTo          *string            `json:"tier_to"`
+	// Active defaults to true.
+	Active *bool `json:"active"`
+}
+
+func (in CampaignInput) validate() error {
+	name := strings.TrimSpace(in.Name)
+	if name == "" || len(name) > 100 || !triggers[in.Trigger] || in.Points <= 0 || in.MaxPerUser < 0 {
+		return ErrInvalidCampaign
+	}
+	if in.CapPeriod != "" && in.CapPeriod != CapLifetime && in.CapPeriod != CapYear && in.CapPeriod != CapMonth {
+		return ErrInvalidCampaign
+	}
+	if in.StartsAt != nil && in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+		return ErrInvalidCampaign
+	}
+	for tier, m := range in.TierMultipliers {
+		if tierRank(tier) < 0 || m <= 0 || m > 10 {
+			return ErrInvalidCampaign
+		}
+	}
+	if in.TierTo != nil && (in.Trigger != TriggerTierUpgraded || tierRank(*in.TierTo) < 0) {
+		return ErrInvalidCampaign
+	}
+	return nil
+}
+
+func (in CampaignInput) apply(c *Campaign) {
+	c.Name = strings.TrimSpace(in.Name)
+	c.Trigger = in.Trigger
+	c.Points = in.Points
+	c.StartsAt = in.StartsAt
+	c.EndsAt = in.EndsAt
+	c.MaxPerUser = in.MaxPerUser
+	c.CapPeriod = in.CapPeriod
+	if c.CapPeriod == "" {
+		c.CapPeriod = CapLifetime
+	}
+	c.TierMultipliers = in.TierMultipliers
+	c.TierTo = in.TierTo
+	c.Active = in.Active == nil || *in.Active
+}
+
+// CreateCampaign adds a campaign; it applies to events from now on.
+func (e *Engine) CreateCampaign(ctx context.Context, actorID uint, in CampaignInput) (*Campaign, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	var c Campaign
+	in.apply(&c)
+	if err := db.MustGet().WithContext(ctx).Create(&c).Error; err != nil {
+		return nil, err
+	}
+	e.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, ActorID: &actorID, Details: map[string]any{"action": "campaign.create", "campaign_id": c.ID, "campaign": c}})
+	return &c, nil
+}
+
+// UpdateCampaign replaces a campaign's rule. Awards already made stay.
+func (e *Engine) UpdateCampaign(ctx context.Context, actorID, id uint, in CampaignInput) (*Campaign, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	d := db.MustGet().WithContext(ctx)
+	var c Campaign
+	if err := d.First(&c, id).Error; err != nil {
+		return nil, ErrCampaignNotFound
+	}
+	before := c
+	in.apply(&c)
+	if err := d.Save(&c).Error; err != nil {
+		return nil, err
+	}
+	e.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, ActorID: &actorID, Details: map[string]any{"action": "campaign.update", "campaign_id": c.ID, "from": before, "to": c}})
+	return &c, nil
+}
+
+type CampaignList struct {
+	Campaigns []Campaign `json:"campaigns"`
+}
+
+// Campaigns lists all campaigns, newest first.
+func (e *Engine) Campaigns(ctx context.Context) (*CampaignList, error) {
+	var rows []Campaign
+	if err := db.MustGet().WithContext(ctx).Order("id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return &CampaignList{Campaigns: rows}, nil
+}
+
+// SeedDefaults creates the standard campaigns when none exist yet, so a fresh
+// install has a welcome, profile completion, birthday and tier upgrade bonus.
+func (e *Engine) SeedDefaults(ctx context.Context) error {
+	d := db.MustGet().WithContext(ctx)
+	var n int64
+	if err := d.Model(&Campaign{}).Count(&n).Error; err != nil || n > 0 {
+		return err
+	}
+	defaults := []Campaign{
+		{Name: "Welcome bonus", Trigger: TriggerRegistered, Points: 100, MaxPerUser: 1, CapPeriod: CapLifetime, Active: true},
+		{Name: "Profile completion", Trigger: TriggerProfileCompleted, Points: 200, MaxPerUser: 1, CapPeriod: CapLifetime, Active: true},
+		{Name: "Birthday bonus", Trigger: TriggerBirthdayMonth, Points: 500, MaxPerUser: 1, CapPeriod: CapYear, Active: true,
+			TierMultipliers: map[string]float64{"Silver": 1.5, "Gold": 2, "Platinum": 3}},
+		{Name: "Tier upgrade bonus", Trigger: TriggerTierUpgraded, Points: 300, Active: true},
+	}
+	return d.Create(&defaults).Error
+}
@@ -0,0 +1,179 @@
+package points
+
+import (
+	"net/http"
+
+	"workshop-be/internal/httpx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	svc    *Service
+	engine *Engine
+}
+
+func NewHandler(svc *Service, engine *Engine) *Handler {
+	return &Handler{svc: svc, engine: engine}
+}
+
+// GetBalance godoc
+// @Summary Get my points balance
+// @Description Current balance, membership tier and progress towards the next tier. Tiers follow lifetime earned points and never go down.
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} BalanceOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/points [get]
+func (h *Handler) GetBalance(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.Balance(c.UserContext(), uid)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return httpx.WriteError(c, http.StatusNotFound, "USER_NOT_FOUND", "user not found")
+		}
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// GetTransactions godoc
+// @Summary List my points transactions
+// @Description Ledger entries newest first. Pass next_before_id from the previous page as before_id to page back.
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Param before_id query int false "return entries older than this id"
+// @Param limit query int false "page size (default 50, max 200)"
+// @Success 200 {object} HistoryOutput
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/points/transactions [get]
+func (h *Handler) GetTransactions(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	before := c.QueryInt("before_id")
+	if before < 0 {
+		before = 0
+	}
+	out, err := h.svc.History(c.UserContext(), uid, uint(before), c.QueryInt("limit"))
+	if err != nil {
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// ListCampaigns godoc
+// @Summary List points campaigns (admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} CampaignList
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/campaigns [get]
+func (h *Handler) ListCampaigns(c *fiber.Ctx) error {
+	out, err := h.engine.Campaigns(c.UserContext())
+	if err != nil {
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// CreateCampaign godoc
+// @Summary Create points campaign (admin)
+// @Description Triggers: user.registered, profile.completed, birthday_month (first login in the birth month), tier.upgraded (optionally only into tier_to). cap_period is lifetime, year or month; max_per_user 0 is unlimited.
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body CampaignInput true "campaign"
+// @Success 201 {object} Campaign
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/campaigns [post]
+func (h *Handler) CreateCampaign(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in CampaignInput
+	if err := c.BodyParser(&in); err != nil {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.engine.CreateCampaign(c.UserContext(), uid, in)
+	if err != nil {
+		return h.campaignError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(out)
+}
+
+// UpdateCampaign godoc
+// @Summary Update points campaign (admin)
+// @Description Replaces the campaign rule. Set active to false to stop it; points already awarded are kept.
+// @Tags Admin
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "campaign id"
+// @Param request body CampaignInput true "campaign"
+// @Success 200 {object} Campaign
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Router /api/v1/admin/campaigns/{id} [put]
+func (h *Handler) UpdateCampaign(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return httpx.WriteError(c, http.StatusNotFound, "CAMPAIGN_NOT_FOUND", "campaign not found")
+	}
+	var in CampaignInput
+	if err := c.BodyParser(&in); err != nil {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.engine.UpdateCampaign(c.UserContext(), uid, uint(id), in)
+	if err != nil {
+		return h.campaignError(c, err)
+	}
+	return c.JSON(out)
+}
+
+func (h *Handler) campaignError(c *fiber.Ctx, err error) error {
+	switch err {
+	case ErrInvalidCampaign:
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_CAMPAIGN", "name, a known trigger and positive points are required; check cap_period, window, tier_multipliers and tier_to")
+	case ErrCampaignNotFound:
+		return httpx.WriteError(c, http.StatusNotFound, "CAMPAIGN_NOT_FOUND", "campaign not found")
+	default:
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+}
+
+// RegisterProfileRoutes mounts the member endpoints on the profile group.
+func RegisterProfileRoutes(r fiber.Router, svc *Service) {
+	h := NewHandler(svc, nil)
+	r.Get("/points", h.GetBalance)
+	r.Get("/points/transactions", h.GetTransactions)
+}
+
+// RegisterAdminRoutes mounts campaign management on the admin group.
+func RegisterAdminRoutes(r fiber.Router, engine *Engine) {
+	h := NewHandler(engine.points, engine)
+	r.Get("/campaigns", h.ListCampaigns)
+	r.Post("/campaigns", h.CreateCampaign)
+	r.Put("/campaigns/:id", h.UpdateCampaign)
+}
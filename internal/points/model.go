@@ -0,0 +1,67 @@
+package points
+
+import "time"
+
+// Transaction kinds.
+const (
+	KindCampaign   = "campaign"
+	KindReferral   = "referral"
+	KindPurchase   = "purchase"
+	KindAdjustment = "adjustment"
+)
+
+// Transaction is one ledger entry. users.points is the running balance; the
+// ledger explains it. Rows are never edited: corrections are new entries.
+type Transaction struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	UserID uint `json:"-" gorm:"index;not null"`
+	Delta  int  `json:"delta"`
+	// Balance is users.points right after this entry.
+	Balance    int       `json:"balance"`
+	Kind       string    `json:"kind" gorm:"size:20;not null"`
+	CampaignID *uint     `json:"campaign_id,omitempty" gorm:"index"`
+	Reference  string    `json:"reference" gorm:"size:100"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+func (Transaction) TableName() string { return "point_transactions" }
+
+// Campaign triggers. Event-type triggers fire on that event; TriggerBirthdayMonth
+// fires on login during the member's birth month.
+const (
+	TriggerRegistered       = "user.registered"
+	TriggerProfileCompleted = "profile.completed"
+	TriggerBirthdayMonth    = "birthday_month"
+	TriggerTierUpgraded     = "tier.upgraded"
+)
+
+// Cap periods for MaxPerUser.
+const (
+	CapLifetime = "lifetime"
+	CapYear     = "year"
+	CapMonth    = "month"
+)
+
+// Campaign is a points rule: when Trigger happens inside the window, award
+// Points times the member's tier multiplier, at most MaxPerUser times per CapPeriod.
+type Campaign struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	Name    string `json:"name" gorm:"size:100;not null"`
+	Trigger string `json:"trigger" gorm:"size:30;index;not null"`
+	Points  int    `json:"points"`
+	// StartsAt/EndsAt bound the campaign window; nil means open-ended.
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	// MaxPerUser limits awards per member per CapPeriod; 0 means unlimited.
+	MaxPerUser int    `json:"max_per_user"`
+	CapPeriod  string `json:"cap_period" gorm:"size:10;not null;default:lifetime"`
+	// TierMultipliers scales Points by membership level, e.g. {"Gold": 2}. Missing tiers use 1.
+	TierMultipliers map[string]float64 `json:"tier_multipliers" gorm:"serializer:json"`
+	// TierTo restricts tier.upgraded campaigns to upgrades into this tier.
+	TierTo    *string   `json:"tier_to" gorm:"size:20"`
+	Active    bool      `json:"active" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Campaign) TableName() string { return "point_campaigns" }
@@ -0,0 +1,246 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+)
+
+var tracer = otel.Tracer("workshop-be/internal/points")
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInsufficientPoints = errors.New("insufficient points")
+)
+
+// Tiers in ascending order with the lifetime points needed to reach them.
+// Tiers only go up: spending points never downgrades a member.
+var Tiers = []struct {
+	Name string
+	Min  int
+}{
+	{"Bronze", 0},
+	{"Silver", 1000},
+	{"Gold", 5000},
+	{"Platinum", 20000},
+}
+
+// TierFor returns the tier reached with lifetime earned points.
+func TierFor(lifetime int) string {
+	tier := Tiers[0].Name
+	for _, t := range Tiers {
+		if lifetime >= t.Min {
+			tier = t.Name
+		}
+	}
+	return tier
+}
+
+func tierRank(name string) int {
+	for i, t := range Tiers {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+type Service struct {
+	bus *events.Bus
+}
+
+// NewService publishes tier upgrades on bus.
+func NewService(bus *events.Bus) *Service {
+	return &Service{bus: bus}
+}
+
+// Entry describes a balance change.
+type Entry struct {
+	UserID     uint
+	Delta      int
+	Kind       string
+	CampaignID *uint
+	Reference  string
+}
+
+// member is the slice of the users row the ledger maintains.
+type member struct {
+	ID              uint
+	Points          int
+	MembershipLevel string
+	DateOfBirth     *string
+}
+
+func loadMember(tx *gorm.DB, userID uint) (*member, error) {
+	var m member
+	err := tx.Table("users").Select("id, points, membership_level, date_of_birth").
+		Where("id = ? AND deleted_at IS NULL", userID).Take(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return &m, err
+}
+
+// Apply records e in the ledger and updates the member's balance in one
+// transaction, then upgrades the tier when lifetime earnings cross a
+// threshold (publishing events.TierUpgraded). A debit that would make the
+// balance negative fails with ErrInsufficientPoints.
+func (s *Service) Apply(ctx context.Context, e Entry) (*Transaction, error) {
+	ctx, span := tracer.Start(ctx, "points.Service.Apply")
+	defer span.End()
+	t, upgrade, err := s.apply(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	if upgrade != nil {
+		s.bus.Publish(ctx, *upgrade)
+	}
+	return t, nil
+}
+
+// apply does the work of Apply but returns the tier upgrade event instead of
+// publishing it, for callers that must publish outside a lock.
+func (s *Service) apply(ctx context.Context, e Entry) (*Transaction, *events.Event, error) {
+	var (
+		t        Transaction
+		from, to string
+	)
+	err := db.MustGet().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Adjust the stored balance rather than writing one computed from an
+		// earlier read, so concurrent changes add up. Writing first also
+		// takes SQLite's write lock up front instead of upgrading a read lock.
+		res := tx.Table("users").
+			Where("id = ? AND deleted_at IS NULL AND points + ? >= 0", e.UserID, e.Delta).
+			Update("points", gorm.Expr("points + ?", e.Delta))
+		if res.Error != nil {
+			return res.Error
+		}
+		m, err := loadMember(tx, e.UserID)
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected == 0 {
+			return ErrInsufficientPoints
+		}
+		t = Transaction{UserID: e.UserID, Delta: e.Delta, Balance: m.Points, Kind: e.Kind, CampaignID: e.CampaignID, Reference: e.Reference}
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+		updates := map[string]any{}
+		if e.Delta > 0 {
+			var lifetime int
+			if err := tx.Model(&Transaction{}).Where("user_id = ? AND delta > 0", e.UserID).
+				Select("COALESCE(SUM(delta), 0)").Scan(&lifetime).Error; err != nil {
+				return err
+			}
+			if next := TierFor(lifetime); tierRank(next) > tierRank(m.MembershipLevel) {
+				updates["membership_level"] = next
+				from, to = m.MembershipLevel, next
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Table("users").Where("id = ?", e.UserID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if to == "" {
+		return &t, nil, nil
+	}
+	return &t, &events.Event{Type: events.TierUpgraded, UserID: e.UserID, Data: map[string]any{"from": from, "to": to}}, nil
+}
+
+type BalanceOutput struct {
+	Points           int    `json:"points"`
+	Tier             string `json:"tier"`
+	LifetimePoints   int    `json:"lifetime_points"`
+	NextTier         string `json:"next_tier,omitempty"`
+	PointsToNextTier int    `json:"points_to_next_tier,omitempty"`
+}
+
+// Balance returns the member's balance and tier progress.
+func (s *Service) Balance(ctx context.Context, userID uint) (*BalanceOutput, error) {
+	d := db.MustGet().WithContext(ctx)
+	m, err := loadMember(d, userID)
+	if err != nil {
+		return nil, err
+	}
+	var lifetime int
+	if err := d.Model(&Transaction{}).Where("user_id = ? AND delta > 0", userID).
+		Select("COALESCE(SUM(delta), 0)").Scan(&lifetime).Error; err != nil {
+		return nil, err
+	}
+	out := &BalanceOutput{Points: m.Points, Tier: m.MembershipLevel, LifetimePoints: lifetime}
+	if r := tierRank(m.MembershipLevel); r >= 0 && r+1 < len(Tiers) {
+		next := Tiers[r+1]
+		out.NextTier = next.Name
+		out.PointsToNextTier = max(next.Min-lifetime, 0)
+	}
+	return out, nil
+}
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+type HistoryOutput struct {
+	Transactions []Transaction `json:"transactions"`
+	// NextBeforeID is the cursor for the next (older) page; 0 when there is none.
+	NextBeforeID uint `json:"next_before_id"`
+}
+
+// History returns ledger entries newest first, paginated by id.
+func (s *Service) History(ctx context.Context, userID, beforeID uint, limit int) (*HistoryOutput, error) {
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	q := db.MustGet().WithContext(ctx).Where("user_id = ?", userID)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var rows []Transaction
+	if err := q.Order("id DESC").Limit(limit + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := &HistoryOutput{Transactions: rows}
+	if len(rows) > limit {
+		out.Transactions = rows[:limit]
+		out.NextBeforeID = rows[limit-1].ID
+	}
+	return out, nil
+}
+
+// All returns every ledger entry of the user, oldest first, for data exports.
+func (s *Service) All(ctx context.Context, userID uint) ([]Transaction, error) {
+	var rows []Transaction
+	err := db.MustGet().WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&rows).Error
+	return rows, err
+}
+
+// periodStart is the beginning of the cap period containing now, in Thai time.
+func periodStart(period string, now time.Time) time.Time {
+	now = now.In(bangkok)
+	switch period {
+	case CapYear:
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, bangkok)
+	case CapMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, bangkok)
+	default:
+		return time.Time{}
+	}
+}
+
+// bangkok is fixed at UTC+7; Thailand has no daylight saving time.
+var bangkok = time.FixedZone("ICT", 7*60*60)
@@ -0,0 +1,73 @@
+package points_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"workshop-be/internal/auth"
+	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+	"workshop-be/internal/points"
+)
+
+// TestApplyConcurrentDebits spends more than the balance from many goroutines
+// at once and checks that exactly the affordable debits pass and the balance
+// never goes below zero.
+func TestApplyConcurrentDebits(t *testing.T) {
+	ctx := context.Background()
+	db.Init(filepath.Join(t.TempDir(), "app.db"), &auth.User{}, &points.Transaction{})
+	user := auth.User{Email: "member@example.com", PasswordHash: "x", Points: 100}
+	if err := db.MustGet().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	s := points.NewService(events.NewBus())
+
+	const debits = 25
+	errs := make(chan error, debits)
+	var wg sync.WaitGroup
+	for range debits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Apply(ctx, points.Entry{UserID: user.ID, Delta: -10, Kind: points.KindPurchase})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	passed := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			passed++
+		case points.ErrInsufficientPoints:
+		default:
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+	if passed != 10 {
+		t.Errorf("%d debits of 10 passed on a balance of 100, want 10", passed)
+	}
+
+	bal, err := s.Balance(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal.Points != 0 {
+		t.Errorf("balance = %d, want 0", bal.Points)
+	}
+	ledger, err := s.All(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range ledger {
+		if tx.Balance < 0 {
+			t.Errorf("ledger entry %d left balance %d", tx.ID, tx.Balance)
+		}
+	}
+	if len(ledger) != passed {
+		t.Errorf("ledger has %d entries for %d debits", len(ledger), passed)
+	}
+}
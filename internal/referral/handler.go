@@ -0,0 +1,43 @@
+package referral
+
+import (
+	"net/http"
+
+	"workshop-be/internal/httpx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GetReferrals godoc
+// @Summary Get my referral code and invited friends
+// @Description Friends register with referral_code. Both sides get points once the friend completes their profile.
+// @Tags Profile
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} Summary
+// @Failure 401 {object} httpx.ErrorResponse
+// @Router /api/v1/profile/referrals [get]
+func (h *Handler) GetReferrals(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	out, err := h.svc.Summary(c.UserContext(), uid)
+	if err != nil {
+		return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+	}
+	return c.JSON(out)
+}
+
+// RegisterProfileRoutes mounts the referral summary on the profile group.
+func RegisterProfileRoutes(r fiber.Router, svc *Service) {
+	r.Get("/referrals", NewHandler(svc).GetReferrals)
+}
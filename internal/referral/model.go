@@ -0,0 +1,44 @@
+package referral
+
+import "time"
+
+// Referral statuses.
+const (
+	StatusPending  = "pending"
+	StatusRewarded = "rewarded"
+	StatusRejected = "rejected"
+)
+
+// Reasons a referral is rejected at registration.
+const (
+	ReasonSameEmail       = "same_email"
+	ReasonSameEmailDomain = "same_email_domain"
+	ReasonSameDevice      = "same_device"
+	ReasonDeviceReused    = "device_reused"
+)
+
+// Referral links a new member (referee) to the member whose code they used.
+// Both are rewarded once the referee completes their profile.
+type Referral struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	ReferrerID uint   `json:"-" gorm:"index;not null"`
+	RefereeID  uint   `json:"-" gorm:"uniqueIndex;not null"`
+	Status     string `json:"status" gorm:"size:20;not null"`
+	// RejectReason is set for rejected referrals; never shown to members.
+	RejectReason string `json:"-" gorm:"size:30"`
+	// DeviceHash is the SHA-256 of the referee's device id at registration.
+	DeviceHash string     `json:"-" gorm:"size:64;index"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RewardedAt *time.Time `json:"rewarded_at"`
+}
+
+// Device is a device id (hashed) seen for a user at registration or login,
+// used to spot members referring themselves from a second account.
+type Device struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"uniqueIndex:idx_referral_device;not null"`
+	DeviceHash string `gorm:"size:64;uniqueIndex:idx_referral_device;index;not null"`
+	CreatedAt  time.Time
+}
+
+func (Device) TableName() string { return "referral_devices" }
@@ -0,0 +1,342 @@
+// Package referral gives members invite codes and rewards both sides once an
+// invited friend completes their profile.
+package referral
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"workshop-be/internal/clientinfo"
+	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+	"workshop-be/internal/points"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var tracer = otel.Tracer("workshop-be/internal/referral")
+
+var ErrInvalidCode = errors.New("invalid referral code")
+
+// Code alphabet without look-alike characters (0/O, 1/I/L).
+const (
+	codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	codeLength   = 8
+)
+
+// NewCode returns a random referral code.
+func NewCode() string {
+	b := make([]byte, codeLength)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b)
+}
+
+// normalizeCode accepts codes typed in lower case or with spaces/dashes.
+func normalizeCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+type Service struct {
+	points *points.Service
+	// ReferrerPoints and RefereePoints are awarded when the referee
+	// completes their profile.
+	ReferrerPoints int
+	RefereePoints  int
+	// AppBaseURL is the frontend origin used to build share links.
+	AppBaseURL string
+}
+
+func NewService(pts *points.Service) *Service {
+	return &Service{points: pts, ReferrerPoints: 500, RefereePoints: 200, AppBaseURL: "http://localhost:3000"}
+}
+
+// Resolve returns the id of the member owning code.
+func (s *Service) Resolve(ctx context.Context, code string) (uint, error) {
+	code = normalizeCode(code)
+	if len(code) != codeLength {
+		return 0, ErrInvalidCode
+	}
+	var u struct{ ID uint }
+	err := db.MustGet().WithContext(ctx).Table("users").Select("id").
+		Where("referral_code = ? AND deleted_at IS NULL", code).Take(&u).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrInvalidCode
+	}
+	return u.ID, err
+}
+
+// Attach records that refereeID registered with referrerID's code. It runs in
+// the registration transaction. Suspected self-referrals are stored as
+// rejected rather than failing registration, so the checks aren't revealed.
+func (s *Service) Attach(ctx context.Context, tx *gorm.DB, referrerID, refereeID uint, refereeEmail string) error {
+	r := Referral{ReferrerID: referrerID, RefereeID: refereeID, Status: StatusPending}
+	if device := clientinfo.From(ctx).DeviceID; device != "" {
+		r.DeviceHash = hashDevice(device)
+	}
+	reason, err := s.suspicious(tx, &r, refereeEmail)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		r.Status, r.RejectReason = StatusRejected, reason
+		slog.WarnContext(ctx, "referral rejected", "referrer_id", referrerID, "referee_id", refereeID, "reason", reason)
+	}
+	return tx.Create(&r).Error
+}
+
+// suspicious returns why r looks like a self-referral, or "".
+func (s *Service) suspicious(tx *gorm.DB, r *Referral, refereeEmail string) (string, error) {
+	var referrer struct{ Email string }
+	if err := tx.Table("users").Select("email").Where("id = ?", r.ReferrerID).Take(&referrer).Error; err != nil {
+		return "", err
+	}
+	referrerEmail := referrer.Email
+	if canonicalEmail(referrerEmail) == canonicalEmail(refereeEmail) {
+		return ReasonSameEmail, nil
+	}
+	if d := emailDomain(refereeEmail); d == emailDomain(referrerEmail) && !publicDomains[d] {
+		return ReasonSameEmailDomain, nil
+	}
+	if r.DeviceHash == "" {
+		return "", nil
+	}
+	var n int64
+	if err := tx.Model(&Device{}).Where("user_id = ? AND device_hash = ?", r.ReferrerID, r.DeviceHash).Count(&n).Error; err != nil {
+		return "", err
+	}
+	if n > 0 {
+		return ReasonSameDevice, nil
+	}
+	// One phone creating account after account to collect referee bonuses.
+	if err := tx.Model(&Referral{}).Where("device_hash = ?", r.DeviceHash).Count(&n).Error; err != nil {
+		return "", err
+	}
+	if n > 0 {
+		return ReasonDeviceReused, nil
+	}
+	return "", nil
+}
+
+// publicDomains are mailbox providers shared by unrelated people; matching
+// domains only count as suspicious for other (e.g. company or own) domains.
+var publicDomains = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "hotmail.com": true, "hotmail.co.th": true,
+	"outlook.com": true, "outlook.co.th": true, "live.com": true, "msn.com": true,
+	"yahoo.com": true, "yahoo.co.th": true, "icloud.com": true, "me.com": true,
+	"proton.me": true, "protonmail.com": true,
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	return strings.ToLower(email[at+1:])
+}
+
+// canonicalEmail folds aliases of one mailbox together: case, "+tag"
+// suffixes and, for Gmail, dots in the local part.
+func canonicalEmail(email string) string {
+	email = strings.ToLower(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if i := strings.IndexByte(local, '+'); i >= 0 {
+		local = local[:i]
+	}
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+func hashDevice(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// Handle is an events.Handler: it remembers members' devices and pays out
+// pending referrals when the referee completes their profile.
+func (s *Service) Handle(ctx context.Context, ev events.Event) {
+	switch ev.Type {
+	case events.UserRegistered, events.UserLoggedIn:
+		s.rememberDevice(ctx, ev.UserID)
+	case events.ProfileCompleted:
+		s.reward(ctx, ev.UserID)
+	}
+}
+
+func (s *Service) rememberDevice(ctx context.Context, userID uint) {
+	device := clientinfo.From(ctx).DeviceID
+	if device == "" {
+		return
+	}
+	err := db.MustGet().WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Device{UserID: userID, DeviceHash: hashDevice(device)}).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "record referral device failed", "user_id", userID, "err", err)
+	}
+}
+
+func (s *Service) reward(ctx context.Context, refereeID uint) {
+	ctx, span := tracer.Start(ctx, "referral.Service.reward")
+	defer span.End()
+	d := db.MustGet().WithContext(ctx)
+	var r Referral
+	if err := d.Where("referee_id = ? AND status = ?", refereeID, StatusPending).Take(&r).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "load referral failed", "referee_id", refereeID, "err", err)
+		}
+		return
+	}
+	// Claim the referral first so a repeated event can't pay twice.
+	now := time.Now()
+	res := d.Model(&Referral{}).Where("id = ? AND status = ?", r.ID, StatusPending).
+		Updates(map[string]any{"status": StatusRewarded, "rewarded_at": &now})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+	ref := fmt.Sprintf("referral:%d", r.ID)
+	for _, e := range []points.Entry{
+		{UserID: r.ReferrerID, Delta: s.ReferrerPoints, Kind: points.KindReferral, Reference: ref},
+		{UserID: r.RefereeID, Delta: s.RefereePoints, Kind: points.KindReferral, Reference: ref},
+	} {
+		if e.Delta <= 0 {
+			continue
+		}
+		if _, err := s.points.Apply(ctx, e); err != nil {
+			slog.ErrorContext(ctx, "referral reward failed", "referral_id", r.ID, "user_id", e.UserID, "err", err)
+		}
+	}
+	slog.InfoContext(ctx, "referral rewarded", "referral_id", r.ID, "referrer_id", r.ReferrerID, "referee_id", r.RefereeID)
+}
+
+// Item is a referral as shown to the referrer. Rejected referrals show as
+// pending so the fraud checks aren't revealed.
+type Item struct {
+	Status     string     `json:"status" example:"rewarded"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	RewardedAt *time.Time `json:"rewarded_at"`
+}
+
+type Summary struct {
+	Code string `json:"code" example:"K7QM3XPA"`
+	// ShareURL opens registration with the code filled in.
+	ShareURL     string `json:"share_url" example:"http://localhost:3000/register?ref=K7QM3XPA"`
+	Invited      int    `json:"invited"`
+	Rewarded     int    `json:"rewarded"`
+	PointsEarned int    `json:"points_earned"`
+	Referrals    []Item `json:"referrals"`
+}
+
+// Summary returns the member's code and the friends who used it. Members
+// registered before referrals existed get a code on first call.
+func (s *Service) Summary(ctx context.Context, userID uint) (*Summary, error) {
+	ctx, span := tracer.Start(ctx, "referral.Service.Summary")
+	defer span.End()
+	code, err := s.ensureCode(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var rows []Referral
+	if err := db.MustGet().WithContext(ctx).Where("referrer_id = ?", userID).Order("id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := &Summary{Code: code, ShareURL: s.AppBaseURL + "/register?ref=" + code, Invited: len(rows), Referrals: make([]Item, 0, len(rows))}
+	for _, r := range rows {
+		it := Item{Status: r.Status, JoinedAt: r.CreatedAt, RewardedAt: r.RewardedAt}
+		if r.Status == StatusRejected {
+			it.Status = StatusPending
+		}
+		if r.Status == StatusRewarded {
+			out.Rewarded++
+		}
+		out.Referrals = append(out.Referrals, it)
+	}
+	err = db.MustGet().WithContext(ctx).Model(&points.Transaction{}).
+		Where("user_id = ? AND kind = ? AND delta > 0", userID, points.KindReferral).
+		Where("reference IN (?)", db.MustGet().Model(&Referral{}).Select("'referral:' || id").Where("referrer_id = ?", userID)).
+		Select("COALESCE(SUM(delta), 0)").Scan(&out.PointsEarned).Error
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Forget drops the device hashes kept for a purged user, using the purge
+// transaction tx. Referrals stay so the other member's history and points
+// remain consistent.
+func (s *Service) Forget(ctx context.Context, tx *gorm.DB, userID uint) error {
+	d := tx.WithContext(ctx)
+	if err := d.Where("user_id = ?", userID).Delete(&Device{}).Error; err != nil {
+		return err
+	}
+	return d.Model(&Referral{}).Where("referee_id = ?", userID).Update("device_hash", "").Error
+}
+
+func (s *Service) ensureCode(ctx context.Context, userID uint) (string, error) {
+	d := db.MustGet().WithContext(ctx)
+	var u struct{ ReferralCode *string }
+	if err := d.Table("users").Select("referral_code").Where("id = ? AND deleted_at IS NULL", userID).Take(&u).Error; err != nil {
+		return "", err
+	}
+	if u.ReferralCode != nil {
+		return *u.ReferralCode, nil
+	}
+	for range 3 {
+		c := NewCode()
+		res := d.Table("users").Where("id = ? AND referral_code IS NULL", userID).Update("referral_code", c)
+		if errors.Is(res.Error, gorm.ErrDuplicatedKey) {
+			continue
+		}
+		if res.Error != nil {
+			return "", res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Assigned concurrently; read it back.
+			return s.ensureCode(ctx, userID)
+		}
+		return c, nil
+	}
+	return "", errors.New("could not allocate referral code")
+}
+
+// ExportItem is a referral in the member's personal data export, as referrer
+// or referee.
+type ExportItem struct {
+	Role       string     `json:"role"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RewardedAt *time.Time `json:"rewarded_at"`
+}
+
+// All returns the referrals the user took part in, for data exports.
+func (s *Service) All(ctx context.Context, userID uint) ([]ExportItem, error) {
+	var rows []Referral
+	err := db.MustGet().WithContext(ctx).Where("referrer_id = ? OR referee_id = ?", userID, userID).Order("id ASC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ExportItem, 0, len(rows))
+	for _, r := range rows {
+		it := ExportItem{Role: "referrer", Status: r.Status, CreatedAt: r.CreatedAt, RewardedAt: r.RewardedAt}
+		if r.RefereeID == userID {
+			it.Role = "referee"
+		}
+		out = append(out, it)
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package referral_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"workshop-be/internal/auth"
+	"workshop-be/internal/clientinfo"
+	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+	"workshop-be/internal/points"
+	"workshop-be/internal/referral"
+)
+
+// TestAttachSelfReferral checks that referrals which look like a member
+// inviting themselves are stored as rejected and never pay out, while an
+// unrelated friend's stays pending and is rewarded.
+func TestAttachSelfReferral(t *testing.T) {
+	ctx := context.Background()
+	db.Init(filepath.Join(t.TempDir(), "app.db"), &auth.User{}, &points.Transaction{}, &referral.Referral{}, &referral.Device{})
+	d := db.MustGet()
+	s := referral.NewService(points.NewService(events.NewBus()))
+
+	referrer := auth.User{Email: "somchai.k@gmail.com", PasswordHash: "x"}
+	colleague := auth.User{Email: "anan@workshop.example", PasswordHash: "x"}
+	for _, u := range []*auth.User{&referrer, &colleague} {
+		if err := d.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	onPhone := clientinfo.With(ctx, clientinfo.Info{DeviceID: "referrer-phone"})
+	s.Handle(onPhone, events.Event{Type: events.UserLoggedIn, UserID: referrer.ID})
+
+	tests := []struct {
+		name     string
+		referrer uint
+		email    string
+		ctx      context.Context
+		reason   string
+	}{
+		{name: "gmail alias", referrer: referrer.ID, email: "Somchai.K+2@googlemail.com", ctx: ctx, reason: referral.ReasonSameEmail},
+		{name: "same company domain", referrer: colleague.ID, email: "anan2@workshop.example", ctx: ctx, reason: referral.ReasonSameEmailDomain},
+		{name: "referrer's device", referrer: referrer.ID, email: "friend1@example.com", ctx: onPhone, reason: referral.ReasonSameDevice},
+		{name: "friend", referrer: referrer.ID, email: "friend2@example.com", ctx: clientinfo.With(ctx, clientinfo.Info{DeviceID: "friend-phone"})},
+		{name: "device reused", referrer: colleague.ID, email: "friend3@example.com", ctx: clientinfo.With(ctx, clientinfo.Info{DeviceID: "friend-phone"}), reason: referral.ReasonDeviceReused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			referee := auth.User{Email: tt.email, PasswordHash: "x"}
+			if err := d.Create(&referee).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Attach(tt.ctx, d, tt.referrer, referee.ID, referee.Email); err != nil {
+				t.Fatal(err)
+			}
+			s.Handle(ctx, events.Event{Type: events.ProfileCompleted, UserID: referee.ID})
+
+			var r referral.Referral
+			if err := d.Where("referee_id = ?", referee.ID).Take(&r).Error; err != nil {
+				t.Fatal(err)
+			}
+			want := referral.StatusRejected
+			if tt.reason == "" {
+				want = referral.StatusRewarded
+			}
+			if r.Status != want || r.RejectReason != tt.reason {
+				t.Errorf("referral = %s (%q), want %s (%q)", r.Status, r.RejectReason, want, tt.reason)
+			}
+			var paid int64
+			if err := d.Model(&points.Transaction{}).Where("user_id = ? AND kind = ?", referee.ID, points.KindReferral).Count(&paid).Error; err != nil {
+				t.Fatal(err)
+			}
+			if (paid > 0) != (tt.reason == "") {
+				t.Errorf("referee got %d referral rewards", paid)
+			}
+		})
+	}
+}
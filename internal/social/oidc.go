@@ -0,0 +1,302 @@
+package social
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCConfig configures an OpenID Connect provider.
+type OIDCConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes default to openid, email and profile.
+	Scopes []string
+	// TrustEmail treats the email claim as verified when the provider does
+	// not send email_verified (LINE only releases emails it has verified).
+	TrustEmail bool
+	// AllowHS256 accepts ID tokens signed with ClientSecret, as LINE signs
+	// them with the channel secret. Ignored when ClientSecret is empty.
+	AllowHS256 bool
+}
+
+// OIDC is a provider discovered from {Issuer}/.well-known/openid-configuration.
+// ID tokens are verified against the provider's JWKS (RS256/ES256) or, only
+// with AllowHS256 (LINE), HS256 keyed by the client secret.
+type OIDC struct {
+	cfg OIDCConfig
+
+	mu     sync.Mutex
+	meta   *discovery
+	keys   map[string]any
+	keysAt time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewOIDC(cfg OIDCConfig) *OIDC {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDC{cfg: cfg}
+}
+
+func (p *OIDC) Name() string { return p.cfg.Name }
+
+// discover loads provider metadata once; failures are retried on the next call.
+func (p *OIDC) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var d discovery
+	if err := getJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("oidc discovery for %s: %w", p.cfg.Name, err)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" {
+		return nil, fmt.Errorf("oidc discovery for %s: incomplete metadata", p.cfg.Name)
+	}
+	p.meta = &d
+	return p.meta, nil
+}
+
+func (p *OIDC) AuthURL(ctx context.Context, r AuthRequest) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {r.RedirectURI},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {r.State},
+		"nonce":                 {r.Nonce},
+		"code_challenge":        {r.CodeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	return withQuery(meta.AuthorizationEndpoint, q), nil
+}
+
+func (p *OIDC) Exchange(ctx context.Context, code, verifier, redirectURI, nonce string) (*Identity, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	var tok struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := postForm(ctx, meta.TokenEndpoint, form, &tok); err != nil {
+		return nil, err
+	}
+	if tok.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token", ErrExchange)
+	}
+	return p.verify(ctx, meta, tok.IDToken, nonce)
+}
+
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+func (p *OIDC) verify(ctx context.Context, meta *discovery, raw, nonce string) (*Identity, error) {
+	var c idClaims
+	methods := []string{"RS256", "ES256"}
+	hmacKey := p.cfg.AllowHS256 && p.cfg.ClientSecret != ""
+	if hmacKey {
+		methods = append(methods, "HS256")
+	}
+	_, err := jwt.ParseWithClaims(raw, &c, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			if !hmacKey {
+				return nil, fmt.Errorf("%s does not sign with the client secret", p.cfg.Name)
+			}
+			return []byte(p.cfg.ClientSecret), nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, meta, kid)
+	},
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if c.Nonce != nonce || c.Subject == "" {
+		return nil, fmt.Errorf("%w: nonce or subject mismatch", ErrExchange)
+	}
+	id := &Identity{Subject: c.Subject, Email: strings.TrimSpace(c.Email), Name: c.Name}
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		id.EmailVerified = v
+	case string:
+		id.EmailVerified = v == "true"
+	case nil:
+		id.EmailVerified = p.cfg.TrustEmail
+	}
+	if id.Email == "" {
+		id.EmailVerified = false
+	}
+	return id, nil
+}
+
+// key returns the JWKS key for kid, refetching the set when kid is unknown
+// (key rotation) but at most once a minute.
+func (p *OIDC) key(ctx context.Context, meta *discovery, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(p.keysAt) < time.Minute {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	p.keys = map[string]any{}
+	p.keysAt = time.Now()
+	for _, k := range set.Keys {
+		if pub, err := k.public(); err == nil {
+			p.keys[k.Kid] = pub
+		}
+	}
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) public() (any, error) {
+	b := func(s string) (*big.Int, error) {
+		raw, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(raw), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := b(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := b(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func withQuery(endpoint string, q url.Values) string {
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + q.Encode()
+}
+
+func getJSON(ctx context.Context, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(req, out)
+}
+
+func postForm(ctx context.Context, u string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doJSON(req, out)
+}
+
+func doJSON(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: %s %s: %d %s", ErrExchange, req.Method, req.URL.Host, res.StatusCode, truncate(string(body), 200))
+	}
+	return json.Unmarshal(body, out)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package social
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyHS256(t *testing.T) {
+	meta := &discovery{Issuer: "https://issuer.example"}
+	sign := func(secret string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": meta.Issuer, "aud": "client", "sub": "u1", "nonce": "n",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		s, err := tok.SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	tests := []struct {
+		name   string
+		cfg    OIDCConfig
+		secret string
+		ok     bool
+	}{
+		{name: "allowed", cfg: OIDCConfig{ClientID: "client", ClientSecret: "channel secret", AllowHS256: true}, secret: "channel secret", ok: true},
+		{name: "not allowed", cfg: OIDCConfig{ClientID: "client", ClientSecret: "channel secret"}, secret: "channel secret"},
+		{name: "empty secret", cfg: OIDCConfig{ClientID: "client", AllowHS256: true}, secret: ""},
+		{name: "wrong secret", cfg: OIDCConfig{ClientID: "client", ClientSecret: "channel secret", AllowHS256: true}, secret: "guess"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewOIDC(tt.cfg).verify(context.Background(), meta, sign(tt.secret), "n")
+			if (err == nil) != tt.ok {
+				t.Fatalf("verify: err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
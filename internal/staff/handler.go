@@ -0,0 +1,70 @@
+package staff
+
+import (
+	"net/http"
+
+	"workshop-be/internal/card"
+	"workshop-be/internal/httpx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Earn godoc
+// @Summary Credit points for a purchase (staff)
+// @Description Identify the member by scanned card payload or membership code. Points = amount / 100 x the tier's earn rate, rounded down. A (store_id, reference) pair can be credited once.
+// @Tags Staff
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body EarnInput true "purchase"
+// @Success 201 {object} EarnOutput
+// @Failure 400 {object} httpx.ErrorResponse
+// @Failure 401 {object} httpx.ErrorResponse
+// @Failure 403 {object} httpx.ErrorResponse
+// @Failure 404 {object} httpx.ErrorResponse
+// @Failure 409 {object} httpx.ErrorResponse
+// @Router /api/v1/staff/earn [post]
+func (h *Handler) Earn(c *fiber.Ctx) error {
+	uid, ok := httpx.CurrentUserID(c)
+	if !ok {
+		return httpx.WriteError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+	}
+	var in EarnInput
+	if err := c.BodyParser(&in); err != nil {
+		return httpx.WriteError(c, http.StatusBadRequest, "INVALID_PAYLOAD", "invalid payload")
+	}
+	out, err := h.svc.Earn(c.UserContext(), uid, in)
+	if err != nil {
+		switch err {
+		case ErrInvalidAmount:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_AMOUNT", "amount must be greater than 0 and at most 1,000,000 THB")
+		case ErrInvalidReference:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_REFERENCE", "reference is required (max 64 characters); store_id max 20 characters")
+		case card.ErrInvalidPayload:
+			return httpx.WriteError(c, http.StatusBadRequest, "INVALID_CARD", "member must be a card payload or membership code")
+		case card.ErrExpired:
+			return httpx.WriteError(c, http.StatusBadRequest, "CARD_EXPIRED", "card payload expired; ask the member to refresh the card")
+		case card.ErrMemberNotFound:
+			return httpx.WriteError(c, http.StatusNotFound, "MEMBER_NOT_FOUND", "member not found")
+		case ErrDuplicatePurchase:
+			return httpx.WriteError(c, http.StatusConflict, "DUPLICATE_PURCHASE", "this reference was already credited")
+		default:
+			return httpx.WriteError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+	}
+	return c.Status(http.StatusCreated).JSON(out)
+}
+
+// RegisterRoutes mounts the staff endpoints; r must restrict access to staff.
+func RegisterRoutes(r fiber.Router, svc *Service) {
+	r.Post("/earn", NewHandler(svc).Earn)
+}
@@ -0,0 +1,19 @@
+package staff
+
+import "time"
+
+// Purchase is a sale credited to a member by store staff or a POS system.
+// (store_id, reference) is unique so a retried request can't credit twice.
+type Purchase struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	UserID  uint   `json:"-" gorm:"index;not null"`
+	StaffID uint   `json:"staff_id" gorm:"index;not null"`
+	StoreID string `json:"store_id" gorm:"size:20;not null;default:'';uniqueIndex:idx_purchase_ref"`
+	// Reference is the receipt or order number from the POS.
+	Reference string `json:"reference" gorm:"size:64;not null;uniqueIndex:idx_purchase_ref"`
+	// AmountSatang is the purchase amount in satang (1/100 THB).
+	AmountSatang  int64     `json:"amount_satang"`
+	Points        int       `json:"points"`
+	TransactionID *uint     `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
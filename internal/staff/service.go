@@ -0,0 +1,136 @@
+// Package staff is the store side of the loyalty program: crediting points
+// for purchases against a scanned membership card.
+package staff
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+
+	"workshop-be/internal/audit"
+	"workshop-be/internal/card"
+	"workshop-be/internal/db"
+	"workshop-be/internal/points"
+
+	"go.opentelemetry.io/otel"
+	"gorm.io/gorm"
+)
+
+var tracer = otel.Tracer("workshop-be/internal/staff")
+
+var (
+	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrInvalidReference  = errors.New("invalid reference")
+	ErrDuplicatePurchase = errors.New("purchase already credited")
+)
+
+// maxAmountSatang caps a single purchase at 1,000,000 THB to catch typos.
+const maxAmountSatang = 100_000_000
+
+// DefaultEarnRates are points per 100 THB by tier.
+var DefaultEarnRates = map[string]float64{
+	"Bronze":   4,
+	"Silver":   5,
+	"Gold":     6,
+	"Platinum": 8,
+}
+
+type Service struct {
+	points *points.Service
+	cards  *card.Service
+	audit  *audit.Service
+	// EarnRates maps tier to points per 100 THB; tiers not listed earn at
+	// the Bronze rate.
+	EarnRates map[string]float64
+}
+
+func NewService(pts *points.Service, cards *card.Service) *Service {
+	return &Service{points: pts, cards: cards, audit: audit.NewService(), EarnRates: DefaultEarnRates}
+}
+
+type EarnInput struct {
+	// Member is the scanned card payload or a typed-in membership code.
+	Member string `json:"member" example:"MC1.LBK001234.1767225600.Ab3dEf..."`
+	// Amount is the purchase total in THB.
+	Amount    float64 `json:"amount" example:"1250.50"`
+	Reference string  `json:"reference" example:"POS1-000123"`
+	StoreID   string  `json:"store_id" example:"BKK-01"`
+}
+
+type EarnOutput struct {
+	PurchaseID     uint   `json:"purchase_id"`
+	MembershipCode string `json:"membership_code" example:"LBK001234"`
+	PointsEarned   int    `json:"points_earned" example:"50"`
+	Balance        int    `json:"balance" example:"1650"`
+	Tier           string `json:"tier" example:"Silver"`
+}
+
+// rate returns the earn rate for tier.
+func (s *Service) rate(tier string) float64 {
+	if r, ok := s.EarnRates[tier]; ok {
+		return r
+	}
+	return s.EarnRates["Bronze"]
+}
+
+// Earn credits points for a purchase to the member identified by in.Member,
+// at the earn rate of their tier before the purchase. Fractions of a point
+// are dropped.
+func (s *Service) Earn(ctx context.Context, staffID uint, in EarnInput) (*EarnOutput, error) {
+	ctx, span := tracer.Start(ctx, "staff.Service.Earn")
+	defer span.End()
+	satang := int64(math.Round(in.Amount * 100))
+	if satang <= 0 || satang > maxAmountSatang {
+		return nil, ErrInvalidAmount
+	}
+	ref := strings.TrimSpace(in.Reference)
+	store := strings.TrimSpace(in.StoreID)
+	if ref == "" || len(ref) > 64 || len(store) > 20 {
+		return nil, ErrInvalidReference
+	}
+	userID, code, err := s.cards.Lookup(ctx, in.Member)
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.points.Balance(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	earned := int(math.Floor(float64(satang) * s.rate(before.Tier) / 10000))
+
+	// Claim the reference first; the unique index turns a retry into
+	// ErrDuplicatePurchase instead of a second credit.
+	d := db.MustGet().WithContext(ctx)
+	p := Purchase{UserID: userID, StaffID: staffID, StoreID: store, Reference: ref, AmountSatang: satang, Points: earned}
+	if err := d.Create(&p).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrDuplicatePurchase
+		}
+		return nil, err
+	}
+	if earned > 0 {
+		t, err := s.points.Apply(ctx, points.Entry{UserID: userID, Delta: earned, Kind: points.KindPurchase, Reference: purchaseRef(store, ref)})
+		if err != nil {
+			d.Delete(&p)
+			return nil, err
+		}
+		d.Model(&p).Update("transaction_id", t.ID)
+	}
+	s.audit.Record(ctx, audit.Entry{Type: audit.EventAdminAction, ActorID: &staffID, UserID: &userID, Details: map[string]any{
+		"action": "points.earn", "purchase_id": p.ID, "store_id": store, "reference": ref, "amount_satang": satang, "points": earned,
+	}})
+	after, err := s.points.Balance(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &EarnOutput{PurchaseID: p.ID, MembershipCode: code, PointsEarned: earned, Balance: after.Points, Tier: after.Tier}, nil
+}
+
+// purchaseRef is the ledger reference shown to the member.
+func purchaseRef(store, ref string) string {
+	if store == "" {
+		return "purchase " + ref
+	}
+	return "purchase " + store + "/" + ref
+}
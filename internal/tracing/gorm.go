@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"workshop-be/internal/db"
+)
+
+const spanKey = "tracing:span"
+
+// GormPlugin emits a client span per GORM statement, parented to the context
+// passed via db.WithContext. The SQL is recorded with placeholders only.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string { return "tracing" }
+
+func (GormPlugin) Initialize(g *gorm.DB) error {
+	return db.RegisterHooks(g, "tracing", startSpan, func(string) func(*gorm.DB) { return endSpan })
+}
+
+func startSpan(op string) func(*gorm.DB) {
+	return func(g *gorm.DB) {
+		ctx := g.Statement.Context
+		if ctx == nil {
+			return
+		}
+		_, span := tracer.Start(ctx, "gorm."+op, trace.WithSpanKind(trace.SpanKindClient))
+		span.SetAttributes(
+			attribute.String("db.system", "sqlite"),
+			attribute.String("db.operation", op),
+		)
+		g.InstanceSet(spanKey, span)
+	}
+}
+
+func endSpan(g *gorm.DB) {
+	v, ok := g.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.sql.table", g.Statement.Table),
+		attribute.String("db.statement", g.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", g.Statement.RowsAffected),
+	)
+	if err := g.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("workshop-be/internal/tracing")
+
+// headerCarrier adapts Fiber request headers to a propagation.TextMapCarrier.
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string { return h.c.Get(key) }
+
+func (h headerCarrier) Set(key, value string) { h.c.Set(key, value) }
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(k, _ []byte) { keys = append(keys, string(k)) })
+	return keys
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+// Middleware starts a server span per request, continuing any incoming W3C
+// traceparent, and stores it in the user context for handlers and services.
+// Span names use the route template, never the raw path.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+		method := strings.Clone(c.Method())
+		ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+		route := c.Route().Path
+		span.SetName(method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", c.Path()),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+			if err != nil {
+				span.RecordError(err)
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const defaultServiceName = "workshop-be"
+
+// Setup installs the W3C trace-context propagator and, when an OTLP endpoint is
+// configured, a batching OTLP/HTTP exporter. Tracing is off when
+// OTEL_SDK_DISABLED=true or no OTEL_EXPORTER_OTLP_(TRACES_)ENDPOINT is set; incoming
+// traceparent headers are still honoured so ids flow into logs.
+//
+// The returned shutdown flushes pending spans and must be called on exit.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	noop := func(context.Context) error { return nil }
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		slog.Info("tracing disabled", "reason", "OTEL_SDK_DISABLED")
+		return noop, nil
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		slog.Info("tracing disabled", "reason", "no OTLP endpoint configured")
+		return noop, nil
+	}
+
+	// Endpoint, headers, TLS and timeout are read from the standard OTEL_EXPORTER_OTLP_* env.
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", defaultServiceName)),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES override the default
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return noop, err
+	}
+	// Sampler follows OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG (default parentbased_always_on).
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	slog.Info("tracing enabled", "exporter", "otlphttp")
+	return tp.Shutdown, nil
+}
@@ -0,0 +1,171 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errCBOR = errors.New("malformed CBOR")
+
+// maxCBORDepth bounds nesting; authenticator data is at most a few levels deep.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR item in b and returns it with the number
+// of bytes it used. It covers the subset WebAuthn needs (RFC 8949 definite
+// lengths): integers become int64, byte strings []byte, text strings string,
+// arrays []any and maps map[any]any with int64 or string keys. Tags are
+// dropped and floats decode to nil.
+func decodeCBOR(b []byte) (any, int, error) {
+	d := cborDecoder{b: b}
+	v, err := d.item(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.off, nil
+}
+
+type cborDecoder struct {
+	b   []byte
+	off int
+}
+
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.off) {
+		return nil, errCBOR
+	}
+	out := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return out, nil
+}
+
+// head reads an item's initial byte and argument.
+func (d *cborDecoder) head() (major byte, info byte, arg uint64, err error) {
+	h, err := d.take(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = h[0]>>5, h[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		raw, err := d.take(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, c := range raw {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, info, arg, nil
+	default:
+		// Indefinite lengths are not used by authenticators.
+		return 0, 0, 0, errCBOR
+	}
+}
+
+func (d *cborDecoder) item(depth int) (any, error) {
+	if depth > maxCBORDepth {
+		return nil, errCBOR
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		raw, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 3:
+		raw, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(raw), nil
+	case 4:
+		// Every item takes at least one byte, so a longer count is malformed.
+		if arg > uint64(len(d.b)-d.off) {
+			return nil, errCBOR
+		}
+		out := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case 5:
+		if arg > uint64(len(d.b)-d.off)/2 {
+			return nil, errCBOR
+		}
+		out := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errCBOR
+			}
+			if _, dup := out[k]; dup {
+				return nil, errCBOR
+			}
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	case 6:
+		return d.item(depth + 1)
+	default:
+		switch {
+		case info == 20:
+			return false, nil
+		case info == 21:
+			return true, nil
+		case info == 22 || info == 23:
+			return nil, nil
+		case info >= 25 && info <= 27:
+			// Float; its bytes were consumed by head.
+			return nil, nil
+		}
+		return nil, errCBOR
+	}
+}
+
+// cborMap type-asserts a decoded map.
+func cborMap(v any) (map[any]any, bool) {
+	m, ok := v.(map[any]any)
+	return m, ok
+}
+
+func cborInt(m map[any]any, key any) (int64, bool) {
+	v, ok := m[key].(int64)
+	return v, ok
+}
+
+func cborBytes(m map[any]any, key any) ([]byte, bool) {
+	v, ok := m[key].([]byte)
+	return v, ok
+}
+
+func uint32At(b []byte) uint32 { return binary.BigEndian.Uint32(b) }
@@ -0,0 +1,166 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// cborHead encodes an item's initial byte and argument in its shortest form.
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+}
+
+// cborPair and cborPairs encode a map in the given order, so tests can build
+// maps with duplicate keys.
+type cborPair struct{ k, v any }
+
+type cborPairs []cborPair
+
+// encodeCBOR is the encoder side of decodeCBOR, for building test vectors.
+func encodeCBOR(v any) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []any:
+		out := cborHead(4, uint64(len(v)))
+		for _, e := range v {
+			out = append(out, encodeCBOR(e)...)
+		}
+		return out
+	case cborPairs:
+		out := cborHead(5, uint64(len(v)))
+		for _, p := range v {
+			out = append(out, encodeCBOR(p.k)...)
+			out = append(out, encodeCBOR(p.v)...)
+		}
+		return out
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case nil:
+		return []byte{0xf6}
+	}
+	panic("encodeCBOR: unsupported type")
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	nested := []byte{}
+	for range maxCBORDepth + 2 {
+		nested = append(nested, 0x81)
+	}
+	nested = append(nested, 0x00)
+
+	tests := []struct {
+		name string
+		in   []byte
+		want any
+		used int
+		err  bool
+	}{
+		// Examples from RFC 8949 Appendix A.
+		{name: "zero", in: mustHex("00"), want: int64(0), used: 1},
+		{name: "uint8", in: mustHex("1818"), want: int64(24), used: 2},
+		{name: "uint16", in: mustHex("1903e8"), want: int64(1000), used: 3},
+		{name: "uint32", in: mustHex("1a000f4240"), want: int64(1000000), used: 5},
+		{name: "uint64", in: mustHex("1b000000e8d4a51000"), want: int64(1000000000000), used: 9},
+		{name: "negative", in: mustHex("3863"), want: int64(-100), used: 2},
+		{name: "byte string", in: mustHex("4401020304"), want: []byte{1, 2, 3, 4}, used: 5},
+		{name: "text string", in: mustHex("6449455446"), want: "IETF", used: 5},
+		{name: "array", in: mustHex("83010203"), want: []any{int64(1), int64(2), int64(3)}, used: 4},
+		{name: "map", in: mustHex("a201020304"), want: map[any]any{int64(1): int64(2), int64(3): int64(4)}, used: 5},
+		{name: "text keys", in: mustHex("a26161016162820203"), want: map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}, used: 9},
+		{name: "simple values", in: mustHex("83f4f5f6"), want: []any{false, true, nil}, used: 4},
+		{name: "float", in: mustHex("f93c00"), want: nil, used: 3},
+		{name: "tag dropped", in: mustHex("c11a514b67b0"), want: int64(1363896240), used: 6},
+		{name: "trailing bytes not consumed", in: mustHex("0102"), want: int64(1), used: 1},
+
+		{name: "empty", in: nil, err: true},
+		{name: "truncated argument", in: mustHex("19e8"), err: true},
+		{name: "truncated byte string", in: mustHex("440102"), err: true},
+		{name: "truncated array", in: mustHex("830102"), err: true},
+		{name: "truncated map value", in: mustHex("a20102"), err: true},
+		{name: "indefinite length", in: mustHex("5f42010243030405ff"), err: true},
+		{name: "reserved additional info", in: mustHex("1c"), err: true},
+		{name: "uint over int64", in: mustHex("1bffffffffffffffff"), err: true},
+		{name: "negative over int64", in: mustHex("3bffffffffffffffff"), err: true},
+		{name: "oversized byte string", in: mustHex("5bffffffffffffffff00"), err: true},
+		{name: "oversized array count", in: mustHex("9affffffff00"), err: true},
+		{name: "oversized map count", in: mustHex("baffffffff0000"), err: true},
+		{name: "duplicate int key", in: mustHex("a201020103"), err: true},
+		{name: "duplicate text key", in: encodeCBOR(cborPairs{{"fmt", "none"}, {"fmt", "packed"}}), err: true},
+		{name: "byte string key", in: mustHex("a1420102" + "01"), err: true},
+		{name: "too deep", in: nested, err: true},
+		{name: "break outside indefinite item", in: mustHex("ff"), err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, used, err := decodeCBOR(tt.in)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("decodeCBOR(%x) = %#v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeCBOR(%x): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || used != tt.used {
+				t.Fatalf("decodeCBOR(%x) = %#v, %d; want %#v, %d", tt.in, got, used, tt.want, tt.used)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORCopiesByteStrings(t *testing.T) {
+	in := mustHex("43010203")
+	got, _, err := decodeCBOR(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in[1] = 0xff
+	if !bytes.Equal(got.([]byte), []byte{1, 2, 3}) {
+		t.Fatalf("decoded byte string aliases the input: %x", got)
+	}
+}
+
+func TestEncodeCBORRoundTrip(t *testing.T) {
+	raw := encodeCBOR(cborPairs{{1, 2}, {3, -7}, {-2, []byte{9}}, {"alg", "x"}})
+	got, used, err := decodeCBOR(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[any]any{int64(1): int64(2), int64(3): int64(-7), int64(-2): []byte{9}, "alg": "x"}
+	if !reflect.DeepEqual(got, want) || used != len(raw) {
+		t.Fatalf("round trip = %#v, %d", got, used)
+	}
+}
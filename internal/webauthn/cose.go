@@ -0,0 +1,110 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithm identifiers offered to authenticators, in order of preference.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+var (
+	errUnsupportedKey = errors.New("unsupported credential public key")
+	errSignature      = errors.New("signature verification failed")
+)
+
+// publicKey is a credential public key decoded from its COSE_Key form.
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parseCOSEKey decodes a COSE_Key (RFC 9053) for one of the supported algorithms.
+func parseCOSEKey(raw []byte) (*publicKey, error) {
+	v, n, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(raw) {
+		return nil, errCBOR
+	}
+	m, ok := cborMap(v)
+	if !ok {
+		return nil, errUnsupportedKey
+	}
+	kty, _ := cborInt(m, int64(1))
+	alg, _ := cborInt(m, int64(3))
+	switch {
+	case kty == 2 && alg == AlgES256:
+		crv, _ := cborInt(m, int64(-1))
+		x, okx := cborBytes(m, int64(-2))
+		y, oky := cborBytes(m, int64(-3))
+		if crv != 1 || !okx || !oky || len(x) != 32 || len(y) != 32 {
+			return nil, errUnsupportedKey
+		}
+		// ecdh rejects points that are not on the curve.
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, errUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}}, nil
+	case kty == 1 && alg == AlgEdDSA:
+		crv, _ := cborInt(m, int64(-1))
+		x, ok := cborBytes(m, int64(-2))
+		if crv != 6 || !ok || len(x) != ed25519.PublicKeySize {
+			return nil, errUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+	case kty == 3 && alg == AlgRS256:
+		nb, okn := cborBytes(m, int64(-1))
+		eb, oke := cborBytes(m, int64(-2))
+		if !okn || !oke || len(eb) > 4 {
+			return nil, errUnsupportedKey
+		}
+		k := &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(new(big.Int).SetBytes(eb).Int64())}
+		if k.N.BitLen() < 2048 || k.E < 3 {
+			return nil, errUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: k}, nil
+	}
+	return nil, errUnsupportedKey
+}
+
+// verify checks sig over data with the key's algorithm.
+func (k *publicKey) verify(data, sig []byte) error {
+	return verifySignature(k.alg, k.key, data, sig)
+}
+
+func verifySignature(alg int64, key crypto.PublicKey, data, sig []byte) error {
+	ok := false
+	switch alg {
+	case AlgES256:
+		if pk, isEC := key.(*ecdsa.PublicKey); isEC {
+			sum := sha256.Sum256(data)
+			ok = ecdsa.VerifyASN1(pk, sum[:], sig)
+		}
+	case AlgEdDSA:
+		if pk, isEd := key.(ed25519.PublicKey); isEd {
+			ok = ed25519.Verify(pk, data, sig)
+		}
+	case AlgRS256:
+		if pk, isRSA := key.(*rsa.PublicKey); isRSA {
+			sum := sha256.Sum256(data)
+			ok = rsa.VerifyPKCS1v15(pk, crypto.SHA256, sum[:], sig) == nil
+		}
+	}
+	if !ok {
+		return errSignature
+	}
+	return nil
+}
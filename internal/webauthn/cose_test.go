@@ -0,0 +1,96 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+func coseES256(k *ecdsa.PublicKey) []byte {
+	return encodeCBOR(cborPairs{{1, 2}, {3, AlgES256}, {-1, 1}, {-2, k.X.FillBytes(make([]byte, 32))}, {-3, k.Y.FillBytes(make([]byte, 32))}})
+}
+
+func coseEdDSA(k ed25519.PublicKey) []byte {
+	return encodeCBOR(cborPairs{{1, 1}, {3, AlgEdDSA}, {-1, 6}, {-2, []byte(k)}})
+}
+
+func coseRS256(k *rsa.PublicKey) []byte {
+	return encodeCBOR(cborPairs{{1, 3}, {3, AlgRS256}, {-1, k.N.Bytes()}, {-2, big.NewInt(int64(k.E)).Bytes()}})
+}
+
+func TestParseCOSEKey(t *testing.T) {
+	ec, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, ed, _ := ed25519.GenerateKey(rand.Reader)
+	rs, _ := rsa.GenerateKey(rand.Reader, 2048)
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	data := []byte("authenticator data || client data hash")
+	sum := sha256.Sum256(data)
+	ecSig, _ := ecdsa.SignASN1(rand.Reader, ec, sum[:])
+	rsSig, _ := rsa.SignPKCS1v15(rand.Reader, rs, crypto.SHA256, sum[:])
+
+	x, y := ec.X.FillBytes(make([]byte, 32)), ec.Y.FillBytes(make([]byte, 32))
+	offCurve := append([]byte(nil), y...)
+	offCurve[31] ^= 1
+
+	tests := []struct {
+		name string
+		raw  []byte
+		sig  []byte
+		err  error
+	}{
+		{name: "ES256", raw: coseES256(&ec.PublicKey), sig: ecSig},
+		{name: "EdDSA", raw: coseEdDSA(edPub), sig: ed25519.Sign(ed, data)},
+		{name: "RS256", raw: coseRS256(&rs.PublicKey), sig: rsSig},
+
+		{name: "trailing bytes", raw: append(coseES256(&ec.PublicKey), 0), err: errCBOR},
+		{name: "truncated", raw: coseES256(&ec.PublicKey)[:40], err: errCBOR},
+		{name: "not a map", raw: encodeCBOR([]any{2, AlgES256}), err: errUnsupportedKey},
+		{name: "unsupported algorithm", raw: encodeCBOR(cborPairs{{1, 2}, {3, -35}, {-1, 2}, {-2, x}, {-3, y}}), err: errUnsupportedKey},
+		{name: "key type does not match algorithm", raw: encodeCBOR(cborPairs{{1, 1}, {3, AlgES256}, {-1, 1}, {-2, x}, {-3, y}}), err: errUnsupportedKey},
+		{name: "ES256 wrong curve", raw: encodeCBOR(cborPairs{{1, 2}, {3, AlgES256}, {-1, 2}, {-2, x}, {-3, y}}), err: errUnsupportedKey},
+		{name: "ES256 short coordinate", raw: encodeCBOR(cborPairs{{1, 2}, {3, AlgES256}, {-1, 1}, {-2, x[1:]}, {-3, y}}), err: errUnsupportedKey},
+		{name: "ES256 point not on curve", raw: encodeCBOR(cborPairs{{1, 2}, {3, AlgES256}, {-1, 1}, {-2, x}, {-3, offCurve}}), err: errUnsupportedKey},
+		{name: "EdDSA wrong curve", raw: encodeCBOR(cborPairs{{1, 1}, {3, AlgEdDSA}, {-1, 7}, {-2, []byte(edPub)}}), err: errUnsupportedKey},
+		{name: "RS256 under 2048 bits", raw: coseRS256(&weak.PublicKey), err: errUnsupportedKey},
+		{name: "RS256 oversized exponent", raw: encodeCBOR(cborPairs{{1, 3}, {3, AlgRS256}, {-1, rs.N.Bytes()}, {-2, []byte{1, 0, 0, 0, 1}}}), err: errUnsupportedKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := parseCOSEKey(tt.raw)
+			if err != tt.err {
+				t.Fatalf("parseCOSEKey: err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if err := k.verify(data, tt.sig); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if err := k.verify([]byte("other data"), tt.sig); err != errSignature {
+				t.Fatalf("verify over other data: err = %v, want %v", err, errSignature)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureKeyMismatch(t *testing.T) {
+	ec, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	sum := sha256.Sum256([]byte("data"))
+	sig, _ := ecdsa.SignASN1(rand.Reader, ec, sum[:])
+	if err := verifySignature(AlgEdDSA, &ec.PublicKey, []byte("data"), sig); err != errSignature {
+		t.Fatalf("EdDSA alg with an ECDSA key: err = %v", err)
+	}
+	if err := verifySignature(AlgES256, edPub, []byte("data"), sig); err != errSignature {
+		t.Fatalf("ES256 alg with an Ed25519 key: err = %v", err)
+	}
+	if err := verifySignature(-35, &ec.PublicKey, []byte("data"), sig); err != errSignature {
+		t.Fatalf("unknown alg: err = %v", err)
+	}
+}
@@ -0,0 +1,453 @@
+// Package webauthn implements the relying party side of WebAuthn (passkey)
+// registration and authentication ceremonies. Attestation is requested as
+// "none": authenticators are not checked against a trust list, so only the
+// "none" and "packed" statement formats are accepted.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrVerification is returned when a ceremony response fails any check. The
+// wrapped detail is for logs only.
+var ErrVerification = errors.New("webauthn verification failed")
+
+// Authenticator data flags (WebAuthn §6.1).
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackedUp       = 0x10
+	flagAttested       = 0x40
+)
+
+// Timeout is the ceremony timeout sent to the browser.
+const Timeout = 5 * time.Minute
+
+// RelyingParty is this service as seen by authenticators.
+type RelyingParty struct {
+	// ID is the domain passkeys are scoped to, e.g. example.com.
+	ID   string
+	Name string
+	// Origins are the exact origins allowed in client data: web origins such as
+	// https://app.example.com and app origins such as android:apk-key-hash:....
+	Origins []string
+}
+
+// Challenge returns a new random challenge in its base64url form.
+func Challenge() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeBase64 accepts base64url with or without padding, as browsers send it.
+func DecodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// CredentialDescriptor names an existing credential.
+type CredentialDescriptor struct {
+	Type       string   `json:"type" example:"public-key"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type RPEntity struct {
+	ID   string `json:"id" example:"example.com"`
+	Name string `json:"name" example:"Workshop"`
+}
+
+type UserEntity struct {
+	// ID is the opaque user handle, base64url.
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type" example:"public-key"`
+	Alg  int    `json:"alg" example:"-7"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey" example:"required"`
+	UserVerification string `json:"userVerification" example:"required"`
+}
+
+// CreationOptions is PublicKeyCredentialCreationOptions in the JSON form of
+// PublicKeyCredential.parseCreationOptionsFromJSON.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout" example:"300000"`
+	Attestation            string                 `json:"attestation" example:"none"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+}
+
+// RequestOptions is PublicKeyCredentialRequestOptions in JSON form. An empty
+// AllowCredentials lets the user pick any passkey for this RP.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId" example:"example.com"`
+	Timeout          int64                  `json:"timeout" example:"300000"`
+	UserVerification string                 `json:"userVerification" example:"required"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+}
+
+// CreationOptions builds the options for registering a passkey for user.
+// Credentials in exclude are refused by authenticators that already hold one.
+func (rp *RelyingParty) CreationOptions(challenge string, user UserEntity, exclude []CredentialDescriptor) CreationOptions {
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return CreationOptions{
+		Challenge: challenge,
+		RP:        RPEntity{ID: rp.ID, Name: rp.Name},
+		User:      user,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:                Timeout.Milliseconds(),
+		Attestation:            "none",
+		AuthenticatorSelection: AuthenticatorSelection{ResidentKey: "required", UserVerification: "required"},
+		ExcludeCredentials:     exclude,
+	}
+}
+
+// RequestOptions builds the options for signing in with a discoverable passkey.
+func (rp *RelyingParty) RequestOptions(challenge string) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          Timeout.Milliseconds(),
+		UserVerification: "required",
+		AllowCredentials: []CredentialDescriptor{},
+	}
+}
+
+// AttestationResponse is AuthenticatorAttestationResponse, base64url fields.
+type AttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// AttestationCredential is the PublicKeyCredential returned by
+// navigator.credentials.create(), as serialized by toJSON().
+type AttestationCredential struct {
+	ID       string              `json:"id"`
+	Type     string              `json:"type" example:"public-key"`
+	Response AttestationResponse `json:"response"`
+}
+
+// AssertionResponse is AuthenticatorAssertionResponse, base64url fields.
+type AssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// AssertionCredential is the PublicKeyCredential returned by
+// navigator.credentials.get(), as serialized by toJSON().
+type AssertionCredential struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type" example:"public-key"`
+	Response AssertionResponse `json:"response"`
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// ClientChallenge returns the challenge a response was made for, so the
+// caller can find the ceremony it belongs to. It is not verified here.
+func ClientChallenge(clientDataJSON string) (string, error) {
+	raw, err := DecodeBase64(clientDataJSON)
+	if err != nil {
+		return "", ErrVerification
+	}
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil || cd.Challenge == "" {
+		return "", ErrVerification
+	}
+	return cd.Challenge, nil
+}
+
+// Credential is a newly registered passkey.
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE_Key, stored as is and parsed on each login.
+	PublicKey      []byte
+	SignCount      uint32
+	AAGUID         []byte
+	BackupEligible bool
+	BackedUp       bool
+	Transports     []string
+}
+
+// Assertion is the result of a verified login.
+type Assertion struct {
+	SignCount uint32
+	BackedUp  bool
+}
+
+type authData struct {
+	raw       []byte
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	aaguid    []byte
+	credID    []byte
+	credKey   []byte
+}
+
+func parseAuthData(raw []byte) (*authData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("authenticator data too short")
+	}
+	ad := &authData{raw: raw, rpIDHash: raw[:32], flags: raw[32], signCount: uint32At(raw[33:37])}
+	if ad.flags&flagAttested == 0 {
+		return ad, nil
+	}
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data too short")
+	}
+	ad.aaguid = rest[:16]
+	n := int(rest[16])<<8 | int(rest[17])
+	rest = rest[18:]
+	if n == 0 || n > 1023 || len(rest) < n {
+		return nil, errors.New("bad credential id length")
+	}
+	ad.credID, rest = rest[:n], rest[n:]
+	_, used, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, err
+	}
+	ad.credKey = rest[:used]
+	return ad, nil
+}
+
+// checkClientData verifies the ceremony type, challenge and origin and
+// returns the hash the authenticator signed over.
+func (rp *RelyingParty) checkClientData(raw, typ, challenge string) ([]byte, error) {
+	b, err := DecodeBase64(raw)
+	if err != nil {
+		return nil, errors.New("client data is not base64url")
+	}
+	var cd clientData
+	if err := json.Unmarshal(b, &cd); err != nil {
+		return nil, errors.New("client data is not JSON")
+	}
+	if cd.Type != typ {
+		return nil, errors.New("wrong client data type")
+	}
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return nil, errors.New("challenge mismatch")
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) || cd.CrossOrigin {
+		return nil, errors.New("origin not allowed: " + cd.Origin)
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// checkAuthData verifies the RP ID hash and that the user was present and verified.
+func (rp *RelyingParty) checkAuthData(ad *authData) error {
+	want := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.rpIDHash, want[:]) {
+		return errors.New("rp id hash mismatch")
+	}
+	if ad.flags&flagUserPresent == 0 || ad.flags&flagUserVerified == 0 {
+		return errors.New("user not present or not verified")
+	}
+	return nil
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// issued for it (WebAuthn §7.1) and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(challenge string, c AttestationCredential) (*Credential, error) {
+	cred, err := rp.verifyRegistration(challenge, c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	return cred, nil
+}
+
+func (rp *RelyingParty) verifyRegistration(challenge string, c AttestationCredential) (*Credential, error) {
+	if c.Type != "public-key" {
+		return nil, errors.New("credential type is not public-key")
+	}
+	clientHash, err := rp.checkClientData(c.Response.ClientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+	rawObj, err := DecodeBase64(c.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.New("attestation object is not base64url")
+	}
+	v, n, err := decodeCBOR(rawObj)
+	if err != nil || n != len(rawObj) {
+		return nil, errors.New("attestation object is not CBOR")
+	}
+	obj, ok := cborMap(v)
+	if !ok {
+		return nil, errors.New("attestation object is not a map")
+	}
+	format, _ := obj["fmt"].(string)
+	stmt, _ := cborMap(obj["attStmt"])
+	rawAuth, _ := cborBytes(obj, "authData")
+	ad, err := parseAuthData(rawAuth)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.checkAuthData(ad); err != nil {
+		return nil, err
+	}
+	if ad.credID == nil {
+		return nil, errors.New("no attested credential data")
+	}
+	if id, err := DecodeBase64(c.ID); err != nil || !bytes.Equal(id, ad.credID) {
+		return nil, errors.New("credential id mismatch")
+	}
+	key, err := parseCOSEKey(ad.credKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyStatement(format, stmt, key, slices.Concat(rawAuth, clientHash)); err != nil {
+		return nil, err
+	}
+	return &Credential{
+		ID:             ad.credID,
+		PublicKey:      ad.credKey,
+		SignCount:      ad.signCount,
+		AAGUID:         ad.aaguid,
+		BackupEligible: ad.flags&flagBackupEligible != 0,
+		BackedUp:       ad.flags&flagBackedUp != 0,
+		Transports:     c.Response.Transports,
+	}, nil
+}
+
+// verifyStatement checks the attestation statement's signature. The
+// attestation certificate, if any, is not chained to a trusted root.
+func verifyStatement(format string, stmt map[any]any, key *publicKey, signed []byte) error {
+	switch format {
+	case "none":
+		if len(stmt) != 0 {
+			return errors.New("none attestation with a statement")
+		}
+		return nil
+	case "packed":
+		alg, _ := cborInt(stmt, "alg")
+		sig, _ := cborBytes(stmt, "sig")
+		x5c, hasCert := stmt["x5c"].([]any)
+		if !hasCert {
+			// Self attestation: signed by the credential key itself.
+			if alg != key.alg {
+				return errors.New("self attestation algorithm mismatch")
+			}
+			return key.verify(signed, sig)
+		}
+		if len(x5c) == 0 {
+			return errors.New("empty x5c")
+		}
+		der, _ := x5c[0].([]byte)
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		return verifySignature(alg, cert.PublicKey, signed, sig)
+	}
+	return errors.New("unsupported attestation format: " + format)
+}
+
+// VerifyAssertion checks a login response against the challenge issued for
+// it and the stored COSE public key (WebAuthn §7.2). userHandle, when the
+// authenticator returned one, must match the credential's owner. Checking
+// the sign counter is left to the caller, which holds the stored value; see
+// SignCountValid.
+func (rp *RelyingParty) VerifyAssertion(challenge string, publicKey []byte, userHandle string, c AssertionCredential) (*Assertion, error) {
+	a, err := rp.verifyAssertion(challenge, publicKey, userHandle, c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	return a, nil
+}
+
+func (rp *RelyingParty) verifyAssertion(challenge string, publicKey []byte, userHandle string, c AssertionCredential) (*Assertion, error) {
+	if c.Type != "public-key" {
+		return nil, errors.New("credential type is not public-key")
+	}
+	if c.Response.UserHandle != "" {
+		got, err := DecodeBase64(c.Response.UserHandle)
+		want, _ := DecodeBase64(userHandle)
+		if err != nil || !bytes.Equal(got, want) {
+			return nil, errors.New("user handle mismatch")
+		}
+	}
+	clientHash, err := rp.checkClientData(c.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return nil, err
+	}
+	rawAuth, err := DecodeBase64(c.Response.AuthenticatorData)
+	if err != nil {
+		return nil, errors.New("authenticator data is not base64url")
+	}
+	ad, err := parseAuthData(rawAuth)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.checkAuthData(ad); err != nil {
+		return nil, err
+	}
+	sig, err := DecodeBase64(c.Response.Signature)
+	if err != nil {
+		return nil, errors.New("signature is not base64url")
+	}
+	key, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.verify(slices.Concat(rawAuth, clientHash), sig); err != nil {
+		return nil, err
+	}
+	return &Assertion{SignCount: ad.signCount, BackedUp: ad.flags&flagBackedUp != 0}, nil
+}
+
+// SignCountValid reports whether an assertion's signature counter is
+// acceptable after stored. Authenticators that do not count report zero
+// every time; any other counter must strictly increase, or the credential
+// may have been cloned (WebAuthn §6.1.1).
+func SignCountValid(stored, got uint32) bool {
+	if stored == 0 && got == 0 {
+		return true
+	}
+	return got > stored
+}
+
+// AAGUIDString formats an authenticator model id for storage.
+func AAGUIDString(b []byte) string { return hex.EncodeToString(b) }
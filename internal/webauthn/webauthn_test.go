@@ -0,0 +1,428 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRP = &RelyingParty{ID: "example.com", Name: "Workshop", Origins: []string{"https://app.example.com"}}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func sign(t *testing.T, k *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, k, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// attestationCA is a packed attestation key with its certificate, as a
+// security key carries from its vendor.
+type attestationCA struct {
+	key  *ecdsa.PrivateKey
+	cert []byte
+}
+
+func newAttestationCA(t *testing.T) *attestationCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"Test Vendor"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Test Authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &attestationCA{key: key, cert: der}
+}
+
+// ceremony is one authenticator response, built field by field so each test
+// case can change exactly one thing about it.
+type ceremony struct {
+	t *testing.T
+
+	typ, challenge, origin string
+	crossOrigin            bool
+
+	rpID   string
+	flags  byte
+	count  uint32
+	aaguid []byte
+	credID []byte
+	key    *ecdsa.PrivateKey
+
+	// format and statement produce attStmt over authData || clientDataHash.
+	format    string
+	statement func(signed []byte) cborPairs
+	// extra entries are appended to the attestation object map.
+	extra cborPairs
+	// tamper edits the encoded attestation object or authenticator data.
+	tamper func([]byte) []byte
+	// resign, when set, makes the assertion signature cover different data.
+	resign []byte
+}
+
+func newCeremony(t *testing.T, typ string) *ceremony {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ceremony{
+		t:         t,
+		typ:       typ,
+		challenge: Challenge(),
+		origin:    "https://app.example.com",
+		rpID:      "example.com",
+		flags:     flagUserPresent | flagUserVerified | flagBackupEligible | flagBackedUp,
+		aaguid:    bytes.Repeat([]byte{0xad}, 16),
+		credID:    []byte("credential-0001"),
+		key:       key,
+		format:    "none",
+	}
+	c.statement = func([]byte) cborPairs { return cborPairs{} }
+	return c
+}
+
+func (c *ceremony) clientDataJSON() []byte {
+	raw, err := json.Marshal(clientData{Type: c.typ, Challenge: c.challenge, Origin: c.origin, CrossOrigin: c.crossOrigin})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return raw
+}
+
+func (c *ceremony) authData(attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(c.rpID))
+	out := append(rpHash[:], c.flags)
+	out = binary.BigEndian.AppendUint32(out, c.count)
+	if attested {
+		out[32] |= flagAttested
+		out = append(out, c.aaguid...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(c.credID)))
+		out = append(out, c.credID...)
+		out = append(out, coseES256(&c.key.PublicKey)...)
+	}
+	return out
+}
+
+func (c *ceremony) signed(authData, clientDataJSON []byte) []byte {
+	sum := sha256.Sum256(clientDataJSON)
+	return slices.Concat(authData, sum[:])
+}
+
+func (c *ceremony) registration() AttestationCredential {
+	cdj := c.clientDataJSON()
+	ad := c.authData(true)
+	obj := encodeCBOR(append(cborPairs{
+		{"fmt", c.format},
+		{"attStmt", c.statement(c.signed(ad, cdj))},
+		{"authData", ad},
+	}, c.extra...))
+	if c.tamper != nil {
+		obj = c.tamper(obj)
+	}
+	return AttestationCredential{
+		ID:   b64(c.credID),
+		Type: "public-key",
+		Response: AttestationResponse{
+			ClientDataJSON:    b64(cdj),
+			AttestationObject: b64(obj),
+			Transports:        []string{"internal", "hybrid"},
+		},
+	}
+}
+
+func (c *ceremony) assertion(userHandle []byte) AssertionCredential {
+	cdj := c.clientDataJSON()
+	ad := c.authData(false)
+	if c.tamper != nil {
+		ad = c.tamper(ad)
+	}
+	signed := c.signed(ad, cdj)
+	if c.resign != nil {
+		signed = c.resign
+	}
+	return AssertionCredential{
+		ID:   b64(c.credID),
+		Type: "public-key",
+		Response: AssertionResponse{
+			ClientDataJSON:    b64(cdj),
+			AuthenticatorData: b64(ad),
+			Signature:         b64(sign(c.t, c.key, signed)),
+			UserHandle:        b64(userHandle),
+		},
+	}
+}
+
+// Statement formats for registration.
+func packedSelf(c *ceremony) {
+	c.format = "packed"
+	c.statement = func(signed []byte) cborPairs {
+		return cborPairs{{"alg", AlgES256}, {"sig", sign(c.t, c.key, signed)}}
+	}
+}
+
+func packedX5C(ca *attestationCA) func(*ceremony) {
+	return func(c *ceremony) {
+		c.format = "packed"
+		c.statement = func(signed []byte) cborPairs {
+			return cborPairs{{"alg", AlgES256}, {"sig", sign(c.t, ca.key, signed)}, {"x5c", []any{ca.cert}}}
+		}
+	}
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	ca := newAttestationCA(t)
+	other := newAttestationCA(t)
+
+	tests := []struct {
+		name   string
+		format func(*ceremony)
+		edit   func(*ceremony)
+		cred   func(*AttestationCredential)
+		// err is a substring of the wrapped detail; empty means success.
+		err string
+	}{
+		{name: "none", format: func(*ceremony) {}},
+		{name: "packed self attestation", format: packedSelf},
+		{name: "packed x5c", format: packedX5C(ca)},
+		{name: "signature counter kept", format: packedSelf, edit: func(c *ceremony) { c.count = 7 }},
+
+		{name: "rp id hash mismatch", format: packedSelf, edit: func(c *ceremony) { c.rpID = "evil.example" }, err: "rp id hash mismatch"},
+		{name: "rp id of a parent domain", format: packedSelf, edit: func(c *ceremony) { c.rpID = "com" }, err: "rp id hash mismatch"},
+		{name: "user present clear", format: packedSelf, edit: func(c *ceremony) { c.flags &^= flagUserPresent }, err: "user not present or not verified"},
+		{name: "user verified clear", format: packedSelf, edit: func(c *ceremony) { c.flags &^= flagUserVerified }, err: "user not present or not verified"},
+		{name: "wrong challenge", format: packedSelf, edit: func(c *ceremony) { c.challenge = Challenge() }, err: "challenge mismatch"},
+		{name: "wrong origin", format: packedSelf, edit: func(c *ceremony) { c.origin = "https://evil.example" }, err: "origin not allowed"},
+		{name: "origin differs only by scheme", format: packedSelf, edit: func(c *ceremony) { c.origin = "http://app.example.com" }, err: "origin not allowed"},
+		{name: "cross origin", format: packedSelf, edit: func(c *ceremony) { c.crossOrigin = true }, err: "origin not allowed"},
+		{name: "assertion client data", format: packedSelf, edit: func(c *ceremony) { c.typ = "webauthn.get" }, err: "wrong client data type"},
+		{name: "credential id mismatch", format: packedSelf, cred: func(a *AttestationCredential) { a.ID = b64([]byte("another-credential")) }, err: "credential id mismatch"},
+		{name: "credential type", format: packedSelf, cred: func(a *AttestationCredential) { a.Type = "password" }, err: "not public-key"},
+		{name: "none with a statement", edit: func(c *ceremony) {
+			c.statement = func([]byte) cborPairs { return cborPairs{{"alg", AlgES256}} }
+		}, err: "none attestation with a statement"},
+		{name: "unsupported format", edit: func(c *ceremony) { c.format = "fido-u2f" }, err: "unsupported attestation format"},
+		{name: "packed self wrong algorithm", format: packedSelf, edit: func(c *ceremony) {
+			c.statement = func(signed []byte) cborPairs {
+				return cborPairs{{"alg", AlgRS256}, {"sig", sign(c.t, c.key, signed)}}
+			}
+		}, err: "self attestation algorithm mismatch"},
+		{name: "packed self bad signature", format: packedSelf, edit: func(c *ceremony) {
+			c.statement = func(signed []byte) cborPairs {
+				return cborPairs{{"alg", AlgES256}, {"sig", sign(c.t, c.key, append(signed, 0))}}
+			}
+		}, err: errSignature.Error()},
+		{name: "packed x5c signed by another key", format: packedX5C(ca), edit: func(c *ceremony) {
+			c.statement = func(signed []byte) cborPairs {
+				return cborPairs{{"alg", AlgES256}, {"sig", sign(c.t, other.key, signed)}, {"x5c", []any{ca.cert}}}
+			}
+		}, err: errSignature.Error()},
+		{name: "packed x5c empty", format: packedX5C(ca), edit: func(c *ceremony) {
+			c.statement = func(signed []byte) cborPairs {
+				return cborPairs{{"alg", AlgES256}, {"sig", sign(c.t, ca.key, signed)}, {"x5c", []any{}}}
+			}
+		}, err: "empty x5c"},
+		{name: "packed x5c not a certificate", format: packedX5C(ca), edit: func(c *ceremony) {
+			c.statement = func(signed []byte) cborPairs {
+				return cborPairs{{"alg", AlgES256}, {"sig", sign(c.t, ca.key, signed)}, {"x5c", []any{[]byte("not DER")}}}
+			}
+		}, err: "x509"},
+		{name: "truncated attestation object", format: packedSelf, edit: func(c *ceremony) {
+			c.tamper = func(b []byte) []byte { return b[:len(b)-10] }
+		}, err: "attestation object is not CBOR"},
+		{name: "trailing bytes after attestation object", format: packedSelf, edit: func(c *ceremony) {
+			c.tamper = func(b []byte) []byte { return append(b, 0x00) }
+		}, err: "attestation object is not CBOR"},
+		{name: "oversized attestation object length", format: packedSelf, edit: func(c *ceremony) {
+			c.tamper = func(b []byte) []byte {
+				return append([]byte{0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, b[1:]...)
+			}
+		}, err: "attestation object is not CBOR"},
+		{name: "duplicate fmt key", edit: func(c *ceremony) { c.extra = cborPairs{{"fmt", "packed"}} }, err: "attestation object is not CBOR"},
+		{name: "duplicate authData key", format: packedSelf, edit: func(c *ceremony) {
+			c.extra = cborPairs{{"authData", c.authData(true)}}
+		}, err: "attestation object is not CBOR"},
+		{name: "attested data flag clear", format: packedSelf, edit: func(c *ceremony) {
+			c.tamper = func(b []byte) []byte {
+				i := bytes.Index(b, c.authData(true))
+				b[i+32] &^= flagAttested
+				return b
+			}
+		}, err: "no attested credential data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCeremony(t, "webauthn.create")
+			challenge := c.challenge
+			if tt.format != nil {
+				tt.format(c)
+			}
+			if tt.edit != nil {
+				tt.edit(c)
+			}
+			resp := c.registration()
+			if tt.cred != nil {
+				tt.cred(&resp)
+			}
+			got, err := testRP.VerifyRegistration(challenge, resp)
+			if tt.err != "" {
+				if !errors.Is(err, ErrVerification) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %v containing %q", err, ErrVerification, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyRegistration: %v", err)
+			}
+			if !bytes.Equal(got.ID, c.credID) || !bytes.Equal(got.AAGUID, c.aaguid) || got.SignCount != c.count {
+				t.Fatalf("credential = %+v", got)
+			}
+			if !bytes.Equal(got.PublicKey, coseES256(&c.key.PublicKey)) {
+				t.Fatalf("public key = %x", got.PublicKey)
+			}
+			if !got.BackupEligible || !got.BackedUp || !slices.Equal(got.Transports, []string{"internal", "hybrid"}) {
+				t.Fatalf("credential = %+v", got)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	handle := []byte("user-handle-42")
+
+	tests := []struct {
+		name   string
+		edit   func(*ceremony)
+		cred   func(*AssertionCredential)
+		handle []byte
+		err    string
+	}{
+		{name: "valid"},
+		{name: "valid without user handle", cred: func(a *AssertionCredential) { a.Response.UserHandle = "" }},
+		{name: "counter reported", edit: func(c *ceremony) { c.count = 42 }},
+
+		{name: "rp id hash mismatch", edit: func(c *ceremony) { c.rpID = "evil.example" }, err: "rp id hash mismatch"},
+		{name: "user present clear", edit: func(c *ceremony) { c.flags &^= flagUserPresent }, err: "user not present or not verified"},
+		{name: "user verified clear", edit: func(c *ceremony) { c.flags &^= flagUserVerified }, err: "user not present or not verified"},
+		{name: "wrong challenge", edit: func(c *ceremony) { c.challenge = Challenge() }, err: "challenge mismatch"},
+		{name: "wrong origin", edit: func(c *ceremony) { c.origin = "https://app.example.com.evil.example" }, err: "origin not allowed"},
+		{name: "registration client data", edit: func(c *ceremony) { c.typ = "webauthn.create" }, err: "wrong client data type"},
+		{name: "client data not JSON", cred: func(a *AssertionCredential) { a.Response.ClientDataJSON = b64([]byte("{")) }, err: "client data is not JSON"},
+		{name: "user handle mismatch", handle: []byte("someone-else"), err: "user handle mismatch"},
+		{name: "signature over other data", edit: func(c *ceremony) { c.resign = []byte("other") }, err: errSignature.Error()},
+		{name: "signature not base64url", cred: func(a *AssertionCredential) { a.Response.Signature = "!!" }, err: "signature is not base64url"},
+		{name: "truncated authenticator data", edit: func(c *ceremony) {
+			c.tamper = func(b []byte) []byte { return b[:36] }
+		}, err: "authenticator data too short"},
+		{name: "attested flag without credential data", edit: func(c *ceremony) {
+			c.tamper = func(b []byte) []byte {
+				b[32] |= flagAttested
+				return b
+			}
+		}, err: "attested credential data too short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCeremony(t, "webauthn.get")
+			challenge := c.challenge
+			if tt.edit != nil {
+				tt.edit(c)
+			}
+			resp := c.assertion(handle)
+			if tt.cred != nil {
+				tt.cred(&resp)
+			}
+			want := handle
+			if tt.handle != nil {
+				want = tt.handle
+			}
+			got, err := testRP.VerifyAssertion(challenge, coseES256(&c.key.PublicKey), b64(want), resp)
+			if tt.err != "" {
+				if !errors.Is(err, ErrVerification) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %v containing %q", err, ErrVerification, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyAssertion: %v", err)
+			}
+			if got.SignCount != c.count || !got.BackedUp {
+				t.Fatalf("assertion = %+v", got)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionWrongKey(t *testing.T) {
+	c := newCeremony(t, "webauthn.get")
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, err := testRP.VerifyAssertion(c.challenge, coseES256(&other.PublicKey), "", c.assertion(nil))
+	if !errors.Is(err, ErrVerification) {
+		t.Fatalf("err = %v, want %v", err, ErrVerification)
+	}
+}
+
+func TestSignCountValid(t *testing.T) {
+	tests := []struct {
+		stored, got uint32
+		want        bool
+	}{
+		{0, 0, true},
+		{0, 1, true},
+		{5, 6, true},
+		{5, 1000, true},
+		{5, 5, false},
+		{5, 4, false},
+		{5, 0, false},
+		{1, 0, false},
+	}
+	for _, tt := range tests {
+		if got := SignCountValid(tt.stored, tt.got); got != tt.want {
+			t.Errorf("SignCountValid(%d, %d) = %v, want %v", tt.stored, tt.got, got, tt.want)
+		}
+	}
+}
+
+func TestClientChallenge(t *testing.T) {
+	c := newCeremony(t, "webauthn.get")
+	got, err := ClientChallenge(b64(c.clientDataJSON()))
+	if err != nil || got != c.challenge {
+		t.Fatalf("ClientChallenge = %q, %v; want %q", got, err, c.challenge)
+	}
+	for _, raw := range []string{"!!", b64([]byte("{}")), b64([]byte("[]"))} {
+		if _, err := ClientChallenge(raw); !errors.Is(err, ErrVerification) {
+			t.Errorf("ClientChallenge(%q): err = %v", raw, err)
+		}
+	}
+}
@@ -2,24 +2,49 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	_ "workshop-be/internal/docs" // swagger docs generated by swag
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/swagger"
 	_ "github.com/gofiber/swagger" // swagger handler
 
 	"github.com/joho/godotenv"
 
+	"workshop-be/internal/audit"
 	"workshop-be/internal/auth"
+	"workshop-be/internal/avatar"
+	"workshop-be/internal/card"
+	"workshop-be/internal/clientinfo"
+	"workshop-be/internal/consent"
 	"workshop-be/internal/db"
+	"workshop-be/internal/events"
+	"workshop-be/internal/export"
+	"workshop-be/internal/geoip"
+	"workshop-be/internal/health"
+	"workshop-be/internal/httpx"
+	"workshop-be/internal/logging"
+	"workshop-be/internal/mailer"
+	"workshop-be/internal/metrics"
 	"workshop-be/internal/middleware"
+	"workshop-be/internal/oauth"
+	"workshop-be/internal/points"
+	"workshop-be/internal/referral"
+	"workshop-be/internal/social"
+	"workshop-be/internal/staff"
+	"workshop-be/internal/tracing"
+	"workshop-be/internal/webauthn"
+	"workshop-be/pkg/blob"
+	"workshop-be/pkg/password"
 )
 
 // @title Workshop BE API
@@ -30,14 +55,38 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
 func main() {
 	_ = godotenv.Load() // load .env if present
+	logging.Setup()
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		logging.Fatal("tracing setup failed", "err", err)
+	}
 
-	app := fiber.New()
-	app.Use(logger.New())
+	app := fiber.New(fiber.Config{
+		ErrorHandler:          httpx.ErrorHandler,
+		DisableStartupMessage: true,
+	})
+	app.Use(middleware.RequestID())
+	app.Use(tracing.Middleware())
+	app.Use(clientinfo.Middleware())
+	app.Use(middleware.AccessLog())
+	app.Use(metrics.Middleware())
 
 	// Health
-	app.Get("/healthz", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	checker := health.NewChecker(2 * time.Second)
+	health.RegisterRoutes(app, checker)
+
+	// Prometheus metrics; METRICS_TOKEN makes scrapers authenticate.
+	metricsToken := os.Getenv("METRICS_TOKEN")
+	if metricsToken == "" && os.Getenv("APP_ENV") == "prod" {
+		slog.Warn("METRICS_TOKEN not set, /metrics is open to anyone who can reach the server")
+	}
+	app.Get("/metrics", metrics.Handler(metricsToken))
 
 	// Root
 	app.Get("/", func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"message": "hello world"}) })
@@ -46,14 +95,117 @@ func main() {
 	dbPath := os.Getenv("DB_PATH")
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
-		log.Println("warning: JWT_SECRET not set, using insecure default (dev only)")
+		slog.Warn("JWT_SECRET not set, using insecure default (dev only)")
 		os.Setenv("JWT_SECRET", "insecure-dev-secret-change-me")
 	}
 	// init database
-	db.Init(dbPath, &auth.User{})
+	db.Init(dbPath, &auth.User{}, &audit.Event{}, &export.Export{}, &consent.Document{}, &consent.Record{}, &auth.EmailChange{}, &points.Transaction{}, &points.Campaign{}, &referral.Referral{}, &referral.Device{}, &staff.Purchase{}, &auth.Identity{}, &auth.OAuthState{}, &auth.MagicLink{}, &auth.Passkey{}, &auth.PasskeyChallenge{}, &auth.APIKey{}, &auth.LoginEvent{}, &auth.StepUpChallenge{}, &oauth.Client{}, &oauth.AuthCode{}, &oauth.Grant{})
+	if err := audit.InstallGuards(db.MustGet()); err != nil {
+		logging.Fatal("install audit guards", "err", err)
+	}
+	if err := db.MustGet().Use(metrics.GormPlugin{}); err != nil {
+		logging.Fatal("register gorm metrics", "err", err)
+	}
+	if err := db.MustGet().Use(tracing.GormPlugin{}); err != nil {
+		logging.Fatal("register gorm tracing", "err", err)
+	}
+
+	// Readiness checks
+	checker.Add("database", db.Ping)
+	// Checked per probe so a database file replaced or migrated by another
+	// build is caught after startup too.
+	checker.Add("migrations", func(ctx context.Context) error {
+		pending, err := db.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("pending: %s", strings.Join(pending, ", "))
+		}
+		return nil
+	})
+	checker.Add("key_material", func(ctx context.Context) error { return auth.CheckKeyMaterial() })
+
+	// Uploaded media (avatars) on local disk, served under MEDIA_BASE_URL when it is a path.
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "data/media"
+	}
+	mediaBase := os.Getenv("MEDIA_BASE_URL")
+	if mediaBase == "" {
+		mediaBase = "/media"
+	}
+	mediaStore := blob.NewLocal(mediaDir, mediaBase)
+	if strings.HasPrefix(mediaBase, "/") {
+		app.Static(mediaBase, mediaDir, fiber.Static{MaxAge: 86400})
+	}
+
+	// Domain events; the campaign engine awards points for auth events.
+	bus := events.NewBus()
+	pointsSvc := points.NewService(bus)
+	campaigns := points.NewEngine(pointsSvc)
+	bus.Subscribe(campaigns.Handle)
+	if err := campaigns.SeedDefaults(context.Background()); err != nil {
+		logging.Fatal("seed campaigns", "err", err)
+	}
+	referralSvc := referral.NewService(pointsSvc)
+	bus.Subscribe(referralSvc.Handle)
 
 	// Auth routes
 	authSvc := auth.NewService()
+	authSvc.Events = bus
+	authSvc.Referrals = referralSvc
+	authSvc.DeletionGrace = envDuration("DELETION_GRACE_PERIOD", authSvc.DeletionGrace)
+	authSvc.Avatars = avatar.NewService(mediaStore)
+	authSvc.Mailer = newMailer()
+	if v := os.Getenv("APP_BASE_URL"); v != "" {
+		authSvc.AppBaseURL = v
+		referralSvc.AppBaseURL = v
+	}
+	authSvc.MagicLinkTTL = envDuration("MAGIC_LINK_TTL", authSvc.MagicLinkTTL)
+	authSvc.Social = newSocialProviders()
+	if v := os.Getenv("OAUTH_REDIRECT_BASE_URL"); v != "" {
+		authSvc.SocialRedirectBase = v
+	}
+	authSvc.WebAuthn = newRelyingParty(authSvc.AppBaseURL)
+	authSvc.Hasher = newPasswordHasher()
+	authSvc.PasswordPolicy = newPasswordPolicy(authSvc.Hasher)
+	authSvc.ConcealExistingAccounts = strings.EqualFold(os.Getenv("REGISTER_CONCEAL_EXISTING"), "true")
+	if path := os.Getenv("GEOIP_DB"); path != "" {
+		geo, err := geoip.Open(path)
+		if err != nil {
+			logging.Fatal("open geoip database", "path", path, "err", err)
+		}
+		defer geo.Close()
+		authSvc.GeoIP = geo
+	}
+	authSvc.LoginStepUp = strings.EqualFold(os.Getenv("LOGIN_STEP_UP"), "true")
+	if err := authSvc.CalibratePasswordDummy(context.Background()); err != nil {
+		slog.Warn("password dummy calibration failed, using current settings", "err", err)
+	}
+	if n, err := authSvc.NormalizeEmails(context.Background()); err != nil {
+		logging.Fatal("normalize stored emails", "err", err)
+	} else if n > 0 {
+		slog.Info("lowercased stored emails", "count", n)
+	}
+	if emails := splitList(os.Getenv("ADMIN_EMAILS")); len(emails) > 0 {
+		n, err := authSvc.PromoteAdmins(context.Background(), emails)
+		if err != nil {
+			logging.Fatal("promote admins", "err", err)
+		}
+		if n > 0 {
+			slog.Info("granted admin role", "count", n)
+		}
+	}
+	if emails := splitList(os.Getenv("STAFF_EMAILS")); len(emails) > 0 {
+		n, err := authSvc.PromoteStaff(context.Background(), emails)
+		if err != nil {
+			logging.Fatal("promote staff", "err", err)
+		}
+		if n > 0 {
+			slog.Info("granted staff role", "count", n)
+		}
+	}
 	authGroup := app.Group("/api/v1/auth")
 	auth.RegisterRoutes(authGroup, authSvc)
 	authHandler := auth.NewHandler(authSvc)
@@ -65,6 +217,88 @@ func main() {
 	profileHandler := auth.NewHandler(authSvc)
 	profileGroup.Get("/", profileHandler.GetProfile)
 	profileGroup.Put("/", profileHandler.UpdateProfile)
+	profileGroup.Put("/password", profileHandler.ChangePassword)
+	profileGroup.Delete("/", profileHandler.DeleteAccount)
+	profileGroup.Put("/avatar", profileHandler.UploadAvatar)
+	profileGroup.Post("/email", profileHandler.RequestEmailChange)
+	profileGroup.Post("/reauth", profileHandler.StartReauth)
+	profileGroup.Get("/identities", profileHandler.ListIdentities)
+	profileGroup.Delete("/identities/:provider", profileHandler.UnlinkIdentity)
+	profileGroup.Get("/passkeys", profileHandler.ListPasskeys)
+	profileGroup.Post("/passkeys/options", profileHandler.PasskeyCreationOptions)
+	profileGroup.Post("/passkeys", profileHandler.RegisterPasskey)
+	profileGroup.Delete("/passkeys/:id", profileHandler.DeletePasskey)
+	profileGroup.Get("/logins", profileHandler.ListLogins)
+	profileGroup.Get("/api-keys", profileHandler.ListAPIKeys)
+	profileGroup.Post("/api-keys", profileHandler.CreateAPIKey)
+	profileGroup.Delete("/api-keys/:id", profileHandler.RevokeAPIKey)
+
+	// Legal documents and consents
+	consentSvc := consent.NewService()
+	consent.RegisterPublicRoutes(app.Group("/api/v1"), consentSvc)
+	consent.RegisterProfileRoutes(profileGroup, consentSvc)
+
+	// Points balance, ledger and referrals
+	points.RegisterProfileRoutes(profileGroup, pointsSvc)
+	referral.RegisterProfileRoutes(profileGroup, referralSvc)
+
+	// Digital membership card; store staff verify payloads server-side.
+	cardSvc := card.NewService(auth.DeriveKey("membership-card"))
+	cardSvc.TTL = envDuration("CARD_TTL", cardSvc.TTL)
+	card.RegisterProfileRoutes(profileGroup, cardSvc)
+	card.RegisterVerifyRoutes(app.Group("/api/v1/cards", middleware.AuthRequired(auth.ScopeCardsVerify), middleware.RequireRole(auth.RoleStaff, auth.RoleAdmin)), cardSvc)
+
+	// Staff / POS: credit purchases to a scanned card
+	staffSvc := staff.NewService(pointsSvc, cardSvc)
+	staff.RegisterRoutes(app.Group("/api/v1/staff", middleware.AuthRequired(auth.ScopePointsEarn), middleware.RequireRole(auth.RoleStaff, auth.RoleAdmin)), staffSvc)
+
+	// Personal data export (sections in archive order)
+	auditSvc := audit.NewService()
+	exportSvc := export.NewService(os.Getenv("EXPORT_DIR"), auth.DeriveKey("data-export"))
+	exportSvc.Retention = envDuration("EXPORT_RETENTION", exportSvc.Retention)
+	exportSvc.AddSource("account", func(ctx context.Context, uid uint) (any, error) { return authSvc.Account(ctx, uid) })
+	exportSvc.AddSource("profile", func(ctx context.Context, uid uint) (any, error) { return authSvc.GetProfile(ctx, uid) })
+	exportSvc.AddSource("identities", func(ctx context.Context, uid uint) (any, error) { return authSvc.Identities(ctx, uid) })
+	exportSvc.AddSource("passkeys", func(ctx context.Context, uid uint) (any, error) { return authSvc.Passkeys(ctx, uid) })
+	exportSvc.AddSource("login_history", func(ctx context.Context, uid uint) (any, error) { return authSvc.LoginHistory(ctx, uid, 0) })
+	exportSvc.AddSource("consents", func(ctx context.Context, uid uint) (any, error) { return consentSvc.History(ctx, uid) })
+	exportSvc.AddSource("points_history", func(ctx context.Context, uid uint) (any, error) { return pointsSvc.All(ctx, uid) })
+	exportSvc.AddSource("referrals", func(ctx context.Context, uid uint) (any, error) { return referralSvc.All(ctx, uid) })
+	exportSvc.AddSource("activity", func(ctx context.Context, uid uint) (any, error) { return auditSvc.ForUser(ctx, uid) })
+	authSvc.OnPurge(exportSvc.Forget)
+	export.RegisterProfileRoutes(profileGroup, exportSvc)
+	export.RegisterDownloadRoutes(app.Group("/api/v1"), exportSvc)
+
+	// Admin routes (protected, admin role)
+	adminGroup := app.Group("/api/v1/admin", middleware.AuthRequired(auth.ScopeAdmin), middleware.RequireRole(auth.RoleAdmin))
+	audit.RegisterAdminRoutes(adminGroup, auditSvc)
+	consent.RegisterAdminRoutes(adminGroup, consentSvc)
+	points.RegisterAdminRoutes(adminGroup, campaigns)
+	auth.RegisterAdminRoutes(adminGroup, authSvc)
+
+	// OpenID Connect provider for other company apps; enabled by OIDC_ISSUER.
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		keyFile := os.Getenv("OIDC_SIGNING_KEY_FILE")
+		if keyFile == "" {
+			if os.Getenv("APP_ENV") == "prod" {
+				logging.Fatal("OIDC_SIGNING_KEY_FILE is required when OIDC_ISSUER is set in production")
+			}
+			slog.Warn("OIDC_SIGNING_KEY_FILE not set, using a temporary signing key (dev only)")
+		}
+		key, err := oauth.LoadKey(keyFile)
+		if err != nil {
+			logging.Fatal("load oidc signing key", "err", err)
+		}
+		oauthSvc := oauth.NewService(issuer, key, authSvc)
+		oauthSvc.ConsentURL = strings.TrimSuffix(authSvc.AppBaseURL, "/") + "/oauth/consent"
+		oauthSvc.AccessTTL = envDuration("OIDC_ACCESS_TOKEN_TTL", oauthSvc.AccessTTL)
+		oauth.RegisterProtocolRoutes(app, oauthSvc)
+		oauth.RegisterConsentRoutes(app.Group("/api/v1/oauth", middleware.AuthRequired()), oauthSvc)
+		oauth.RegisterProfileRoutes(profileGroup, oauthSvc)
+		oauth.RegisterAdminRoutes(adminGroup, oauthSvc)
+		exportSvc.AddSource("connected_apps", func(ctx context.Context, uid uint) (any, error) { return oauthSvc.Grants(ctx, uid) })
+		authSvc.OnPurge(oauthSvc.Forget)
+	}
 
 	// Swagger endpoint
 	app.Get("/swagger/*", swagger.HandlerDefault)
@@ -75,23 +309,192 @@ func main() {
 	}
 
 	go func() {
+		slog.Info("server listening", "port", port)
 		if err := app.Listen(":" + port); err != nil {
-			log.Printf("shutting down server: %v\n", err)
+			slog.Info("shutting down server", "err", err)
 		}
 	}()
 
+	// Background jobs stop when jobsCtx is cancelled at shutdown.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go authSvc.RunPurger(jobsCtx, envDuration("PURGE_INTERVAL", time.Hour))
+	go exportSvc.Run(jobsCtx, 30*time.Second)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Gracefully shutting down...")
+	slog.Info("gracefully shutting down")
+	// Fail readiness first so load balancers drain traffic before we stop accepting it.
+	checker.StartDraining()
+	stopJobs()
+	time.Sleep(envDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second))
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := app.Shutdown(); err != nil {
-		log.Printf("error on shutdown: %v", err)
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		slog.Error("error on shutdown", "err", err)
 	}
+	authSvc.Wait()
 	if err := db.Close(); err != nil {
-		log.Printf("error closing db: %v", err)
+		slog.Error("error closing db", "err", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("error flushing traces", "err", err)
+	}
+	slog.Info("server stopped")
+}
+
+// newMailer uses SMTP when SMTP_ADDR is set; otherwise emails are only logged.
+func newMailer() mailer.Mailer {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		if os.Getenv("APP_ENV") == "prod" {
+			slog.Warn("SMTP_ADDR not set, emails are logged instead of sent")
+		}
+		return mailer.NewLog()
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	return mailer.NewSMTP(addr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+}
+
+// splitList parses a comma-separated env value, dropping blanks.
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// newPasswordHasher picks the algorithm for new password hashes
+// (PASSWORD_HASH, argon2id by default) and its cost. Existing hashes are
+// upgraded to these settings as users log in. PASSWORD_HASH_WORKERS bounds
+// concurrent hashing (default half the CPUs); up to PASSWORD_HASH_QUEUE
+// more wait at most PASSWORD_HASH_MAX_WAIT before getting 503.
+func newPasswordHasher() *password.Hasher {
+	h := password.DefaultHasher()
+	if v := os.Getenv("PASSWORD_HASH"); v != "" {
+		h.Algorithm = v
+	}
+	h.BcryptCost = envInt("PASSWORD_BCRYPT_COST", h.BcryptCost)
+	h.Argon2.Memory = uint32(envInt("PASSWORD_ARGON2_MEMORY_KIB", int(h.Argon2.Memory)))
+	h.Argon2.Iterations = uint32(envInt("PASSWORD_ARGON2_ITERATIONS", int(h.Argon2.Iterations)))
+	lanes := envInt("PASSWORD_ARGON2_PARALLELISM", int(h.Argon2.Parallelism))
+	if lanes > 255 {
+		logging.Fatal("PASSWORD_ARGON2_PARALLELISM must be at most 255", "value", lanes)
+	}
+	h.Argon2.Parallelism = uint8(lanes)
+	if err := h.Validate(); err != nil {
+		logging.Fatal("invalid password hash settings", "err", err)
+	}
+	h.Pool = password.NewPool(
+		envInt("PASSWORD_HASH_WORKERS", password.DefaultWorkers()),
+		envInt("PASSWORD_HASH_QUEUE", 64),
+		envDuration("PASSWORD_HASH_MAX_WAIT", 2*time.Second),
+	)
+	metrics.WatchPasswordPool(h.Pool.Stats)
+	return h
+}
+
+// newPasswordPolicy applies PASSWORD_* overrides to the default policy.
+// PASSWORD_BREACH_API enables the k-anonymity breached-password check,
+// e.g. https://api.pwnedpasswords.com/range.
+func newPasswordPolicy(h *password.Hasher) *password.Policy {
+	p := password.DefaultPolicy()
+	p.MaxBytes = h.MaxBytes()
+	p.MinLength = envInt("PASSWORD_MIN_LENGTH", p.MinLength)
+	p.MaxLength = envInt("PASSWORD_MAX_LENGTH", p.MaxLength)
+	for _, class := range splitList(os.Getenv("PASSWORD_REQUIRE")) {
+		if !password.ValidClass(class) {
+			logging.Fatal("unknown PASSWORD_REQUIRE class", "class", class)
+		}
+		p.Require = append(p.Require, class)
+	}
+	if p.MinLength < 1 || (p.MaxLength > 0 && p.MaxLength < p.MinLength) {
+		logging.Fatal("invalid password length limits", "min", p.MinLength, "max", p.MaxLength)
+	}
+	if u := os.Getenv("PASSWORD_BREACH_API"); u != "" {
+		p.Breach = password.NewRangeChecker(u)
+	}
+	return p
+}
+
+// newRelyingParty configures passkeys. The RP ID defaults to the host of
+// the frontend and the allowed origins to the frontend itself; native apps
+// add their origins (e.g. android:apk-key-hash:...) to WEBAUTHN_ORIGINS.
+func newRelyingParty(appBaseURL string) *webauthn.RelyingParty {
+	origin := strings.TrimSuffix(appBaseURL, "/")
+	rp := &webauthn.RelyingParty{ID: os.Getenv("WEBAUTHN_RP_ID"), Name: os.Getenv("WEBAUTHN_RP_NAME"), Origins: splitList(os.Getenv("WEBAUTHN_ORIGINS"))}
+	if rp.ID == "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Hostname() == "" {
+			logging.Fatal("WEBAUTHN_RP_ID is required when APP_BASE_URL has no host", "app_base_url", appBaseURL)
+		}
+		rp.ID = u.Hostname()
+	}
+	if rp.Name == "" {
+		rp.Name = "Workshop"
+	}
+	if len(rp.Origins) == 0 {
+		rp.Origins = []string{origin}
+	}
+	return rp
+}
+
+// newSocialProviders enables each social login provider whose client
+// credentials are set. OAUTH_MOCK_ISSUER adds a provider named "mock" for
+// local testing against cmd/mockoidc.
+func newSocialProviders() *social.Registry {
+	reg := social.NewRegistry()
+	if id := os.Getenv("OAUTH_GOOGLE_CLIENT_ID"); id != "" {
+		reg.Add(social.NewOIDC(social.OIDCConfig{Name: "google", Issuer: "https://accounts.google.com", ClientID: id, ClientSecret: os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET")}))
+	}
+	if id := os.Getenv("OAUTH_LINE_CLIENT_ID"); id != "" {
+		reg.Add(social.NewOIDC(social.OIDCConfig{Name: "line", Issuer: "https://access.line.me", ClientID: id, ClientSecret: os.Getenv("OAUTH_LINE_CLIENT_SECRET"), TrustEmail: true, AllowHS256: true}))
+	}
+	if id := os.Getenv("OAUTH_FACEBOOK_CLIENT_ID"); id != "" {
+		reg.Add(social.NewFacebook(id, os.Getenv("OAUTH_FACEBOOK_CLIENT_SECRET")))
+	}
+	if iss := os.Getenv("OAUTH_MOCK_ISSUER"); iss != "" {
+		if os.Getenv("APP_ENV") == "prod" {
+			logging.Fatal("OAUTH_MOCK_ISSUER must not be set in production")
+		}
+		reg.Add(social.NewOIDC(social.OIDCConfig{Name: "mock", Issuer: iss, ClientID: os.Getenv("OAUTH_MOCK_CLIENT_ID"), ClientSecret: os.Getenv("OAUTH_MOCK_CLIENT_SECRET")}))
+	}
+	if names := reg.Names(); len(names) > 0 {
+		slog.Info("social login enabled", "providers", strings.Join(names, ","))
+	}
+	return reg
+}
+
+// envInt parses a non-negative integer from the environment, falling back to def.
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		slog.Warn("invalid integer, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// envDuration parses a Go duration from the environment, falling back to def.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		slog.Warn("invalid duration, using default", "key", key, "value", v, "default", def.String())
+		return def
 	}
-	_ = ctx
-	log.Println("Server stopped")
+	return d
 }
@@ -0,0 +1,36 @@
+// Package blob abstracts object storage for user-uploaded files so the
+// backing store (local disk, S3-compatible, ...) can be swapped in main.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Store persists objects under slash-separated keys such as
+// "avatars/12/3f9c-256.jpg".
+type Store interface {
+	// Put writes r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL clients use to fetch key.
+	URL(key string) string
+}
+
+// ValidKey rejects empty keys, absolute paths and traversal segments.
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
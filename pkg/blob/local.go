@@ -0,0 +1,69 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores objects as files under a directory. Objects are served by the
+// HTTP server itself (see main), so URL joins baseURL and the key.
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal stores files under dir and builds URLs from baseURL, which may be
+// a path ("/media") or an absolute URL in front of a CDN.
+func NewLocal(dir, baseURL string) *Local {
+	return &Local{dir: dir, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Dir is the root directory, for mounting a static file handler.
+func (l *Local) Dir() string { return l.dir }
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	path := filepath.Join(l.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temp file and rename so readers never see a partial object.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(l.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) URL(key string) string {
+	return l.baseURL + "/" + key
+}
@@ -0,0 +1,63 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BreachChecker reports whether a password is known from data breaches.
+type BreachChecker interface {
+	Breached(ctx context.Context, pw string) (bool, error)
+}
+
+// RangeChecker queries a k-anonymity range API such as Have I Been Pwned's
+// Pwned Passwords: only the first 5 hex characters of the password's SHA-1
+// leave this service, and the match is done locally against the returned
+// suffixes.
+type RangeChecker struct {
+	// BaseURL is the range endpoint; the prefix is appended, e.g.
+	// https://api.pwnedpasswords.com/range/ + ABCDE.
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewRangeChecker(baseURL string) *RangeChecker {
+	return &RangeChecker{BaseURL: strings.TrimSuffix(baseURL, "/") + "/", Client: &http.Client{Timeout: 3 * time.Second}}
+}
+
+func (c *RangeChecker) Breached(ctx context.Context, pw string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "password.RangeChecker.Breached")
+	defer span.End()
+	sum := sha1.Sum([]byte(pw))
+	h := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := h[:5], h[5:]
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides the real number of matches from anyone watching the response size.
+	req.Header.Set("Add-Padding", "true")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("breach range api: status %d", resp.StatusCode)
+	}
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		// Lines are SUFFIX:COUNT; padding entries have a count of 0.
+		s, count, _ := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if strings.EqualFold(s, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
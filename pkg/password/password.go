@@ -1,24 +1,361 @@
 package password
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-const cost = 12
+// Hash algorithms.
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+	// bcryptMaxBytes is the longest password bcrypt accepts.
+	bcryptMaxBytes = 72
+	dummyPassword  = "dummy password for timing"
+)
+
+var tracer = otel.Tracer("workshop-be/pkg/password")
+
+var errMalformedHash = errors.New("malformed password hash")
+
+// Argon2Params are the argon2id cost settings.
+type Argon2Params struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Hasher hashes new passwords with Algorithm and verifies hashes made by
+// either algorithm, so stored hashes can be upgraded one login at a time.
+// Argon2id hashes use the PHC string format
+// ($argon2id$v=19$m=19456,t=2,p=1$salt$hash); bcrypt hashes keep their
+// $2a$/$2b$ format, and $2y$ hashes from PHP verify as $2b$.
+//
+// Settings must not change once the Hasher is in use.
+type Hasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+	// Pool limits concurrent hashing; nil means no limit.
+	Pool *Pool
+
+	dummyMu sync.Mutex
+	dummy   string
+	// dummyCost is a moving average of how long checking dummy takes, in
+	// nanoseconds; failed checks are padded to it.
+	dummyCost atomic.Int64
+}
+
+// DefaultHasher uses argon2id with the OWASP baseline of 19 MiB, 2 passes
+// and 1 lane.
+func DefaultHasher() *Hasher {
+	return &Hasher{
+		Algorithm:  Argon2id,
+		BcryptCost: 12,
+		Argon2:     Argon2Params{Memory: 19 * 1024, Iterations: 2, Parallelism: 1},
+	}
+}
+
+// Validate reports settings the algorithm cannot use.
+func (h *Hasher) Validate() error {
+	switch h.Algorithm {
+	case Bcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be %d-%d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		p := h.Argon2
+		if p.Iterations < 1 || p.Parallelism < 1 || p.Memory < 8*uint32(p.Parallelism) {
+			return errors.New("argon2id needs at least 1 iteration, 1 lane and 8 KiB of memory per lane")
+		}
+	default:
+		return fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+	return nil
+}
+
+// MaxBytes is the longest password Hash accepts, or 0 for no limit.
+func (h *Hasher) MaxBytes() int {
+	if h.Algorithm == Bcrypt {
+		return bcryptMaxBytes
+	}
+	return 0
+}
 
-func Hash(pw string) (string, error) {
+// Hash returns ErrBusy when the Pool is saturated.
+func (h *Hasher) Hash(ctx context.Context, pw string) (string, error) {
+	ctx, span := tracer.Start(ctx, "password.Hash")
+	defer span.End()
 	if len(pw) == 0 {
 		return "", errors.New("empty password")
 	}
-	h, err := bcrypt.GenerateFromPassword([]byte(pw), cost)
-	return string(h), err
+	if err := h.Pool.acquire(ctx); err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	defer h.Pool.release()
+	out, err := h.hash(pw)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return out, err
+}
+
+func (h *Hasher) hash(pw string) (string, error) {
+	switch h.Algorithm {
+	case Bcrypt:
+		b, err := bcrypt.GenerateFromPassword([]byte(pw), h.BcryptCost)
+		return string(b), err
+	case Argon2id:
+		return hashArgon2(pw, h.Argon2, argon2KeyLen), nil
+	}
+	return "", fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+}
+
+func hashArgon2(pw string, p Argon2Params, keyLen uint32) string {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	key := argon2.IDKey([]byte(pw), salt, p.Iterations, p.Memory, p.Parallelism, keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// Verify reports whether pw matches hash and, when it does, whether hash
+// should be replaced because its algorithm or cost settings differ from the
+// Hasher's. err is ErrBusy when the Pool is saturated. A mismatch takes at
+// least as long as VerifyDummy, so an account on a cheaper hash does not
+// stand out from an unknown email.
+func (h *Hasher) Verify(ctx context.Context, hash, pw string) (ok, rehash bool, err error) {
+	ctx, span := tracer.Start(ctx, "password.Verify")
+	defer span.End()
+	if pw == "" {
+		return false, false, nil
+	}
+	if hash == "" {
+		return false, false, h.verifyDummy(ctx, pw)
+	}
+	ok, rehash, took, err := h.check(ctx, hash, pw)
+	if err != nil {
+		span.RecordError(err)
+		return false, false, err
+	}
+	if !ok {
+		h.pad(ctx, took)
+	}
+	return ok, rehash, nil
+}
+
+// check runs verify on a Pool worker and reports how long verify took,
+// without the wait for the worker.
+func (h *Hasher) check(ctx context.Context, hash, pw string) (ok, rehash bool, took time.Duration, err error) {
+	if err := h.Pool.acquire(ctx); err != nil {
+		return false, false, 0, err
+	}
+	defer h.Pool.release()
+	start := time.Now()
+	ok, rehash = h.verify(hash, pw)
+	return ok, rehash, time.Since(start), nil
+}
+
+// pad sleeps until a failed check that took took has lasted as long as a
+// dummy check does on average.
+func (h *Hasher) pad(ctx context.Context, took time.Duration) {
+	wait := time.Duration(h.dummyCost.Load()) - took
+	if wait <= 0 {
+		return
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+func (h *Hasher) verify(hash, pw string) (ok, rehash bool) {
+	if strings.HasPrefix(hash, "$"+Argon2id+"$") {
+		p, salt, key, err := parseArgon2(hash)
+		if err != nil {
+			return false, false
+		}
+		got := argon2.IDKey([]byte(pw), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return false, false
+		}
+		return true, h.Algorithm != Argon2id || p != h.Argon2 || len(key) != argon2KeyLen
+	}
+	if !isBcrypt(hash) {
+		return false, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw)) != nil {
+		return false, false
+	}
+	cost, _ := bcrypt.Cost([]byte(hash))
+	return true, h.Algorithm != Bcrypt || cost != h.BcryptCost
+}
+
+// isBcrypt reports whether hash has a bcrypt prefix this package accepts.
+// The bcrypt package takes any minor version, but $2x$ marks hashes from the
+// crypt_blowfish sign-extension bug and must not match as $2a$.
+func isBcrypt(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyDummy checks pw against a throwaway hash and discards the result.
+// Callers with no hash to check (unknown email, account without a password)
+// use it so they take as long as a failed Verify, and are refused with
+// ErrBusy alike, and neither reveals which accounts exist. The dummy has the
+// current settings until CalibrateDummy picks a slower one.
+func (h *Hasher) VerifyDummy(ctx context.Context, pw string) error {
+	ctx, span := tracer.Start(ctx, "password.Verify")
+	defer span.End()
+	return h.verifyDummy(ctx, pw)
+}
+
+func (h *Hasher) verifyDummy(ctx context.Context, pw string) error {
+	dummy, err := h.dummyHash()
+	if err != nil {
+		return err
+	}
+	_, _, took, err := h.check(ctx, dummy, pw)
+	if err != nil {
+		return err
+	}
+	h.observeDummy(took)
+	h.pad(ctx, took)
+	return nil
+}
+
+func (h *Hasher) dummyHash() (string, error) {
+	h.dummyMu.Lock()
+	defer h.dummyMu.Unlock()
+	if h.dummy == "" {
+		d, err := h.hash(dummyPassword)
+		if err != nil {
+			return "", err
+		}
+		h.dummy = d
+	}
+	return h.dummy, nil
 }
 
-func Verify(hash, pw string) bool {
-	if hash == "" || pw == "" {
-		return false
+// observeDummy folds one dummy check into dummyCost, weighting it 1/8 so the
+// average follows load without jumping on a single slow check. Concurrent
+// updates may overwrite each other, which only slows the average down.
+func (h *Hasher) observeDummy(took time.Duration) {
+	old := h.dummyCost.Load()
+	if old == 0 {
+		h.dummyCost.Store(int64(took))
+		return
+	}
+	h.dummyCost.Store(old + (int64(took)-old)/8)
+}
+
+// CalibrateDummy picks the hash VerifyDummy checks against: whichever of the
+// current settings and the settings of samples is slowest to verify. samples
+// are stored hashes, one per algorithm and cost still in use, so that an
+// unknown email costs as much as the slowest account. Samples this package
+// cannot parse are skipped. It runs outside the Pool; call it at startup.
+func (h *Hasher) CalibrateDummy(samples []string) error {
+	best, err := h.hash(dummyPassword)
+	if err != nil {
+		return err
+	}
+	cost := h.timeVerify(best)
+	for _, sample := range samples {
+		d, ok := dummyLike(sample)
+		if !ok {
+			continue
+		}
+		if c := h.timeVerify(d); c > cost {
+			best, cost = d, c
+		}
+	}
+	h.dummyMu.Lock()
+	h.dummy = best
+	h.dummyMu.Unlock()
+	h.dummyCost.Store(int64(cost))
+	return nil
+}
+
+// dummyLike hashes dummyPassword with the algorithm and cost of hash.
+func dummyLike(hash string) (string, bool) {
+	if strings.HasPrefix(hash, "$"+Argon2id+"$") {
+		p, _, key, err := parseArgon2(hash)
+		if err != nil {
+			return "", false
+		}
+		return hashArgon2(dummyPassword, p, uint32(len(key))), true
+	}
+	if !isBcrypt(hash) {
+		return "", false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return "", false
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(dummyPassword), cost)
+	return string(b), err == nil
+}
+
+// timeVerify measures one failed check of hash.
+func (h *Hasher) timeVerify(hash string) time.Duration {
+	start := time.Now()
+	h.verify(hash, dummyPassword+"?")
+	return time.Since(start)
+}
+
+// parseArgon2 splits a PHC argon2id string into its settings, salt and key.
+func parseArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != Argon2id {
+		return p, nil, nil, errMalformedHash
+	}
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return p, nil, nil, errMalformedHash
+	}
+	// Sscanf stops at the last verb, so the fields must also print back the
+	// same: this rejects trailing text, signs and leading zeros.
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil ||
+		parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism) {
+		return p, nil, nil, errMalformedHash
+	}
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return p, nil, nil, errMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return p, nil, nil, errMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) < 16 {
+		return p, nil, nil, errMalformedHash
 	}
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw)) == nil
+	return p, salt, key, nil
 }
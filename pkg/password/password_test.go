@@ -0,0 +1,294 @@
+package password
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Small settings keep the tests fast; the format does not depend on them.
+var testArgon2 = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func testHasher(alg string) *Hasher {
+	return &Hasher{Algorithm: alg, BcryptCost: bcrypt.MinCost, Argon2: testArgon2}
+}
+
+var phc = regexp.MustCompile(`^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+
+func TestHashVerifyRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, alg := range []string{Argon2id, Bcrypt} {
+		t.Run(alg, func(t *testing.T) {
+			h := testHasher(alg)
+			a, err := h.Hash(ctx, "correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := h.Hash(ctx, "correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a == b {
+				t.Fatal("two hashes of one password are equal; salt not random")
+			}
+			switch alg {
+			case Argon2id:
+				if !phc.MatchString(a) {
+					t.Fatalf("hash %q is not a PHC argon2id string", a)
+				}
+				p, salt, key, err := parseArgon2(a)
+				if err != nil || p != testArgon2 || len(salt) != argon2SaltLen || len(key) != argon2KeyLen {
+					t.Fatalf("parseArgon2(%q) = %+v, %d, %d, %v", a, p, len(salt), len(key), err)
+				}
+			case Bcrypt:
+				if cost, err := bcrypt.Cost([]byte(a)); err != nil || cost != bcrypt.MinCost || !strings.HasPrefix(a, "$2a$") {
+					t.Fatalf("bcrypt hash %q: cost %d, %v", a, cost, err)
+				}
+			}
+			if ok, rehash, err := h.Verify(ctx, a, "correct horse battery staple"); !ok || rehash || err != nil {
+				t.Fatalf("Verify(right) = %v, %v, %v", ok, rehash, err)
+			}
+			for _, pw := range []string{"correct horse battery stapl", "Correct horse battery staple", "correct horse battery staple ", ""} {
+				if ok, rehash, err := h.Verify(ctx, a, pw); ok || rehash || err != nil {
+					t.Fatalf("Verify(%q) = %v, %v, %v", pw, ok, rehash, err)
+				}
+			}
+		})
+	}
+}
+
+func TestHashEmpty(t *testing.T) {
+	if _, err := testHasher(Argon2id).Hash(context.Background(), ""); err == nil {
+		t.Fatal("empty password hashed")
+	}
+}
+
+// Vectors from the reference implementations, so hashes made elsewhere
+// (other services, imports) verify here.
+func TestVerifyReferenceVectors(t *testing.T) {
+	tests := []struct {
+		name, hash, pw string
+	}{
+		// argon2 reference implementation test suite (src/test.c).
+		{"argon2id", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc", "password"},
+		// OpenBSD/Openwall crypt_blowfish test vectors.
+		{"bcrypt 2a", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U"},
+		{"bcrypt 2b", "$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U"},
+		{"bcrypt 2y", "$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U"},
+	}
+	h := DefaultHasher()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, _, err := h.Verify(context.Background(), tt.hash, tt.pw); !ok || err != nil {
+				t.Fatalf("Verify = %v, %v", ok, err)
+			}
+			if ok, _, _ := h.Verify(context.Background(), tt.hash, tt.pw+"x"); ok {
+				t.Fatal("wrong password accepted")
+			}
+		})
+	}
+}
+
+func TestVerifyRehash(t *testing.T) {
+	ctx := context.Background()
+	const pw = "correct horse battery staple"
+	bcryptMin, _ := testHasher(Bcrypt).Hash(ctx, pw)
+	argonTest, _ := testHasher(Argon2id).Hash(ctx, pw)
+	salt := []byte("somesaltsomesalt")
+	shortKey := fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=1$%s$%s", base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte(pw), salt, 1, 64, 1, 16)))
+	with := func(alg string, edit func(*Hasher)) *Hasher {
+		h := testHasher(alg)
+		if edit != nil {
+			edit(h)
+		}
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		hash   string
+		rehash bool
+	}{
+		{"bcrypt to argon2id", with(Argon2id, nil), bcryptMin, true},
+		{"bcrypt same cost", with(Bcrypt, nil), bcryptMin, false},
+		{"bcrypt cost raised", with(Bcrypt, func(h *Hasher) { h.BcryptCost++ }), bcryptMin, true},
+		{"argon2id same params", with(Argon2id, nil), argonTest, false},
+		{"argon2id memory raised", with(Argon2id, func(h *Hasher) { h.Argon2.Memory *= 2 }), argonTest, true},
+		{"argon2id iterations raised", with(Argon2id, func(h *Hasher) { h.Argon2.Iterations++ }), argonTest, true},
+		{"argon2id lanes raised", with(Argon2id, func(h *Hasher) { h.Argon2.Parallelism++ }), argonTest, true},
+		{"argon2id to bcrypt", with(Bcrypt, nil), argonTest, true},
+		{"argon2id short key", with(Argon2id, nil), shortKey, true},
+		// bcrypt settings do not matter to argon2id hashes and vice versa.
+		{"argon2id ignores bcrypt cost", with(Argon2id, func(h *Hasher) { h.BcryptCost++ }), argonTest, false},
+		{"bcrypt ignores argon2 params", with(Bcrypt, func(h *Hasher) { h.Argon2.Memory *= 2 }), bcryptMin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := tt.hasher.Verify(ctx, tt.hash, pw)
+			if !ok || err != nil || rehash != tt.rehash {
+				t.Fatalf("Verify = %v, %v, %v; want true, %v, nil", ok, rehash, err, tt.rehash)
+			}
+			if ok, rehash, _ := tt.hasher.Verify(ctx, tt.hash, "wrong"); ok || rehash {
+				t.Fatalf("wrong password: ok=%v rehash=%v; rehash must only be set on a match", ok, rehash)
+			}
+		})
+	}
+
+}
+
+func TestParseArgon2Malformed(t *testing.T) {
+	const salt, key = "c29tZXNhbHQ", "CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	valid := "$argon2id$v=19$m=65536,t=2,p=1$" + salt + "$" + key
+	if _, _, _, err := parseArgon2(valid); err != nil {
+		t.Fatalf("valid hash rejected: %v", err)
+	}
+	tests := []struct{ name, hash string }{
+		{"empty", ""},
+		{"plain text", "password"},
+		{"too few fields", "$argon2id$v=19$m=65536,t=2,p=1$" + salt},
+		{"too many fields", valid + "$extra"},
+		{"text before prefix", "x" + valid},
+		{"argon2i", "$argon2i$v=19$m=65536,t=2,p=1$" + salt + "$" + key},
+		{"argon2d", "$argon2d$v=19$m=65536,t=2,p=1$" + salt + "$" + key},
+		{"missing version", "$argon2id$m=65536,t=2,p=1$" + salt + "$" + key + "$"},
+		{"old version", "$argon2id$v=16$m=65536,t=2,p=1$" + salt + "$" + key},
+		{"version with trailing text", "$argon2id$v=19x$m=65536,t=2,p=1$" + salt + "$" + key},
+		{"params out of order", "$argon2id$v=19$t=2,m=65536,p=1$" + salt + "$" + key},
+		{"params with trailing text", "$argon2id$v=19$m=65536,t=2,p=1x$" + salt + "$" + key},
+		{"params with spaces", "$argon2id$v=19$m=65536, t=2, p=1$" + salt + "$" + key},
+		{"params with leading zero", "$argon2id$v=19$m=065536,t=2,p=1$" + salt + "$" + key},
+		{"params with sign", "$argon2id$v=19$m=+65536,t=2,p=1$" + salt + "$" + key},
+		{"negative memory", "$argon2id$v=19$m=-1,t=2,p=1$" + salt + "$" + key},
+		{"memory over uint32", "$argon2id$v=19$m=4294967296,t=2,p=1$" + salt + "$" + key},
+		{"lanes over uint8", "$argon2id$v=19$m=65536,t=2,p=256$" + salt + "$" + key},
+		{"zero iterations", "$argon2id$v=19$m=65536,t=0,p=1$" + salt + "$" + key},
+		{"zero lanes", "$argon2id$v=19$m=65536,t=2,p=0$" + salt + "$" + key},
+		{"empty salt", "$argon2id$v=19$m=65536,t=2,p=1$$" + key},
+		{"padded salt", "$argon2id$v=19$m=65536,t=2,p=1$" + salt + "=$" + key},
+		{"salt not base64", "$argon2id$v=19$m=65536,t=2,p=1$s@lt!$" + key},
+		{"key not base64", "$argon2id$v=19$m=65536,t=2,p=1$" + salt + "$" + key[:42] + "*"},
+		{"key too short", "$argon2id$v=19$m=65536,t=2,p=1$" + salt + "$" + key[:20]},
+	}
+	h := DefaultHasher()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := parseArgon2(tt.hash); err != errMalformedHash {
+				t.Fatalf("parseArgon2(%q): err = %v, want %v", tt.hash, err, errMalformedHash)
+			}
+			// A malformed stored hash is a failed login, never a match or a panic.
+			if ok, rehash, err := h.Verify(context.Background(), tt.hash, "password"); ok || rehash || err != nil {
+				t.Fatalf("Verify(%q) = %v, %v, %v", tt.hash, ok, rehash, err)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformedBcrypt(t *testing.T) {
+	h := testHasher(Bcrypt)
+	for _, hash := range []string{
+		"$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOe",
+		"$2a$5$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		"$2x$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		"$scrypt$ln=16,r=8,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"U*U",
+	} {
+		if ok, rehash, err := h.Verify(context.Background(), hash, "U*U"); ok || rehash || err != nil {
+			t.Errorf("Verify(%q) = %v, %v, %v", hash, ok, rehash, err)
+		}
+	}
+}
+
+func TestHasherValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		h    *Hasher
+		ok   bool
+	}{
+		{"default", DefaultHasher(), true},
+		{"bcrypt", &Hasher{Algorithm: Bcrypt, BcryptCost: 12}, true},
+		{"bcrypt cost too low", &Hasher{Algorithm: Bcrypt, BcryptCost: 3}, false},
+		{"bcrypt cost too high", &Hasher{Algorithm: Bcrypt, BcryptCost: 32}, false},
+		{"argon2id no iterations", &Hasher{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 64, Parallelism: 1}}, false},
+		{"argon2id no lanes", &Hasher{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 64, Iterations: 1}}, false},
+		{"argon2id too little memory per lane", &Hasher{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 15, Iterations: 1, Parallelism: 2}}, false},
+		{"unknown", &Hasher{Algorithm: "scrypt"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.h.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	if got := testHasher(Bcrypt).MaxBytes(); got != 72 {
+		t.Errorf("bcrypt MaxBytes = %d", got)
+	}
+	if got := testHasher(Argon2id).MaxBytes(); got != 0 {
+		t.Errorf("argon2id MaxBytes = %d", got)
+	}
+}
+
+func TestCalibrateDummy(t *testing.T) {
+	ctx := context.Background()
+	h := testHasher(Argon2id)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("legacy"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ := h.Hash(ctx, "current")
+	if err := h.CalibrateDummy([]string{current, "not a hash", "$2x$08$" + string(legacy[7:]), string(legacy)}); err != nil {
+		t.Fatal(err)
+	}
+	// bcrypt cost 8 is far slower than the test argon2id settings.
+	if !strings.HasPrefix(h.dummy, "$2a$08$") {
+		t.Fatalf("dummy = %q, want a bcrypt cost 8 hash", h.dummy)
+	}
+	cost := time.Duration(h.dummyCost.Load())
+	if cost <= 0 {
+		t.Fatal("dummy cost not measured")
+	}
+
+	// A wrong password on the cheap hash is padded to the dummy's cost; a
+	// right one is not.
+	start := time.Now()
+	if ok, _, _ := h.Verify(ctx, current, "wrong"); ok {
+		t.Fatal("wrong password accepted")
+	}
+	if took := time.Since(start); took < cost*9/10 {
+		t.Errorf("failed Verify took %v, dummy costs %v", took, cost)
+	}
+	start = time.Now()
+	if err := h.VerifyDummy(ctx, "wrong"); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took < cost*9/10 {
+		t.Errorf("VerifyDummy took %v, dummy costs %v", took, cost)
+	}
+	start = time.Now()
+	if ok, _, _ := h.Verify(ctx, current, "current"); !ok {
+		t.Fatal("right password rejected")
+	}
+	if took := time.Since(start); took > cost/2 {
+		t.Errorf("successful Verify took %v; only failures are padded", took)
+	}
+}
+
+func TestVerifyDummyDefaultsToCurrentSettings(t *testing.T) {
+	h := testHasher(Argon2id)
+	if err := h.VerifyDummy(context.Background(), "anything"); err != nil {
+		t.Fatal(err)
+	}
+	if !phc.MatchString(h.dummy) {
+		t.Fatalf("uncalibrated dummy = %q", h.dummy)
+	}
+}
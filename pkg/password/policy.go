@@ -0,0 +1,160 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violation codes reported by Policy.Check.
+const (
+	TooShort       = "TOO_SHORT"
+	TooLong        = "TOO_LONG"
+	MissingLower   = "MISSING_LOWERCASE"
+	MissingUpper   = "MISSING_UPPERCASE"
+	MissingDigit   = "MISSING_DIGIT"
+	MissingSymbol  = "MISSING_SYMBOL"
+	Common         = "COMMON_PASSWORD"
+	SimilarToEmail = "SIMILAR_TO_EMAIL"
+	Breached       = "BREACHED"
+)
+
+// Character classes a policy can require.
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+// Violation is one rule a password breaks.
+type Violation struct {
+	Code    string `json:"code" example:"TOO_SHORT"`
+	Message string `json:"message" example:"must be at least 8 characters"`
+}
+
+//go:embed common.txt
+var commonList string
+
+var common = func() map[string]bool {
+	m := map[string]bool{}
+	sc := bufio.NewScanner(strings.NewReader(commonList))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			m[line] = true
+		}
+	}
+	return m
+}()
+
+// Policy decides which passwords are acceptable. Lengths count characters
+// (runes), not bytes.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes is the longest input the hash accepts (Hasher.MaxBytes); 0
+	// means no limit.
+	MaxBytes int
+	// Require lists the character classes (ClassLower, ...) a password must contain.
+	Require []string
+	// Breach, if set, rejects passwords found in known data breaches.
+	Breach BreachChecker
+}
+
+// DefaultPolicy follows NIST SP 800-63B: length and blocklists, no
+// composition rules.
+func DefaultPolicy() *Policy {
+	return &Policy{MinLength: 8, MaxLength: 128}
+}
+
+// Check returns every rule pw breaks, or nil. email is the account's
+// address; passwords built from it are rejected. The breach check runs only
+// when the local rules pass, and a checker error is logged and ignored so an
+// outage does not block sign-ups.
+func (p *Policy) Check(ctx context.Context, pw, email string) []Violation {
+	var out []Violation
+	n := utf8.RuneCountInString(pw)
+	if n < p.MinLength {
+		out = append(out, Violation{TooShort, "must be at least " + strconv.Itoa(p.MinLength) + " characters"})
+	}
+	if (p.MaxLength > 0 && n > p.MaxLength) || (p.MaxBytes > 0 && len(pw) > p.MaxBytes) {
+		out = append(out, Violation{TooLong, "is too long"})
+	}
+	for _, class := range p.Require {
+		if v, ok := missingClass(pw, class); ok {
+			out = append(out, v)
+		}
+	}
+	if isCommon(pw) {
+		out = append(out, Violation{Common, "is too common"})
+	}
+	if similarToEmail(pw, email) {
+		out = append(out, Violation{SimilarToEmail, "must not contain your email address"})
+	}
+	if len(out) == 0 && p.Breach != nil && pw != "" {
+		found, err := p.Breach.Breached(ctx, pw)
+		if err != nil {
+			slog.WarnContext(ctx, "breached password check failed", "err", err)
+		} else if found {
+			out = append(out, Violation{Breached, "appeared in a data breach; choose another"})
+		}
+	}
+	return out
+}
+
+func missingClass(pw, class string) (Violation, bool) {
+	var test func(rune) bool
+	var v Violation
+	switch class {
+	case ClassLower:
+		test, v = unicode.IsLower, Violation{MissingLower, "must contain a lowercase letter"}
+	case ClassUpper:
+		test, v = unicode.IsUpper, Violation{MissingUpper, "must contain an uppercase letter"}
+	case ClassDigit:
+		test, v = unicode.IsDigit, Violation{MissingDigit, "must contain a digit"}
+	case ClassSymbol:
+		test = func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) }
+		v = Violation{MissingSymbol, "must contain a symbol"}
+	default:
+		return Violation{}, false
+	}
+	return v, strings.IndexFunc(pw, test) < 0
+}
+
+// isCommon matches the blocklist case-insensitively, also without trailing
+// digits and symbols ("Summer2024!" is "summer").
+func isCommon(pw string) bool {
+	lower := strings.ToLower(pw)
+	if common[lower] {
+		return true
+	}
+	base := strings.TrimRightFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	return len(base) >= 4 && common[base]
+}
+
+// similarToEmail reports whether pw contains the email's local part,
+// ignoring case and any +tag.
+func similarToEmail(pw, email string) bool {
+	local, _, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok {
+		return false
+	}
+	local, _, _ = strings.Cut(local, "+")
+	lower := strings.ToLower(pw)
+	return len(local) >= 3 && strings.Contains(lower, local)
+}
+
+// ValidClass reports whether class is a known character class.
+func ValidClass(class string) bool {
+	switch class {
+	case ClassLower, ClassUpper, ClassDigit, ClassSymbol:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package password
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type fakeBreach struct {
+	found bool
+	err   error
+	calls int
+}
+
+func (f *fakeBreach) Breached(context.Context, string) (bool, error) {
+	f.calls++
+	return f.found, f.err
+}
+
+func codes(v []Violation) []string {
+	out := []string{}
+	for _, x := range v {
+		out = append(out, x.Code)
+	}
+	return out
+}
+
+func TestPolicyCheck(t *testing.T) {
+	all := []string{ClassLower, ClassUpper, ClassDigit, ClassSymbol}
+	tests := []struct {
+		name   string
+		policy Policy
+		pw     string
+		email  string
+		want   []string
+	}{
+		{name: "ok", policy: Policy{MinLength: 8, MaxLength: 64}, pw: "tulip orbit canvas", want: []string{}},
+		{name: "too short", policy: Policy{MinLength: 8}, pw: "x7#kq", want: []string{TooShort}},
+		{name: "empty", policy: Policy{MinLength: 1}, pw: "", want: []string{TooShort}},
+		{name: "exactly min", policy: Policy{MinLength: 8}, pw: "x7#kq9!z", want: []string{}},
+		{name: "too long", policy: Policy{MinLength: 1, MaxLength: 10}, pw: "x7#kq9!zvbn", want: []string{TooLong}},
+		{name: "exactly max", policy: Policy{MinLength: 1, MaxLength: 10}, pw: "x7#kq9!zvb", want: []string{}},
+		// Lengths count runes: seven two-byte runes are 14 bytes but too short.
+		{name: "runes not bytes short", policy: Policy{MinLength: 8}, pw: "ñøçåßðþ", want: []string{TooShort}},
+		{name: "runes not bytes ok", policy: Policy{MinLength: 8}, pw: "ñøçåßðþæ", want: []string{}},
+		{name: "multibyte under max runes", policy: Policy{MinLength: 1, MaxLength: 4}, pw: "🔑🔒🗝🚪", want: []string{}},
+		{name: "over max bytes", policy: Policy{MinLength: 1, MaxLength: 64, MaxBytes: 12}, pw: "🔑🔒🗝🚪", want: []string{TooLong}},
+		{name: "no max", policy: Policy{MinLength: 1}, pw: strings.Repeat("qz", 500), want: []string{}},
+
+		{name: "all classes present", policy: Policy{MinLength: 1, Require: all}, pw: "Zq7#", want: []string{}},
+		{name: "missing every class", policy: Policy{MinLength: 1, Require: all}, pw: "     ", want: []string{MissingLower, MissingUpper, MissingDigit, MissingSymbol}},
+		{name: "missing upper and symbol", policy: Policy{MinLength: 1, Require: all}, pw: "zq7x", want: []string{MissingUpper, MissingSymbol}},
+		{name: "unicode letters count", policy: Policy{MinLength: 1, Require: []string{ClassLower, ClassUpper}}, pw: "Ωß", want: []string{}},
+		{name: "unicode digits count", policy: Policy{MinLength: 1, Require: []string{ClassDigit}}, pw: "zq٣", want: []string{}},
+		{name: "space is not a symbol", policy: Policy{MinLength: 1, Require: []string{ClassSymbol}}, pw: "zq xv", want: []string{MissingSymbol}},
+		{name: "unknown class ignored", policy: Policy{MinLength: 1, Require: []string{"emoji"}}, pw: "zqxv", want: []string{}},
+
+		{name: "common", policy: Policy{MinLength: 1}, pw: "password", want: []string{Common}},
+		{name: "common any case", policy: Policy{MinLength: 1}, pw: "PassWord", want: []string{Common}},
+		{name: "common with trailing digits and symbols", policy: Policy{MinLength: 1}, pw: "Password123!", want: []string{Common}},
+		{name: "common numeric", policy: Policy{MinLength: 1}, pw: "12345678", want: []string{Common}},
+		{name: "common word inside a phrase", policy: Policy{MinLength: 1}, pw: "password tulip orbit", want: []string{}},
+		{name: "four letter base stripped", policy: Policy{MinLength: 1}, pw: "Blue2024!", want: []string{Common}},
+		{name: "shorter base not stripped", policy: Policy{MinLength: 1}, pw: "Red2024!", want: []string{}},
+
+		{name: "contains email local part", policy: Policy{MinLength: 1}, pw: "jdoe-rocks-42", email: "jdoe@example.com", want: []string{SimilarToEmail}},
+		{name: "email match ignores case", policy: Policy{MinLength: 1}, pw: "JDoe-rocks-42", email: "JDOE@example.com", want: []string{SimilarToEmail}},
+		{name: "email match ignores plus tag", policy: Policy{MinLength: 1}, pw: "jdoe-rocks-42", email: "jdoe+shop@example.com", want: []string{SimilarToEmail}},
+		{name: "short local part ignored", policy: Policy{MinLength: 1}, pw: "jd-rocks-42", email: "jd@example.com", want: []string{}},
+		{name: "domain not matched", policy: Policy{MinLength: 1}, pw: "example-rocks-42", email: "jdoe@example.com", want: []string{}},
+		{name: "no email", policy: Policy{MinLength: 1}, pw: "jdoe-rocks-42", email: "", want: []string{}},
+
+		{name: "several rules at once", policy: Policy{MinLength: 12, Require: []string{ClassSymbol}}, pw: "jdoe2024", email: "jdoe@example.com", want: []string{TooShort, MissingSymbol, SimilarToEmail}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := codes(tt.policy.Check(context.Background(), tt.pw, tt.email))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Check(%q, %q) = %v, want %v", tt.pw, tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyBreach(t *testing.T) {
+	ctx := context.Background()
+
+	found := &fakeBreach{found: true}
+	p := Policy{MinLength: 8, Breach: found}
+	if got := codes(p.Check(ctx, "tulip orbit canvas", "")); !slices.Equal(got, []string{Breached}) {
+		t.Fatalf("breached password: %v", got)
+	}
+
+	// The network lookup is skipped when a local rule already fails.
+	found.calls = 0
+	p.Check(ctx, "short", "")
+	if found.calls != 0 {
+		t.Fatalf("breach checker called %d times for a locally rejected password", found.calls)
+	}
+
+	// An unavailable checker must not block the password.
+	p.Breach = &fakeBreach{err: errors.New("unreachable")}
+	if got := p.Check(ctx, "tulip orbit canvas", ""); len(got) != 0 {
+		t.Fatalf("checker error: %v", got)
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	p := DefaultPolicy()
+	if len(p.Require) != 0 {
+		t.Fatalf("default policy requires classes %v", p.Require)
+	}
+	if got := p.Check(context.Background(), "correct horse battery staple", "a@b.c"); len(got) != 0 {
+		t.Fatalf("passphrase rejected: %v", got)
+	}
+}
+
+func TestCommonListNormalized(t *testing.T) {
+	if len(common) < 100 {
+		t.Fatalf("blocklist has %d entries", len(common))
+	}
+	for pw := range common {
+		if pw != strings.ToLower(pw) || pw != strings.TrimSpace(pw) {
+			t.Errorf("blocklist entry %q is not lowercase and trimmed", pw)
+		}
+	}
+}
+
+func TestRangeChecker(t *testing.T) {
+	sum := sha1.Sum([]byte("hunter2"))
+	h := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var gotPath, gotPadding string
+	status, count := http.StatusOK, "17"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotPadding = r.URL.Path, r.Header.Get("Add-Padding")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n%s:%s\r\n", strings.ToLower(h[5:]), count)
+	}))
+	defer srv.Close()
+	c := NewRangeChecker(srv.URL + "/range")
+	ctx := context.Background()
+
+	found, err := c.Breached(ctx, "hunter2")
+	if err != nil || !found {
+		t.Fatalf("Breached(hunter2) = %v, %v", found, err)
+	}
+	// Only the 5-character prefix leaves the service.
+	if gotPath != "/range/"+h[:5] || gotPadding != "true" {
+		t.Fatalf("request path %q, Add-Padding %q", gotPath, gotPadding)
+	}
+	if found, err := c.Breached(ctx, "tulip orbit canvas"); err != nil || found {
+		t.Fatalf("other suffix: %v, %v", found, err)
+	}
+	// Padding entries have a count of 0 and never match.
+	count = "0"
+	if found, err := c.Breached(ctx, "hunter2"); err != nil || found {
+		t.Fatalf("padding entry: %v, %v", found, err)
+	}
+	status = http.StatusServiceUnavailable
+	if _, err := c.Breached(ctx, "hunter2"); err == nil {
+		t.Fatal("no error for a failed range request")
+	}
+}
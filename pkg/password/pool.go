@@ -0,0 +1,113 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBusy is returned by Hash and Verify when every hashing slot is taken and
+// the request could not wait: the queue was full, or MaxWait passed or the
+// request was cancelled before a slot freed up.
+var ErrBusy = errors.New("password hashing is busy")
+
+// Pool bounds how many hashes run at once. Hashing is CPU and (for
+// argon2id) memory heavy, so without a limit a burst of logins takes every
+// core and starves health checks and cheap requests. Callers beyond the
+// workers queue for a slot, up to a queue length and a wait limit.
+type Pool struct {
+	slots    chan struct{}
+	maxQueue int64
+	maxWait  time.Duration
+
+	queued    atomic.Int64
+	acquired  atomic.Uint64
+	queueFull atomic.Uint64
+	timedOut  atomic.Uint64
+	waitNanos atomic.Int64
+}
+
+// PoolStats is a snapshot of a Pool for metrics.
+type PoolStats struct {
+	Workers int
+	InUse   int
+	Queued  int
+	// Acquired counts hashes that got a slot; QueueFull and TimedOut count
+	// those refused with ErrBusy. TimedOut includes requests cancelled while
+	// queued.
+	Acquired  uint64
+	QueueFull uint64
+	TimedOut  uint64
+	// WaitTotal is the time spent queueing by hashes that got a slot.
+	WaitTotal time.Duration
+}
+
+// DefaultWorkers leaves half the CPUs for everything else.
+func DefaultWorkers() int {
+	return max(1, runtime.GOMAXPROCS(0)/2)
+}
+
+// NewPool runs up to workers hashes at once. Up to queue more wait for a
+// slot, each for at most maxWait.
+func NewPool(workers, queue int, maxWait time.Duration) *Pool {
+	return &Pool{slots: make(chan struct{}, max(1, workers)), maxQueue: int64(queue), maxWait: maxWait}
+}
+
+// acquire takes a slot; a nil Pool never blocks. Slots go to waiters in
+// arrival order: a free slot is only taken directly while nobody queues.
+func (p *Pool) acquire(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	if p.queued.Load() == 0 {
+		select {
+		case p.slots <- struct{}{}:
+			p.acquired.Add(1)
+			return nil
+		default:
+		}
+	}
+	if p.queued.Add(1) > p.maxQueue {
+		p.queued.Add(-1)
+		p.queueFull.Add(1)
+		return ErrBusy
+	}
+	defer p.queued.Add(-1)
+	start := time.Now()
+	t := time.NewTimer(p.maxWait)
+	defer t.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		p.waitNanos.Add(int64(time.Since(start)))
+		p.acquired.Add(1)
+		return nil
+	case <-t.C:
+		p.timedOut.Add(1)
+		return ErrBusy
+	case <-ctx.Done():
+		// A client that gave up or a request deadline is the same overload
+		// as MaxWait passing, and gets the same 503.
+		p.timedOut.Add(1)
+		return ErrBusy
+	}
+}
+
+func (p *Pool) release() {
+	if p != nil {
+		<-p.slots
+	}
+}
+
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Workers:   cap(p.slots),
+		InUse:     len(p.slots),
+		Queued:    int(p.queued.Load()),
+		Acquired:  p.acquired.Load(),
+		QueueFull: p.queueFull.Load(),
+		TimedOut:  p.timedOut.Load(),
+		WaitTotal: time.Duration(p.waitNanos.Load()),
+	}
+}
@@ -0,0 +1,116 @@
+package password
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitQueued blocks until n callers are queued on p.
+func waitQueued(t *testing.T, p *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued = %d, want %d", p.Stats().Queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolBusy(t *testing.T) {
+	tests := []struct {
+		name    string
+		queue   int
+		maxWait time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+		cancel  bool
+	}{
+		{name: "queue full", queue: 0, maxWait: time.Second},
+		{name: "max wait", queue: 1, maxWait: 10 * time.Millisecond},
+		{name: "deadline", queue: 1, maxWait: time.Second, ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 10*time.Millisecond)
+		}},
+		{name: "cancelled", queue: 1, maxWait: time.Second, cancel: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPool(1, tt.queue, tt.maxWait)
+			if err := p.acquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer p.release()
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+			if tt.cancel {
+				go func() {
+					for p.Stats().Queued == 0 {
+						time.Sleep(time.Millisecond)
+					}
+					cancel()
+				}()
+			}
+			if err := p.acquire(ctx); err != ErrBusy {
+				t.Fatalf("acquire: err = %v, want %v", err, ErrBusy)
+			}
+			s := p.Stats()
+			if s.Queued != 0 || s.InUse != 1 || s.Acquired != 1 || s.QueueFull+s.TimedOut != 1 {
+				t.Fatalf("stats = %+v", s)
+			}
+		})
+	}
+}
+
+func TestPoolOrder(t *testing.T) {
+	p := NewPool(1, 4, time.Second)
+	ctx := context.Background()
+	if err := p.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	order := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func() {
+			if err := p.acquire(ctx); err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+		}()
+		waitQueued(t, p, i)
+	}
+	p.release()
+	if first := <-order; first != 1 {
+		t.Fatalf("waiter %d got the slot first", first)
+	}
+	// A new arrival while one still queues does not take the next slot.
+	late := make(chan struct{})
+	go func() {
+		if err := p.acquire(ctx); err == nil {
+			close(late)
+		}
+	}()
+	waitQueued(t, p, 2)
+	p.release()
+	if second := <-order; second != 2 {
+		t.Fatalf("waiter %d got the slot second", second)
+	}
+	p.release()
+	<-late
+	p.release()
+	if s := p.Stats(); s.InUse != 0 || s.Queued != 0 || s.Acquired != 4 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestNilPool(t *testing.T) {
+	var p *Pool
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.acquire(ctx); err != nil {
+		t.Fatalf("nil pool: %v", err)
+	}
+	p.release()
+}